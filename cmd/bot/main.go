@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"os"
+	"os/signal"
 	"stomatology_bot/configs"
 	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/catalog"
+	"stomatology_bot/internal/doctor"
+	"stomatology_bot/internal/i18n"
 	"stomatology_bot/internal/logger"
+	"stomatology_bot/internal/patient"
 	"stomatology_bot/internal/platform/calendar"
 	"stomatology_bot/internal/platform/database"
 	"stomatology_bot/internal/platform/telegram"
+	"stomatology_bot/internal/preference"
+	"stomatology_bot/internal/schedule"
+	"stomatology_bot/internal/session"
+	"syscall"
+	"time"
 
 	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/golang-migrate/migrate/v4"
@@ -23,10 +35,29 @@ func main() {
 		logrus.WithError(err).Fatal("Failed to load config")
 	}
 	logger.SetupLogger(cfg.LogLevel)
-	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", cfg.DB.User, cfg.DB.Password, cfg.DB.Host, cfg.DB.Port, cfg.DB.Name)
+
+	// Контекст отменяется по SIGINT/SIGTERM (в том числе при docker stop)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Правки шаблонов сообщений оператора; с некорректными шаблонами бот не запускается
+	if err := i18n.Load(cfg.Telegram.TemplatesDir); err != nil {
+		logrus.WithError(err).Fatal("Failed to load message templates")
+	}
+	if cfg.Telegram.TemplatesDir != "" {
+		go i18n.Watch(ctx, cfg.Telegram.TemplatesDir, time.Duration(cfg.Telegram.TemplatesReloadSeconds)*time.Second)
+	}
+
+	// Пул соединений к БД, общий для всех обработчиков.
+	// Создается до миграций: при холодном старте база может быть еще недоступна, и подключение повторяется
+	pool, err := database.NewPool(ctx, cfg.DB)
+	if err != nil {
+		logrus.WithError(err).Fatal("Failed to connect to database")
+	}
 
 	// Применение миграций
 	{
+		dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", cfg.DB.User, cfg.DB.Password, cfg.DB.Host, cfg.DB.Port, cfg.DB.Name)
 		db, err := sql.Open("pgx", dbURL)
 		if err != nil {
 			logrus.WithError(err).Fatal("could not connect to the database for migration")
@@ -48,17 +79,38 @@ func main() {
 		db.Close()
 	}
 
-	// Основное подключение к БД
-	pgxConn, err := database.GetConnect(cfg.DB)
-	if err != nil {
-		logrus.WithError(err).Fatal("Failed to connect to database")
+	repo := booking.NewRepo(pool)
+	doctorRepo := doctor.NewRepo(pool)
+	serviceRepo := catalog.NewRepo(pool)
+	closureRepo := schedule.NewRepo(pool)
+	sessionStore := session.NewRepo(pool, time.Duration(cfg.Telegram.SessionTTLHours)*time.Hour)
+	preferenceRepo := preference.NewRepo(pool)
+	patientRepo := patient.NewRepo(pool)
+
+	// Праздничные дни из производственного календаря, если он настроен
+	if cfg.Telegram.HolidaysFile != "" {
+		imported, err := closureRepo.ImportProductionCalendarFile(ctx, cfg.Telegram.HolidaysFile)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to import production calendar")
+		} else {
+			logrus.Infof("Imported %d holidays from production calendar", imported)
+		}
 	}
-	repo := booking.NewRepo(pgxConn)
 
-	calendarSvc, err := calendar.NewService("credentials.json", cfg.Telegram.CalendarID, cfg.Telegram.WorkStartHour, cfg.Telegram.WorkEndHour)
-	if err != nil {
-		logrus.WithError(err).Fatal("Failed to create calendar service")
+	var calendarSvc calendar.Provider
+	switch cfg.Telegram.CalendarBackend {
+	case calendar.BackendGoogle:
+		calendarSvc, err = calendar.NewService("credentials.json", cfg.Telegram.CalendarID, cfg.Telegram.WorkSchedule, closureRepo)
+		if err != nil {
+			logrus.WithError(err).Fatal("Failed to create calendar service")
+		}
+	case calendar.BackendLocal:
+		// События хранятся в PostgreSQL, credentials.json не нужен
+		calendarSvc = calendar.NewLocalService(pool, cfg.Telegram.CalendarID, cfg.Telegram.WorkSchedule, closureRepo)
+	default:
+		logrus.WithField("backend", cfg.Telegram.CalendarBackend).Fatal("Unknown calendar backend")
 	}
+	logrus.Infof("Using %s calendar backend", cfg.Telegram.CalendarBackend)
 
 	botAPI, err := tgbot.NewBotAPI(cfg.Telegram.Token)
 	if err != nil {
@@ -71,6 +123,9 @@ func main() {
 	botAPI.Debug = true
 	logrus.Infof("Authorized on account %s", botAPI.Self.UserName)
 
-	bot := telegram.NewBot(botAPI, cfg, repo, calendarSvc)
-	bot.Start()
+	bot := telegram.NewBot(botAPI, cfg, repo, doctorRepo, serviceRepo, closureRepo, calendarSvc, sessionStore, preferenceRepo, patientRepo)
+	if err := bot.Start(ctx); err != nil {
+		logrus.WithError(err).Error("Bot stopped with error")
+	}
+	pool.Close()
 }
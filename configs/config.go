@@ -3,7 +3,11 @@ package configs
 import (
 	"fmt"
 	"os"
+	"sort"
+	"stomatology_bot/internal/schedule"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -14,11 +18,44 @@ type Config struct {
 	LogLevel string
 }
 type TelegramConfig struct {
-	Token         string
-	CalendarID    string
-	AdminID       string
-	WorkStartHour int
-	WorkEndHour   int
+	Token      string
+	CalendarID string
+	AdminIDs   []int64
+	// Где хранятся события приемов: google (Google Calendar) или local (PostgreSQL)
+	CalendarBackend string
+	// Недельное расписание работы клиники
+	WorkSchedule schedule.Week
+	// Время жизни незавершенного диалога записи (в часах)
+	SessionTTLHours int
+	// Максимальное число одновременно обрабатываемых чатов
+	Workers int
+	// Сколько секунд отводится на обработку одного обновления (запросы к БД и календарю)
+	UpdateTimeoutSeconds int
+	// Сколько секунд при остановке бот дожидается завершения уже начатой обработки обновлений
+	ShutdownTimeoutSeconds int
+	// Как бот получает обновления: polling (long polling) или webhook (HTTP-сервер)
+	UpdatesMode string
+	// Публичный HTTPS-адрес вебхука, который регистрируется в Telegram
+	WebhookURL string
+	// Адрес и путь, на которых HTTP-сервер принимает обновления
+	WebhookListenAddr string
+	WebhookPath       string
+	// Секрет, который Telegram передает в заголовке X-Telegram-Bot-Api-Secret-Token
+	WebhookSecret string
+	// За сколько до приема отправляются напоминания, по возрастанию
+	ReminderOffsets []time.Duration
+	// Ключ HMAC-подписи данных inline-кнопок
+	CallbackSecret string
+	// Сколько часов действуют inline-кнопки; не меньше самого раннего напоминания
+	CallbackTTLHours int
+	// Сколько минут выбранный слот удерживается за пациентом до подтверждения записи
+	SlotHoldMinutes int
+	// XML-файл производственного календаря для импорта праздничных дней
+	HolidaysFile string
+	// Каталог с правками шаблонов сообщений (<язык>.json); пусто - только встроенные шаблоны
+	TemplatesDir string
+	// Как часто проверять изменения в TemplatesDir, секунд
+	TemplatesReloadSeconds int
 }
 type DBConfig struct {
 	User     string
@@ -26,18 +63,72 @@ type DBConfig struct {
 	Name     string
 	Port     string
 	Host     string
+	// Размер пула соединений: не меньше BOT_WORKERS, иначе обработчики будут ждать свободное соединение
+	MaxConns int
+	MinConns int
+	// Сколько раз пытаться подключиться к БД при старте (база может подниматься дольше бота)
+	ConnectRetries int
+	// Как часто (в секундах) пул проверяет простаивающие соединения
+	HealthCheckSeconds int
 }
 
 func LoadConfig() (*Config, error) {
 	if err := godotenv.Load(".env"); err != nil {
 		return nil, fmt.Errorf("error loading .env file: %v", err)
 	}
+	workSchedule, err := loadWorkSchedule()
+	if err != nil {
+		return nil, err
+	}
+	reminderOffsets, err := loadReminderOffsets()
+	if err != nil {
+		return nil, err
+	}
 	telegramConfig := TelegramConfig{
-		Token:         os.Getenv("BOT_TOKEN"),
-		CalendarID:    os.Getenv("CALENDAR_ID"),
-		AdminID:       os.Getenv("ADMIN_ID"),
-		WorkStartHour: parseInt(os.Getenv("WORK_START_HOUR"), 9), // Значение по умолчанию 9
-		WorkEndHour:   parseInt(os.Getenv("WORK_END_HOUR"), 18),  // Значение по умолчанию 18
+		Token:                  os.Getenv("BOT_TOKEN"),
+		CalendarID:             os.Getenv("CALENDAR_ID"),
+		AdminIDs:               parseInt64List(os.Getenv("ADMIN_ID")),
+		CalendarBackend:        parseString(os.Getenv("CALENDAR_BACKEND"), "google"), // Значение по умолчанию google
+		WorkSchedule:           workSchedule,
+		ReminderOffsets:        reminderOffsets,
+		CallbackSecret:         os.Getenv("CALLBACK_SECRET"),
+		CallbackTTLHours:       parseInt(os.Getenv("CALLBACK_TTL_HOURS"), 168),      // Значение по умолчанию 168
+		SessionTTLHours:        parseInt(os.Getenv("SESSION_TTL_HOURS"), 24),        // Значение по умолчанию 24
+		Workers:                parseInt(os.Getenv("BOT_WORKERS"), 10),              // Значение по умолчанию 10
+		SlotHoldMinutes:        parseInt(os.Getenv("SLOT_HOLD_MINUTES"), 10),        // Значение по умолчанию 10
+		UpdateTimeoutSeconds:   parseInt(os.Getenv("UPDATE_TIMEOUT_SECONDS"), 30),   // Значение по умолчанию 30
+		ShutdownTimeoutSeconds: parseInt(os.Getenv("SHUTDOWN_TIMEOUT_SECONDS"), 20), // Значение по умолчанию 20
+		HolidaysFile:           os.Getenv("HOLIDAYS_FILE"),
+		TemplatesDir:           os.Getenv("TEMPLATES_DIR"),
+		TemplatesReloadSeconds: parseInt(os.Getenv("TEMPLATES_RELOAD_SECONDS"), 10), // Значение по умолчанию 10
+		UpdatesMode:            parseString(os.Getenv("UPDATES_MODE"), "polling"),   // Значение по умолчанию polling
+		WebhookURL:             os.Getenv("WEBHOOK_URL"),
+		WebhookListenAddr:      parseString(os.Getenv("WEBHOOK_LISTEN_ADDR"), ":8080"),      // Значение по умолчанию :8080
+		WebhookPath:            parseString(os.Getenv("WEBHOOK_PATH"), "/telegram/webhook"), // Значение по умолчанию /telegram/webhook
+		WebhookSecret:          os.Getenv("WEBHOOK_SECRET"),
+	}
+	// Со случайным ключом кнопки перестают работать после перезапуска и на других экземплярах бота
+	if telegramConfig.CallbackSecret == "" {
+		return nil, fmt.Errorf("CALLBACK_SECRET is required: set a long random string, e.g. openssl rand -hex 32")
+	}
+	// Нулевые значения ломают запись: обработчики сразу получают отмененный контекст,
+	// а удержание времени истекает раньше, чем пациент успеет ввести данные
+	for _, setting := range []struct {
+		name  string
+		value int
+	}{
+		{"SESSION_TTL_HOURS", telegramConfig.SessionTTLHours},
+		{"SLOT_HOLD_MINUTES", telegramConfig.SlotHoldMinutes},
+		{"UPDATE_TIMEOUT_SECONDS", telegramConfig.UpdateTimeoutSeconds},
+	} {
+		if setting.value <= 0 {
+			return nil, fmt.Errorf("%s must be positive, got %d", setting.name, setting.value)
+		}
+	}
+	// Кнопки из напоминания должны работать до самого приема
+	if ttl := time.Duration(telegramConfig.CallbackTTLHours) * time.Hour; ttl < reminderOffsets[len(reminderOffsets)-1] {
+		return nil, fmt.Errorf("CALLBACK_TTL_HOURS (%d) must cover the largest REMINDER_OFFSETS value (%s)",
+			telegramConfig.CallbackTTLHours, reminderOffsets[len(reminderOffsets)-1])
 	}
 	dbConfig := DBConfig{
 		User:     os.Getenv("DB_USER"),
@@ -45,6 +136,11 @@ func LoadConfig() (*Config, error) {
 		Name:     os.Getenv("DB_NAME"),
 		Port:     os.Getenv("DB_PORT"),
 		Host:     os.Getenv("DB_HOST"),
+
+		MaxConns:           parseInt(os.Getenv("DB_MAX_CONNS"), 10),            // Значение по умолчанию 10
+		MinConns:           parseInt(os.Getenv("DB_MIN_CONNS"), 1),             // Значение по умолчанию 1
+		ConnectRetries:     parseInt(os.Getenv("DB_CONNECT_RETRIES"), 5),       // Значение по умолчанию 5
+		HealthCheckSeconds: parseInt(os.Getenv("DB_HEALTH_CHECK_SECONDS"), 30), // Значение по умолчанию 30
 	}
 	return &Config{
 		Telegram: telegramConfig,
@@ -53,6 +149,37 @@ func LoadConfig() (*Config, error) {
 	}, nil
 }
 
+// loadWorkSchedule читает расписание из WORK_SCHEDULE. Если оно не задано, используются
+// WORK_START_HOUR и WORK_END_HOUR с понедельника по субботу, как в прежних версиях
+func loadWorkSchedule() (schedule.Week, error) {
+	if s := os.Getenv("WORK_SCHEDULE"); s != "" {
+		week, err := schedule.Parse(s)
+		if err != nil {
+			return schedule.Week{}, fmt.Errorf("invalid WORK_SCHEDULE: %v", err)
+		}
+		return week, nil
+	}
+	startHour := parseInt(os.Getenv("WORK_START_HOUR"), 9) // Значение по умолчанию 9
+	endHour := parseInt(os.Getenv("WORK_END_HOUR"), 18)    // Значение по умолчанию 18
+	return schedule.Default(startHour, endHour), nil
+}
+
+// loadReminderOffsets читает REMINDER_OFFSETS - список длительностей через запятую ("24h,2h");
+// по умолчанию одно напоминание за сутки
+func loadReminderOffsets() ([]time.Duration, error) {
+	s := parseString(os.Getenv("REMINDER_OFFSETS"), "24h") // Значение по умолчанию 24h
+	var offsets []time.Duration
+	for _, part := range strings.Split(s, ",") {
+		offset, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || offset <= 0 {
+			return nil, fmt.Errorf("invalid REMINDER_OFFSETS %q: expected positive durations like 24h,2h", s)
+		}
+		offsets = append(offsets, offset)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	return offsets, nil
+}
+
 // parseInt пытается преобразовать строку в int, возвращая defaultValue в случае ошибки
 func parseInt(s string, defaultValue int) int {
 	val, err := strconv.Atoi(s)
@@ -61,3 +188,24 @@ func parseInt(s string, defaultValue int) int {
 	}
 	return val
 }
+
+// parseString возвращает значение по умолчанию для пустой строки
+func parseString(s string, defaultValue string) string {
+	if s == "" {
+		return defaultValue
+	}
+	return s
+}
+
+// parseInt64List разбирает список чисел, разделенных запятыми; некорректные значения пропускаются
+func parseInt64List(s string) []int64 {
+	var result []int64
+	for _, part := range strings.Split(s, ",") {
+		val, err := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, val)
+	}
+	return result
+}
@@ -1,14 +1,43 @@
 package booking
 
 import (
+	"errors"
 	"time"
 )
 
+// ErrSlotTaken возвращается, если выбранное время уже занято другим пациентом
+var ErrSlotTaken = errors.New("slot is already taken")
+
+// ErrForbidden возвращается, если пользователь пытается изменить чужую запись
+var ErrForbidden = errors.New("booking belongs to another user")
+
 type Booking struct {
-	ID       int       `db:"id"`
-	UserID   int64     `db:"user_id"`
-	Name     string    `db:"name"`
-	Contact  string    `db:"contact"`
-	Datetime time.Time `db:"datetime"`
-	EventID  *string   `db:"event_id"`
+	ID              int        `db:"id"`
+	UserID          int64      `db:"user_id"`
+	Name            string     `db:"name"`
+	Contact         string     `db:"contact"`
+	Datetime        time.Time  `db:"datetime"`
+	EventID         *string    `db:"event_id"`
+	DoctorID        *int       `db:"doctor_id"`    // nil, если в клинике не заведены врачи
+	EndTime         time.Time  `db:"end_time"`     // Окончание приема, зависит от длительности услуги
+	ServiceID       *int       `db:"service_id"`   // nil, если каталог услуг не заполнен
+	ConfirmedAt     *time.Time `db:"confirmed_at"` // Когда пациент подтвердил визит по кнопке в напоминании; nil - не подтвержден
+	Status          Status     `db:"status"`
+	StatusReason    string     `db:"status_reason"`     // Причина отмены, если указана
+	StatusChangedAt time.Time  `db:"status_changed_at"` // Когда запись перешла в текущий статус
+	PatientID       *int       `db:"patient_id"`        // Профиль пациента; nil - данные введены без профиля
+}
+
+// Authorize проверяет, что запись принадлежит пользователю userID
+func (b *Booking) Authorize(userID int64) error {
+	if b.UserID != userID {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Hold - интервал времени врача, временно удерживаемый другим пользователем
+type Hold struct {
+	Start time.Time
+	End   time.Time
 }
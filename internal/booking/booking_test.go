@@ -0,0 +1,13 @@
+package booking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBooking_Authorize(t *testing.T) {
+	bk := Booking{ID: 1, UserID: 42}
+	assert.NoError(t, bk.Authorize(42))
+	assert.ErrorIs(t, bk.Authorize(43), ErrForbidden)
+}
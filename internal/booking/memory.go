@@ -0,0 +1,269 @@
+package booking
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+type reminderKey struct {
+	bookingID int
+	offset    time.Duration
+	visit     int64
+}
+
+type memoryHold struct {
+	Hold
+	doctorID  int
+	userID    int64
+	expiresAt time.Time
+}
+
+// MemoryStore - хранилище записей в памяти процесса (для тестов и локального запуска).
+// Повторяет поведение Repo: пересечение предстоящих приемов одного врача дает ErrSlotTaken,
+// отсутствующая запись - pgx.ErrNoRows, недопустимая смена статуса - ErrInvalidTransition
+type MemoryStore struct {
+	mu        sync.Mutex
+	nextID    int
+	bookings  map[int]Booking
+	holds     []memoryHold
+	reminders map[reminderKey]bool
+	now       func() time.Time
+}
+
+// NewMemoryStore создает хранилище записей в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		bookings:  make(map[int]Booking),
+		reminders: make(map[reminderKey]bool),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryStore) CreateBooking(_ context.Context, booking *Booking) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.bookingOverlaps(doctorKey(booking.DoctorID), booking.Datetime, booking.EndTime, 0) {
+		return ErrSlotTaken
+	}
+	s.nextID++
+	booking.ID = s.nextID
+	booking.Status = StatusScheduled
+	booking.StatusChangedAt = s.now()
+	s.bookings[booking.ID] = *booking
+	return nil
+}
+
+func (s *MemoryStore) GetAllBooking(_ context.Context) ([]Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filter(func(Booking) bool { return true }), nil
+}
+
+// RescheduleBooking переносит запись на новое время. Если новое время пересекается
+// с другой записью того же врача, возвращается ErrSlotTaken
+func (s *MemoryStore) RescheduleBooking(_ context.Context, id int, start, end time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[id]
+	if !ok || !booking.Status.Active() {
+		return pgx.ErrNoRows
+	}
+	if s.bookingOverlaps(doctorKey(booking.DoctorID), start, end, id) {
+		return ErrSlotTaken
+	}
+	booking.Datetime, booking.EndTime = start, end
+	booking.ConfirmedAt = nil
+	booking.Status, booking.StatusChangedAt = StatusScheduled, s.now()
+	s.bookings[id] = booking
+	return nil
+}
+
+// UpdateStatus переводит запись в новый статус, как Repo.UpdateStatus
+func (s *MemoryStore) UpdateStatus(_ context.Context, id int, status Status, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[id]
+	if !ok {
+		return pgx.ErrNoRows
+	}
+	if !booking.Status.CanTransitionTo(status) {
+		return ErrInvalidTransition
+	}
+	now := s.now()
+	if status == StatusConfirmed {
+		booking.ConfirmedAt = &now
+	}
+	booking.Status, booking.StatusReason, booking.StatusChangedAt = status, reason, now
+	s.bookings[id] = booking
+	return nil
+}
+
+func (s *MemoryStore) GetUserBookings(_ context.Context, userID int64, statuses []Status) ([]Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bookings := s.filter(func(b Booking) bool { return b.UserID == userID && hasStatus(b, statuses) })
+	sort.SliceStable(bookings, func(i, j int) bool { return bookings[i].Datetime.Before(bookings[j].Datetime) })
+	return bookings, nil
+}
+
+func (s *MemoryStore) GetBookingByID(_ context.Context, id int) (*Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	booking, ok := s.bookings[id]
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+	return &booking, nil
+}
+
+func (s *MemoryStore) GetUpcomingBookings(_ context.Context, from, to time.Time, statuses []Status) ([]Booking, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.filter(func(b Booking) bool {
+		return !b.Datetime.Before(from) && b.Datetime.Before(to) && hasStatus(b, statuses)
+	}), nil
+}
+
+// HoldSlot временно закрепляет интервал [start, end) врача за пользователем, как Repo.HoldSlot
+func (s *MemoryStore) HoldSlot(_ context.Context, userID int64, doctorID int, start, end time.Time, ttl time.Duration, excludeBookingID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.bookingOverlaps(doctorID, start, end, excludeBookingID) {
+		return ErrSlotTaken
+	}
+	for _, hold := range s.holds {
+		if hold.userID != userID && !hold.expiresAt.Before(now) &&
+			hold.doctorID == doctorID && overlaps(hold.Start, hold.End, start, end) {
+			return ErrSlotTaken
+		}
+	}
+
+	// Прежнее удержание пользователя снимается только после успешной проверки, как при откате транзакции в Repo
+	holds := s.holds[:0]
+	for _, hold := range s.holds {
+		if hold.userID != userID && !hold.expiresAt.Before(now) {
+			holds = append(holds, hold)
+		}
+	}
+	s.holds = append(holds, memoryHold{
+		Hold:      Hold{Start: start, End: end},
+		doctorID:  doctorID,
+		userID:    userID,
+		expiresAt: now.Add(ttl),
+	})
+	return nil
+}
+
+// ReleaseHold снимает удержание слотов пользователем
+func (s *MemoryStore) ReleaseHold(_ context.Context, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holds := s.holds[:0]
+	for _, hold := range s.holds {
+		if hold.userID != userID {
+			holds = append(holds, hold)
+		}
+	}
+	s.holds = holds
+	return nil
+}
+
+// GetHeldSlots возвращает интервалы врача, пересекающиеся с [from, to) и удерживаемые другими пользователями
+func (s *MemoryStore) GetHeldSlots(_ context.Context, doctorID int, from, to time.Time, exceptUserID int64) ([]Hold, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	var held []Hold
+	for _, hold := range s.holds {
+		if hold.doctorID == doctorID && hold.userID != exceptUserID && hold.expiresAt.After(now) &&
+			overlaps(hold.Start, hold.End, from, to) {
+			held = append(held, hold.Hold)
+		}
+	}
+	return held, nil
+}
+
+// ClaimReminder отмечает напоминание как отправленное, как Repo.ClaimReminder
+func (s *MemoryStore) ClaimReminder(_ context.Context, bookingID int, offset time.Duration, visit time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := reminderKey{bookingID: bookingID, offset: offset, visit: visit.UnixNano()}
+	if s.reminders[key] {
+		return false, nil
+	}
+	s.reminders[key] = true
+	return true, nil
+}
+
+// ReleaseReminder снимает отметку об отправке напоминания
+func (s *MemoryStore) ReleaseReminder(_ context.Context, bookingID int, offset time.Duration, visit time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reminders, reminderKey{bookingID: bookingID, offset: offset, visit: visit.UnixNano()})
+	return nil
+}
+
+// bookingOverlaps проверяет пересечение с предстоящими записями врача, кроме записи exceptID
+func (s *MemoryStore) bookingOverlaps(doctorID int, start, end time.Time, exceptID int) bool {
+	for id, booking := range s.bookings {
+		if id != exceptID && booking.Status.Active() && doctorKey(booking.DoctorID) == doctorID &&
+			overlaps(booking.Datetime, booking.EndTime, start, end) {
+			return true
+		}
+	}
+	return false
+}
+
+// filter возвращает копии подходящих записей в порядке создания
+func (s *MemoryStore) filter(match func(Booking) bool) []Booking {
+	var bookings []Booking
+	for _, booking := range s.bookings {
+		if match(booking) {
+			bookings = append(bookings, booking)
+		}
+	}
+	sort.Slice(bookings, func(i, j int) bool { return bookings[i].ID < bookings[j].ID })
+	return bookings
+}
+
+// hasStatus проверяет запись по фильтру статусов; nil - без фильтра
+func hasStatus(booking Booking, statuses []Status) bool {
+	if statuses == nil {
+		return true
+	}
+	for _, status := range statuses {
+		if booking.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+// doctorKey повторяет COALESCE(doctor_id, 0) из ограничения на пересечение приемов
+func doctorKey(doctorID *int) int {
+	if doctorID == nil {
+		return 0
+	}
+	return *doctorID
+}
+
+func overlaps(aStart, aEnd, bStart, bEnd time.Time) bool {
+	return aStart.Before(bEnd) && bStart.Before(aEnd)
+}
@@ -0,0 +1,234 @@
+package booking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_CreateBooking(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+	doctorID := 2
+
+	first := &Booking{UserID: gofakeit.Int64(), Name: gofakeit.Name(), Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, store.CreateBooking(context.Background(), first))
+	assert.Equal(t, 1, first.ID)
+
+	// Пересечение с приемом того же кабинета
+	overlapping := &Booking{UserID: gofakeit.Int64(), Datetime: start.Add(30 * time.Minute), EndTime: start.Add(90 * time.Minute)}
+	assert.ErrorIs(t, store.CreateBooking(context.Background(), overlapping), ErrSlotTaken)
+
+	// У другого врача то же время свободно, а впритык к приему - тоже
+	assert.NoError(t, store.CreateBooking(context.Background(), &Booking{Datetime: start, EndTime: start.Add(time.Hour), DoctorID: &doctorID}))
+	assert.NoError(t, store.CreateBooking(context.Background(), &Booking{Datetime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)}))
+
+	got, err := store.GetBookingByID(context.Background(), first.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, first, got)
+
+	_, err = store.GetBookingByID(context.Background(), 100)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
+}
+
+func TestMemoryStore_ReturnsCopies(t *testing.T) {
+	store := NewMemoryStore()
+	start := gofakeit.Date()
+	booking := &Booking{Name: "Иван", Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, store.CreateBooking(context.Background(), booking))
+
+	booking.Name = "Петр"
+	got, err := store.GetBookingByID(context.Background(), booking.ID)
+	assert.NoError(t, err)
+	got.Name = "Анна"
+
+	got, err = store.GetBookingByID(context.Background(), booking.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Иван", got.Name)
+}
+
+func TestMemoryStore_Queries(t *testing.T) {
+	store := NewMemoryStore()
+	userID := gofakeit.Int64()
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		slot := start.AddDate(0, 0, i)
+		assert.NoError(t, store.CreateBooking(context.Background(), &Booking{UserID: userID, Datetime: slot, EndTime: slot.Add(time.Hour)}))
+	}
+	assert.NoError(t, store.CreateBooking(context.Background(), &Booking{UserID: userID + 1, Datetime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)}))
+
+	all, err := store.GetAllBooking(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	userBookings, err := store.GetUserBookings(context.Background(), userID, nil)
+	assert.NoError(t, err)
+	assert.Len(t, userBookings, 3)
+
+	// Интервал [from, to) по времени начала приема
+	upcoming, err := store.GetUpcomingBookings(context.Background(), start, start.AddDate(0, 0, 1), nil)
+	assert.NoError(t, err)
+	assert.Len(t, upcoming, 2)
+
+	// Отмененная запись остается в истории, но не попадает в активные
+	assert.NoError(t, store.UpdateStatus(context.Background(), 1, StatusCancelledByPatient, ""))
+	userBookings, err = store.GetUserBookings(context.Background(), userID, ActiveStatuses)
+	assert.NoError(t, err)
+	assert.Len(t, userBookings, 2)
+	userBookings, err = store.GetUserBookings(context.Background(), userID, nil)
+	assert.NoError(t, err)
+	assert.Len(t, userBookings, 3)
+	upcoming, err = store.GetUpcomingBookings(context.Background(), start, start.AddDate(0, 0, 1), ActiveStatuses)
+	assert.NoError(t, err)
+	assert.Len(t, upcoming, 1)
+}
+
+func TestMemoryStore_RescheduleBooking(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+
+	moved := &Booking{Datetime: start, EndTime: start.Add(time.Hour)}
+	other := &Booking{Datetime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)}
+	assert.NoError(t, store.CreateBooking(context.Background(), moved))
+	assert.NoError(t, store.CreateBooking(context.Background(), other))
+
+	// Сдвиг внутри собственного интервала не конфликтует сам с собой
+	assert.NoError(t, store.RescheduleBooking(context.Background(), moved.ID, start.Add(30*time.Minute), start.Add(90*time.Minute)))
+	assert.ErrorIs(t, store.RescheduleBooking(context.Background(), moved.ID, start.Add(2*time.Hour), start.Add(3*time.Hour)), ErrSlotTaken)
+	assert.ErrorIs(t, store.RescheduleBooking(context.Background(), 100, start, start.Add(time.Hour)), pgx.ErrNoRows)
+
+	got, err := store.GetBookingByID(context.Background(), moved.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, start.Add(30*time.Minute), got.Datetime)
+	assert.Equal(t, start.Add(90*time.Minute), got.EndTime)
+}
+
+func TestMemoryStore_HoldSlot(t *testing.T) {
+	store := NewMemoryStore()
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	firstUser, secondUser := gofakeit.Int64(), gofakeit.Int64()
+
+	assert.NoError(t, store.HoldSlot(context.Background(), firstUser, 2, start, end, 10*time.Minute, 0))
+	assert.ErrorIs(t, store.HoldSlot(context.Background(), secondUser, 2, start.Add(30*time.Minute), end.Add(30*time.Minute), 10*time.Minute, 0), ErrSlotTaken)
+	// У другого врача то же время свободно
+	assert.NoError(t, store.HoldSlot(context.Background(), secondUser, 3, start, end, 10*time.Minute, 0))
+
+	held, err := store.GetHeldSlots(context.Background(), 2, start, end, secondUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{{Start: start, End: end}}, held)
+	// Собственное удержание пользователю не мешает
+	held, err = store.GetHeldSlots(context.Background(), 2, start, end, firstUser)
+	assert.NoError(t, err)
+	assert.Empty(t, held)
+
+	// Новое удержание заменяет предыдущее
+	assert.NoError(t, store.HoldSlot(context.Background(), firstUser, 2, end, end.Add(time.Hour), 10*time.Minute, 0))
+	assert.NoError(t, store.HoldSlot(context.Background(), secondUser, 2, start, end, 10*time.Minute, 0))
+
+	// Истекшее удержание не мешает другим
+	now = now.Add(time.Hour)
+	held, err = store.GetHeldSlots(context.Background(), 2, start, end.Add(time.Hour), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, held)
+	assert.NoError(t, store.HoldSlot(context.Background(), gofakeit.Int64(), 2, end, end.Add(time.Hour), 10*time.Minute, 0))
+
+	assert.NoError(t, store.ReleaseHold(context.Background(), firstUser))
+	assert.Len(t, store.holds, 1)
+}
+
+func TestMemoryStore_HoldSlot_KeepsHoldOnConflict(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+	firstUser, secondUser := gofakeit.Int64(), gofakeit.Int64()
+
+	assert.NoError(t, store.HoldSlot(context.Background(), firstUser, 2, start, end, 10*time.Minute, 0))
+	assert.NoError(t, store.HoldSlot(context.Background(), secondUser, 2, end, end.Add(time.Hour), 10*time.Minute, 0))
+
+	// Новое время занято - прежнее удержание пользователя сохраняется, как в Repo
+	assert.ErrorIs(t, store.HoldSlot(context.Background(), firstUser, 2, end, end.Add(time.Hour), 10*time.Minute, 0), ErrSlotTaken)
+	held, err := store.GetHeldSlots(context.Background(), 2, start, end, secondUser)
+	assert.NoError(t, err)
+	assert.Equal(t, []Hold{{Start: start, End: end}}, held)
+
+	// Собственное удержание не мешает сдвинуть время на пересекающийся слот
+	assert.NoError(t, store.HoldSlot(context.Background(), firstUser, 2, start.Add(-30*time.Minute), end.Add(-30*time.Minute), 10*time.Minute, 0))
+}
+
+func TestMemoryStore_HoldSlot_Booked(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+	doctorID := 2
+	assert.NoError(t, store.CreateBooking(context.Background(), &Booking{Datetime: start, EndTime: start.Add(time.Hour), DoctorID: &doctorID}))
+
+	assert.ErrorIs(t, store.HoldSlot(context.Background(), gofakeit.Int64(), doctorID, start.Add(30*time.Minute), start.Add(90*time.Minute), time.Minute, 0), ErrSlotTaken)
+	assert.NoError(t, store.HoldSlot(context.Background(), gofakeit.Int64(), 0, start, start.Add(time.Hour), time.Minute, 0))
+}
+
+func TestMemoryStore_ClaimReminder(t *testing.T) {
+	store := NewMemoryStore()
+	visit := gofakeit.Date()
+
+	claimed, err := store.ClaimReminder(context.Background(), 1, 2*time.Hour, visit)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = store.ClaimReminder(context.Background(), 1, 2*time.Hour, visit)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	// Другое напоминание и перенесенный прием отмечаются отдельно
+	claimed, err = store.ClaimReminder(context.Background(), 1, 24*time.Hour, visit)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = store.ClaimReminder(context.Background(), 1, 2*time.Hour, visit.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	assert.NoError(t, store.ReleaseReminder(context.Background(), 1, 2*time.Hour, visit))
+	claimed, err = store.ClaimReminder(context.Background(), 1, 2*time.Hour, visit)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+}
+
+func TestMemoryStore_UpdateStatus(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+	bk := &Booking{Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, store.CreateBooking(context.Background(), bk))
+	assert.Equal(t, StatusScheduled, bk.Status)
+
+	assert.NoError(t, store.UpdateStatus(context.Background(), bk.ID, StatusConfirmed, ""))
+	got, err := store.GetBookingByID(context.Background(), bk.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusConfirmed, got.Status)
+	assert.NotNil(t, got.ConfirmedAt)
+	assert.ErrorIs(t, store.UpdateStatus(context.Background(), 100, StatusConfirmed, ""), pgx.ErrNoRows)
+
+	// Новое время нужно подтвердить заново
+	assert.NoError(t, store.RescheduleBooking(context.Background(), bk.ID, start.Add(time.Hour), start.Add(2*time.Hour)))
+	got, err = store.GetBookingByID(context.Background(), bk.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusScheduled, got.Status)
+	assert.Nil(t, got.ConfirmedAt)
+
+	assert.NoError(t, store.UpdateStatus(context.Background(), bk.ID, StatusCancelledByClinic, "Врач заболел"))
+	got, err = store.GetBookingByID(context.Background(), bk.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCancelledByClinic, got.Status)
+	assert.Equal(t, "Врач заболел", got.StatusReason)
+
+	// Отмененную запись нельзя перенести или завершить, а ее время снова свободно
+	assert.ErrorIs(t, store.RescheduleBooking(context.Background(), bk.ID, start, start.Add(time.Hour)), pgx.ErrNoRows)
+	assert.ErrorIs(t, store.UpdateStatus(context.Background(), bk.ID, StatusCompleted, ""), ErrInvalidTransition)
+	assert.NoError(t, store.CreateBooking(context.Background(), &Booking{Datetime: start.Add(time.Hour), EndTime: start.Add(2 * time.Hour)}))
+}
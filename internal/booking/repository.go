@@ -2,8 +2,10 @@ package booking
 
 import (
 	"context"
+	"errors"
 	"time"
 
+	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/sirupsen/logrus"
@@ -13,6 +15,7 @@ type DBConnection interface {
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
 }
 
 type Repo struct {
@@ -23,21 +26,29 @@ func NewRepo(conn DBConnection) *Repo {
 	return &Repo{conn: conn}
 }
 
-func (r *Repo) CreateBooking(booking *Booking) error {
+func (r *Repo) CreateBooking(ctx context.Context, booking *Booking) error {
 	query := `
-	INSERT INTO bookings (user_id, name, contact, datetime, event_id)
-	VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO bookings (user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, patient_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	RETURNING id`
-	row := r.conn.QueryRow(context.Background(), query, booking.UserID, booking.Name, booking.Contact, booking.Datetime, booking.EventID)
+	row := r.conn.QueryRow(ctx, query, booking.UserID, booking.Name, booking.Contact, booking.Datetime, booking.EventID, booking.DoctorID, booking.EndTime, booking.ServiceID, booking.PatientID)
 	err := row.Scan(&booking.ID)
-	return err
+	if isSlotConflict(err) {
+		// Ограничение на пересечение приемов гарантирует, что время врача принадлежит только одному пациенту
+		return ErrSlotTaken
+	}
+	if err != nil {
+		return err
+	}
+	booking.Status = StatusScheduled
+	return nil
 }
 
-func (r *Repo) GetAllBooking() ([]Booking, error) {
+func (r *Repo) GetAllBooking(ctx context.Context) ([]Booking, error) {
 	var bookings []Booking
 	query := `
-		SELECT id, name, contact, datetime, event_id FROM bookings`
-	rows, err := r.conn.Query(context.Background(), query)
+		SELECT id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings`
+	rows, err := r.conn.Query(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -45,7 +56,7 @@ func (r *Repo) GetAllBooking() ([]Booking, error) {
 	for rows.Next() {
 		var bookingItem Booking
 		var eventID *string
-		if err := rows.Scan(&bookingItem.ID, &bookingItem.Name, &bookingItem.Contact, &bookingItem.Datetime, &eventID); err != nil {
+		if err := rows.Scan(&bookingItem.ID, &bookingItem.Name, &bookingItem.Contact, &bookingItem.Datetime, &eventID, &bookingItem.DoctorID, &bookingItem.EndTime, &bookingItem.ServiceID, &bookingItem.ConfirmedAt, &bookingItem.Status, &bookingItem.StatusReason, &bookingItem.StatusChangedAt, &bookingItem.PatientID); err != nil {
 			logrus.WithError(err).Error("Failed to scan row in GetAllBooking")
 			continue
 		}
@@ -55,17 +66,58 @@ func (r *Repo) GetAllBooking() ([]Booking, error) {
 	return bookings, rows.Err()
 }
 
-func (r *Repo) DeleteBookingByID(id int) error {
-	query := `DELETE FROM bookings WHERE id = $1`
-	_, err := r.conn.Exec(context.Background(), query, id)
-	return err
+// RescheduleBooking переносит предстоящую запись на новое время; подтверждение визита при этом сбрасывается.
+// Если новое время пересекается с другой записью того же врача, возвращается ErrSlotTaken,
+// если записи нет или она уже отменена или завершена - pgx.ErrNoRows
+func (r *Repo) RescheduleBooking(ctx context.Context, id int, start, end time.Time) error {
+	query := `
+	UPDATE bookings
+	SET datetime = $2, end_time = $3, confirmed_at = NULL, status = 'scheduled', status_changed_at = now()
+	WHERE id = $1 AND status IN ('scheduled', 'confirmed')`
+	tag, err := r.conn.Exec(ctx, query, id, start, end)
+	if isSlotConflict(err) {
+		return ErrSlotTaken
+	}
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateStatus переводит запись в новый статус с указанием причины (например, причины отмены).
+// Возвращает ErrInvalidTransition, если из текущего статуса такой переход недопустим,
+// и pgx.ErrNoRows, если записи нет
+func (r *Repo) UpdateStatus(ctx context.Context, id int, status Status, reason string) error {
+	query := `
+	UPDATE bookings
+	SET status = $2, status_reason = $3, status_changed_at = now(),
+		confirmed_at = CASE WHEN $2 = 'confirmed' THEN now() ELSE confirmed_at END
+	WHERE id = $1 AND status = ANY($4)`
+	tag, err := r.conn.Exec(ctx, query, id, string(status), reason, sourcesOf(status))
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil
+	}
+
+	// Запись не обновлена: либо ее нет, либо переход недопустим
+	var current Status
+	if err := r.conn.QueryRow(ctx, `SELECT status FROM bookings WHERE id = $1`, id).Scan(&current); err != nil {
+		return err
+	}
+	return ErrInvalidTransition
 }
 
-func (r *Repo) GetUserBookings(userID int64) ([]Booking, error) {
+// GetUserBookings возвращает записи пользователя в статусах statuses; nil - во всех статусах
+func (r *Repo) GetUserBookings(ctx context.Context, userID int64, statuses []Status) ([]Booking, error) {
 	var bookings []Booking
 	// Используем $1 вместо ?
-	query := "SELECT id, user_id, name, contact, datetime, event_id FROM bookings WHERE user_id = $1"
-	rows, err := r.conn.Query(context.Background(), query, userID)
+	query := "SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE user_id = $1 AND ($2::text[] IS NULL OR status = ANY($2)) ORDER BY datetime"
+	rows, err := r.conn.Query(ctx, query, userID, statusStrings(statuses))
 	if err != nil {
 		logrus.WithError(err).WithField("userID", userID).Error("Failed to query user bookings")
 		return nil, err
@@ -75,7 +127,7 @@ func (r *Repo) GetUserBookings(userID int64) ([]Booking, error) {
 	for rows.Next() {
 		var booking Booking
 		var eventID *string
-		if err := rows.Scan(&booking.ID, &booking.UserID, &booking.Name, &booking.Contact, &booking.Datetime, &eventID); err != nil {
+		if err := rows.Scan(&booking.ID, &booking.UserID, &booking.Name, &booking.Contact, &booking.Datetime, &eventID, &booking.DoctorID, &booking.EndTime, &booking.ServiceID, &booking.ConfirmedAt, &booking.Status, &booking.StatusReason, &booking.StatusChangedAt, &booking.PatientID); err != nil {
 			logrus.WithError(err).Error("Failed to scan row in GetUserBookings")
 			continue
 		}
@@ -91,11 +143,11 @@ func (r *Repo) GetUserBookings(userID int64) ([]Booking, error) {
 	return bookings, nil
 }
 
-func (r *Repo) GetBookingByID(id int) (*Booking, error) {
+func (r *Repo) GetBookingByID(ctx context.Context, id int) (*Booking, error) {
 	var booking Booking
-	query := "SELECT id, user_id, name, contact, datetime, event_id FROM bookings WHERE id = $1"
+	query := "SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE id = $1"
 	var eventID *string
-	err := r.conn.QueryRow(context.Background(), query, id).Scan(&booking.ID, &booking.UserID, &booking.Name, &booking.Contact, &booking.Datetime, &eventID)
+	err := r.conn.QueryRow(ctx, query, id).Scan(&booking.ID, &booking.UserID, &booking.Name, &booking.Contact, &booking.Datetime, &eventID, &booking.DoctorID, &booking.EndTime, &booking.ServiceID, &booking.ConfirmedAt, &booking.Status, &booking.StatusReason, &booking.StatusChangedAt, &booking.PatientID)
 	if err != nil {
 		return nil, err
 	}
@@ -103,10 +155,11 @@ func (r *Repo) GetBookingByID(id int) (*Booking, error) {
 	return &booking, nil
 }
 
-func (r *Repo) GetUpcomingBookings(from, to time.Time) ([]Booking, error) {
+// GetUpcomingBookings возвращает записи с началом в [from, to) в статусах statuses; nil - во всех статусах
+func (r *Repo) GetUpcomingBookings(ctx context.Context, from, to time.Time, statuses []Status) ([]Booking, error) {
 	var bookings []Booking
-	query := "SELECT id, user_id, name, contact, datetime, event_id FROM bookings WHERE datetime >= $1 AND datetime < $2 AND user_id IS NOT NULL"
-	rows, err := r.conn.Query(context.Background(), query, from, to)
+	query := "SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE datetime >= $1 AND datetime < $2 AND user_id IS NOT NULL AND ($3::text[] IS NULL OR status = ANY($3))"
+	rows, err := r.conn.Query(ctx, query, from, to, statusStrings(statuses))
 	if err != nil {
 		return nil, err
 	}
@@ -115,7 +168,7 @@ func (r *Repo) GetUpcomingBookings(from, to time.Time) ([]Booking, error) {
 	for rows.Next() {
 		var booking Booking
 		var eventID *string
-		if err := rows.Scan(&booking.ID, &booking.UserID, &booking.Name, &booking.Contact, &booking.Datetime, &eventID); err != nil {
+		if err := rows.Scan(&booking.ID, &booking.UserID, &booking.Name, &booking.Contact, &booking.Datetime, &eventID, &booking.DoctorID, &booking.EndTime, &booking.ServiceID, &booking.ConfirmedAt, &booking.Status, &booking.StatusReason, &booking.StatusChangedAt, &booking.PatientID); err != nil {
 			logrus.WithError(err).Error("Failed to scan row in GetUpcomingBookings")
 			continue
 		}
@@ -125,3 +178,101 @@ func (r *Repo) GetUpcomingBookings(from, to time.Time) ([]Booking, error) {
 
 	return bookings, rows.Err()
 }
+
+// HoldSlot временно закрепляет интервал [start, end) врача за пользователем на время ввода имени и телефона.
+// Предыдущее удержание пользователя заменяется новым. Если интервал пересекается с записью
+// или удерживается другим пользователем, возвращается ErrSlotTaken.
+// doctorID = 0 означает, что врачи в клинике не заведены. Запись excludeBookingID (переносимая)
+// не считается пересечением; 0 - учитываются все записи.
+func (r *Repo) HoldSlot(ctx context.Context, userID int64, doctorID int, start, end time.Time, ttl time.Duration, excludeBookingID int) error {
+	// Снятие старого удержания и новое удержание выполняются в одной транзакции:
+	// при занятом слоте пользователь сохраняет ранее выбранное время
+	tx, err := r.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx) // После Commit ничего не делает
+
+	// Пользователь может удерживать только один слот; заодно убираем истекшие удержания,
+	// чтобы они не мешали ограничению на пересечение
+	releaseQuery := `DELETE FROM slot_holds WHERE user_id = $1 OR expires_at < now()`
+	if _, err := tx.Exec(ctx, releaseQuery, userID); err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO slot_holds (doctor_id, slot_start, slot_end, user_id, expires_at)
+	SELECT $1, $2, $3, $4, now() + $5 * interval '1 second'
+	WHERE NOT EXISTS (
+		SELECT 1 FROM bookings
+		WHERE COALESCE(doctor_id, 0) = $1 AND tstzrange(datetime, end_time) && tstzrange($2, $3)
+			AND status IN ('scheduled', 'confirmed') AND id <> $6
+	)
+	RETURNING id`
+	var holdID int
+	err = tx.QueryRow(ctx, query, doctorID, start, end, userID, int64(ttl.Seconds()), excludeBookingID).Scan(&holdID)
+	if errors.Is(err, pgx.ErrNoRows) || isSlotConflict(err) {
+		return ErrSlotTaken
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// ReleaseHold снимает удержание слотов пользователем
+func (r *Repo) ReleaseHold(ctx context.Context, userID int64) error {
+	query := `DELETE FROM slot_holds WHERE user_id = $1`
+	_, err := r.conn.Exec(ctx, query, userID)
+	return err
+}
+
+// GetHeldSlots возвращает интервалы врача, пересекающиеся с [from, to) и удерживаемые другими пользователями
+func (r *Repo) GetHeldSlots(ctx context.Context, doctorID int, from, to time.Time, exceptUserID int64) ([]Hold, error) {
+	var holds []Hold
+	query := "SELECT slot_start, slot_end FROM slot_holds WHERE doctor_id = $1 AND slot_end > $2 AND slot_start < $3 AND user_id <> $4 AND expires_at > now()"
+	rows, err := r.conn.Query(ctx, query, doctorID, from, to, exceptUserID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hold Hold
+		if err := rows.Scan(&hold.Start, &hold.End); err != nil {
+			logrus.WithError(err).Error("Failed to scan row in GetHeldSlots")
+			continue
+		}
+		holds = append(holds, hold)
+	}
+
+	return holds, rows.Err()
+}
+
+// ClaimReminder отмечает напоминание о приеме в visit за offset до него как отправленное.
+// Возвращает false, если оно уже было отмечено раньше (в том числе до перезапуска бота)
+func (r *Repo) ClaimReminder(ctx context.Context, bookingID int, offset time.Duration, visit time.Time) (bool, error) {
+	query := `
+	INSERT INTO reminders_sent (booking_id, offset_minutes, visit_time)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+	tag, err := r.conn.Exec(ctx, query, bookingID, int(offset.Minutes()), visit)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseReminder снимает отметку, если напоминание не удалось доставить, чтобы отправить его повторно
+func (r *Repo) ReleaseReminder(ctx context.Context, bookingID int, offset time.Duration, visit time.Time) error {
+	query := `DELETE FROM reminders_sent WHERE booking_id = $1 AND offset_minutes = $2 AND visit_time = $3`
+	_, err := r.conn.Exec(ctx, query, bookingID, int(offset.Minutes()), visit)
+	return err
+}
+
+// isSlotConflict сообщает, что запись или удержание нарушили ограничение на пересечение интервалов
+func isSlotConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) &&
+		(pgErr.Code == pgerrcode.ExclusionViolation || pgErr.Code == pgerrcode.UniqueViolation)
+}
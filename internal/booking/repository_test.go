@@ -3,8 +3,12 @@ package booking
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/pashagolub/pgxmock/v2"
 	"github.com/stretchr/testify/assert"
 )
@@ -26,10 +30,10 @@ func TestBookingRepo_CreateBooking(t *testing.T) {
 	}
 
 	mock.ExpectQuery(`INSERT INTO bookings`).
-		WithArgs(booking.UserID, booking.Name, booking.Contact, booking.Datetime, booking.EventID).
+		WithArgs(booking.UserID, booking.Name, booking.Contact, booking.Datetime, booking.EventID, booking.DoctorID, booking.EndTime, booking.ServiceID, booking.PatientID).
 		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(int(gofakeit.Int64())))
 
-	err = repo.CreateBooking(booking)
+	err = repo.CreateBooking(context.Background(), booking)
 	assert.NoError(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -44,37 +48,80 @@ func TestBookingRepo_GetBookingByID(t *testing.T) {
 
 	bookingID := int(gofakeit.Int64())
 	eventID := gofakeit.UUID()
+	patientID := gofakeit.Number(1, 1000)
 
-	rows := pgxmock.NewRows([]string{"id", "user_id", "name", "contact", "datetime", "event_id"}).
-		AddRow(bookingID, gofakeit.Int64(), gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID)
+	rows := pgxmock.NewRows([]string{"id", "user_id", "name", "contact", "datetime", "event_id", "doctor_id", "end_time", "service_id", "confirmed_at", "status", "status_reason", "status_changed_at", "patient_id"}).
+		AddRow(bookingID, gofakeit.Int64(), gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID, nil, gofakeit.Date(), nil, nil, StatusScheduled, "", gofakeit.Date(), &patientID)
 
-	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id FROM bookings WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE id = \$1`).
 		WithArgs(bookingID).
 		WillReturnRows(rows)
 
-	booking, err := repo.GetBookingByID(bookingID)
+	booking, err := repo.GetBookingByID(context.Background(), bookingID)
 	assert.NoError(t, err)
 	assert.NotNil(t, booking)
 	assert.Equal(t, bookingID, booking.ID)
+	assert.Equal(t, &patientID, booking.PatientID)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestBookingRepo_DeleteBookingById(t *testing.T) {
+func TestBookingRepo_RescheduleBooking(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	assert.NoError(t, err)
 	defer mock.Close(context.Background())
 
 	repo := NewRepo(mock)
+	bookingID := gofakeit.Number(1, 1000)
+	start := gofakeit.Date()
+	end := start.Add(30 * time.Minute)
 
-	bookingID := int(gofakeit.Int64())
+	mock.ExpectExec(`UPDATE bookings SET datetime = \$2, end_time = \$3, confirmed_at = NULL, status = 'scheduled', status_changed_at = now\(\) WHERE id = \$1 AND status IN \('scheduled', 'confirmed'\)`).
+		WithArgs(bookingID, start, end).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
 
-	mock.ExpectExec(`DELETE FROM bookings WHERE id = \$1`).
-		WithArgs(bookingID).
-		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	err = repo.RescheduleBooking(context.Background(), bookingID, start, end)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
 
-	err = repo.DeleteBookingByID(bookingID)
+func TestBookingRepo_RescheduleBooking_SlotTaken(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	bookingID := gofakeit.Number(1, 1000)
+	start := gofakeit.Date()
+	end := start.Add(time.Hour)
+
+	mock.ExpectExec(`UPDATE bookings`).
+		WithArgs(bookingID, start, end).
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.ExclusionViolation})
+
+	err = repo.RescheduleBooking(context.Background(), bookingID, start, end)
+	assert.ErrorIs(t, err, ErrSlotTaken)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_RescheduleBooking_NotFound(t *testing.T) {
+	mock, err := pgxmock.NewConn()
 	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	bookingID := gofakeit.Number(1, 1000)
+	start := gofakeit.Date()
+	end := start.Add(time.Hour)
+
+	mock.ExpectExec(`UPDATE bookings`).
+		WithArgs(bookingID, start, end).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+
+	err = repo.RescheduleBooking(context.Background(), bookingID, start, end)
+	assert.ErrorIs(t, err, pgx.ErrNoRows)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -90,15 +137,15 @@ func TestBookingRepo_GetUserBookings(t *testing.T) {
 	eventID1 := gofakeit.UUID()
 	eventID2 := gofakeit.UUID()
 
-	rows := pgxmock.NewRows([]string{"id", "user_id", "name", "contact", "datetime", "event_id"}).
-		AddRow(int(gofakeit.Int64()), userID, gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID1).
-		AddRow(int(gofakeit.Int64()), userID, gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID2)
+	rows := pgxmock.NewRows([]string{"id", "user_id", "name", "contact", "datetime", "event_id", "doctor_id", "end_time", "service_id", "confirmed_at", "status", "status_reason", "status_changed_at", "patient_id"}).
+		AddRow(int(gofakeit.Int64()), userID, gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID1, nil, gofakeit.Date(), nil, nil, StatusScheduled, "", gofakeit.Date(), nil).
+		AddRow(int(gofakeit.Int64()), userID, gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID2, nil, gofakeit.Date(), nil, nil, StatusScheduled, "", gofakeit.Date(), nil)
 
-	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id FROM bookings WHERE user_id = \$1`).
-		WithArgs(userID).
+	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE user_id = \$1`).
+		WithArgs(userID, []string{"scheduled", "confirmed"}).
 		WillReturnRows(rows)
 
-	bookings, err := repo.GetUserBookings(userID)
+	bookings, err := repo.GetUserBookings(context.Background(), userID, ActiveStatuses)
 	assert.NoError(t, err)
 	assert.NotNil(t, bookings)
 	assert.Len(t, bookings, 2)
@@ -116,14 +163,14 @@ func TestBookingRepo_GetAllBooking(t *testing.T) {
 	eventID1 := gofakeit.UUID()
 	eventID2 := gofakeit.UUID()
 
-	rows := pgxmock.NewRows([]string{"id", "name", "contact", "datetime", "event_id"}).
-		AddRow(int(gofakeit.Int64()), gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID1).
-		AddRow(int(gofakeit.Int64()), gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID2)
+	rows := pgxmock.NewRows([]string{"id", "name", "contact", "datetime", "event_id", "doctor_id", "end_time", "service_id", "confirmed_at", "status", "status_reason", "status_changed_at", "patient_id"}).
+		AddRow(int(gofakeit.Int64()), gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID1, nil, gofakeit.Date(), nil, nil, StatusScheduled, "", gofakeit.Date(), nil).
+		AddRow(int(gofakeit.Int64()), gofakeit.Name(), gofakeit.Phone(), gofakeit.Date(), &eventID2, nil, gofakeit.Date(), nil, nil, StatusScheduled, "", gofakeit.Date(), nil)
 
-	mock.ExpectQuery(`SELECT id, name, contact, datetime, event_id FROM bookings`).
+	mock.ExpectQuery(`SELECT id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings`).
 		WillReturnRows(rows)
 
-	bookings, err := repo.GetAllBooking()
+	bookings, err := repo.GetAllBooking(context.Background())
 	assert.NoError(t, err)
 	assert.NotNil(t, bookings)
 	assert.Len(t, bookings, 2)
@@ -148,10 +195,10 @@ func TestBookingRepo_CreateBooking_Error(t *testing.T) {
 	}
 
 	mock.ExpectQuery(`INSERT INTO bookings`).
-		WithArgs(booking.UserID, booking.Name, booking.Contact, booking.Datetime, booking.EventID).
+		WithArgs(booking.UserID, booking.Name, booking.Contact, booking.Datetime, booking.EventID, booking.DoctorID, booking.EndTime, booking.ServiceID, booking.PatientID).
 		WillReturnError(assert.AnError)
 
-	err = repo.CreateBooking(booking)
+	err = repo.CreateBooking(context.Background(), booking)
 	assert.Error(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -165,11 +212,11 @@ func TestBookingRepo_GetUserBookings_Error(t *testing.T) {
 	repo := NewRepo(mock)
 	userID := gofakeit.Int64()
 
-	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id FROM bookings WHERE user_id = \$1`).
-		WithArgs(userID).
+	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE user_id = \$1`).
+		WithArgs(userID, []string(nil)).
 		WillReturnError(assert.AnError)
 
-	_, err = repo.GetUserBookings(userID)
+	_, err = repo.GetUserBookings(context.Background(), userID, nil)
 	assert.Error(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
@@ -183,30 +230,246 @@ func TestBookingRepo_GetBookingByID_Error(t *testing.T) {
 	repo := NewRepo(mock)
 	bookingID := int(gofakeit.Int64())
 
-	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id FROM bookings WHERE id = \$1`).
+	mock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE id = \$1`).
 		WithArgs(bookingID).
 		WillReturnError(assert.AnError)
 
-	_, err = repo.GetBookingByID(bookingID)
+	_, err = repo.GetBookingByID(context.Background(), bookingID)
 	assert.Error(t, err)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
-func TestBookingRepo_DeleteBookingById_Error(t *testing.T) {
+func TestBookingRepo_CreateBooking_SlotTaken(t *testing.T) {
 	mock, err := pgxmock.NewConn()
 	assert.NoError(t, err)
 	defer mock.Close(context.Background())
 
 	repo := NewRepo(mock)
-	bookingID := int(gofakeit.Int64())
 
-	mock.ExpectExec(`DELETE FROM bookings WHERE id = \$1`).
+	booking := &Booking{
+		UserID:   gofakeit.Int64(),
+		Name:     gofakeit.Name(),
+		Contact:  gofakeit.Phone(),
+		Datetime: gofakeit.Date(),
+	}
+	booking.EndTime = booking.Datetime.Add(30 * time.Minute)
+
+	mock.ExpectQuery(`INSERT INTO bookings`).
+		WithArgs(booking.UserID, booking.Name, booking.Contact, booking.Datetime, booking.EventID, booking.DoctorID, booking.EndTime, booking.ServiceID, booking.PatientID).
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.ExclusionViolation})
+
+	err = repo.CreateBooking(context.Background(), booking)
+	assert.ErrorIs(t, err, ErrSlotTaken)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_HoldSlot(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	userID := gofakeit.Int64()
+	doctorID := gofakeit.Number(1, 100)
+	start := gofakeit.Date()
+	end := start.Add(90 * time.Minute)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM slot_holds WHERE user_id = \$1 OR expires_at < now\(\)`).
+		WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectQuery(`INSERT INTO slot_holds`).
+		WithArgs(doctorID, start, end, userID, int64(600), 0).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectCommit()
+
+	err = repo.HoldSlot(context.Background(), userID, doctorID, start, end, 10*time.Minute, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_HoldSlot_Taken(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	userID := gofakeit.Int64()
+	doctorID := gofakeit.Number(1, 100)
+	start := gofakeit.Date()
+	end := start.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM slot_holds`).
+		WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	mock.ExpectQuery(`INSERT INTO slot_holds`).
+		WithArgs(doctorID, start, end, userID, int64(600), 0).
+		WillReturnError(pgx.ErrNoRows)
+	// Слот занят - предыдущее удержание пользователя не снимается
+	mock.ExpectRollback()
+
+	err = repo.HoldSlot(context.Background(), userID, doctorID, start, end, 10*time.Minute, 0)
+	assert.ErrorIs(t, err, ErrSlotTaken)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_HoldSlot_Overlap(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	userID := gofakeit.Int64()
+	doctorID := gofakeit.Number(1, 100)
+	start := gofakeit.Date()
+	end := start.Add(time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM slot_holds`).
+		WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	// Другой пользователь удерживает пересекающийся интервал
+	mock.ExpectQuery(`INSERT INTO slot_holds`).
+		WithArgs(doctorID, start, end, userID, int64(600), 0).
+		WillReturnError(&pgconn.PgError{Code: pgerrcode.ExclusionViolation})
+	mock.ExpectRollback()
+
+	err = repo.HoldSlot(context.Background(), userID, doctorID, start, end, 10*time.Minute, 0)
+	assert.ErrorIs(t, err, ErrSlotTaken)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_ReleaseHold(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	userID := gofakeit.Int64()
+
+	mock.ExpectExec(`DELETE FROM slot_holds WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	err = repo.ReleaseHold(context.Background(), userID)
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_GetHeldSlots(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	userID := gofakeit.Int64()
+	doctorID := gofakeit.Number(1, 100)
+	from := gofakeit.Date()
+	to := from.Add(24 * time.Hour)
+
+	mock.ExpectQuery(`SELECT slot_start, slot_end FROM slot_holds`).
+		WithArgs(doctorID, from, to, userID).
+		WillReturnRows(pgxmock.NewRows([]string{"slot_start", "slot_end"}).
+			AddRow(from, from.Add(30*time.Minute)).
+			AddRow(from.Add(time.Hour), from.Add(150*time.Minute)))
+
+	holds, err := repo.GetHeldSlots(context.Background(), doctorID, from, to, userID)
+	assert.NoError(t, err)
+	assert.Len(t, holds, 2)
+	assert.Equal(t, from.Add(150*time.Minute), holds[1].End)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_ClaimReminder(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	bookingID := gofakeit.Number(1, 100)
+	visit := gofakeit.Date()
+
+	mock.ExpectExec(`INSERT INTO reminders_sent .* ON CONFLICT DO NOTHING`).
+		WithArgs(bookingID, 120, visit).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	claimed, err := repo.ClaimReminder(context.Background(), bookingID, 2*time.Hour, visit)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+
+	// Напоминание уже отправлено
+	mock.ExpectExec(`INSERT INTO reminders_sent`).
+		WithArgs(bookingID, 120, visit).
+		WillReturnResult(pgxmock.NewResult("INSERT", 0))
+	claimed, err = repo.ClaimReminder(context.Background(), bookingID, 2*time.Hour, visit)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+
+	mock.ExpectExec(`DELETE FROM reminders_sent WHERE booking_id = \$1 AND offset_minutes = \$2 AND visit_time = \$3`).
+		WithArgs(bookingID, 120, visit).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	assert.NoError(t, repo.ReleaseReminder(context.Background(), bookingID, 2*time.Hour, visit))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_UpdateStatus(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	bookingID := gofakeit.Number(1, 100)
+
+	mock.ExpectExec(`UPDATE bookings SET status = \$2, status_reason = \$3`).
+		WithArgs(bookingID, "cancelled_by_clinic", "Врач заболел", []string{"scheduled", "confirmed"}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	assert.NoError(t, repo.UpdateStatus(context.Background(), bookingID, StatusCancelledByClinic, "Врач заболел"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_UpdateStatus_InvalidTransition(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	bookingID := gofakeit.Number(1, 100)
+
+	mock.ExpectExec(`UPDATE bookings`).
+		WithArgs(bookingID, "confirmed", "", []string{"scheduled"}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectQuery(`SELECT status FROM bookings WHERE id = \$1`).
 		WithArgs(bookingID).
-		WillReturnError(assert.AnError)
+		WillReturnRows(pgxmock.NewRows([]string{"status"}).AddRow(StatusCancelledByPatient))
+	assert.ErrorIs(t, repo.UpdateStatus(context.Background(), bookingID, StatusConfirmed, ""), ErrInvalidTransition)
 
-	err = repo.DeleteBookingByID(bookingID)
-	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBookingRepo_UpdateStatus_NotFound(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	bookingID := gofakeit.Number(1, 100)
+
+	mock.ExpectExec(`UPDATE bookings`).
+		WithArgs(bookingID, "completed", "", []string{"scheduled", "confirmed"}).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	mock.ExpectQuery(`SELECT status FROM bookings WHERE id = \$1`).
+		WithArgs(bookingID).
+		WillReturnError(pgx.ErrNoRows)
+	assert.ErrorIs(t, repo.UpdateStatus(context.Background(), bookingID, StatusCompleted, ""), pgx.ErrNoRows)
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -0,0 +1,76 @@
+package booking
+
+import "errors"
+
+// Status - этап жизненного цикла записи
+type Status string
+
+const (
+	StatusScheduled          Status = "scheduled"            // Запись создана
+	StatusConfirmed          Status = "confirmed"            // Пациент подтвердил визит
+	StatusCancelledByPatient Status = "cancelled_by_patient" // Пациент отменил запись
+	StatusCancelledByClinic  Status = "cancelled_by_clinic"  // Клиника отменила запись
+	StatusCompleted          Status = "completed"            // Прием состоялся
+	StatusNoShow             Status = "no_show"              // Пациент не пришел
+)
+
+// allStatuses перечисляет статусы в порядке жизненного цикла
+var allStatuses = []Status{
+	StatusScheduled, StatusConfirmed, StatusCancelledByPatient, StatusCancelledByClinic, StatusCompleted, StatusNoShow,
+}
+
+// ActiveStatuses - статусы записей, которые занимают время врача и видны пациенту
+var ActiveStatuses = []Status{StatusScheduled, StatusConfirmed}
+
+// ErrInvalidTransition возвращается при недопустимой смене статуса, например при отмене состоявшегося приема
+var ErrInvalidTransition = errors.New("invalid booking status transition")
+
+// transitions перечисляет, в какие статусы может перейти запись; отмененные,
+// состоявшиеся и пропущенные приемы больше не меняются
+var transitions = map[Status][]Status{
+	StatusScheduled: {StatusConfirmed, StatusCancelledByPatient, StatusCancelledByClinic, StatusCompleted, StatusNoShow},
+	StatusConfirmed: {StatusCancelledByPatient, StatusCancelledByClinic, StatusCompleted, StatusNoShow},
+}
+
+// Active сообщает, что запись еще предстоит: она занимает время врача и ее можно перенести или отменить
+func (s Status) Active() bool {
+	for _, active := range ActiveStatuses {
+		if s == active {
+			return true
+		}
+	}
+	return false
+}
+
+// CanTransitionTo сообщает, допустим ли переход из статуса s в статус to
+func (s Status) CanTransitionTo(to Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// sourcesOf возвращает статусы, из которых допустим переход в to
+func sourcesOf(to Status) []string {
+	var sources []string
+	for _, from := range allStatuses {
+		if from.CanTransitionTo(to) {
+			sources = append(sources, string(from))
+		}
+	}
+	return sources
+}
+
+// statusStrings преобразует фильтр по статусам в параметр запроса; nil - без фильтра
+func statusStrings(statuses []Status) []string {
+	if statuses == nil {
+		return nil
+	}
+	result := make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		result = append(result, string(s))
+	}
+	return result
+}
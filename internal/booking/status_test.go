@@ -0,0 +1,30 @@
+package booking
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus_CanTransitionTo(t *testing.T) {
+	assert.True(t, StatusScheduled.CanTransitionTo(StatusConfirmed))
+	assert.True(t, StatusConfirmed.CanTransitionTo(StatusCompleted))
+	assert.True(t, StatusConfirmed.CanTransitionTo(StatusCancelledByPatient))
+	assert.False(t, StatusConfirmed.CanTransitionTo(StatusConfirmed))
+	assert.False(t, StatusCancelledByClinic.CanTransitionTo(StatusScheduled))
+	assert.False(t, StatusCompleted.CanTransitionTo(StatusNoShow))
+	assert.False(t, StatusNoShow.CanTransitionTo(StatusCompleted))
+}
+
+func TestStatus_Active(t *testing.T) {
+	assert.True(t, StatusScheduled.Active())
+	assert.True(t, StatusConfirmed.Active())
+	assert.False(t, StatusCancelledByPatient.Active())
+	assert.False(t, StatusCompleted.Active())
+}
+
+func TestSourcesOf(t *testing.T) {
+	assert.Equal(t, []string{"scheduled"}, sourcesOf(StatusConfirmed))
+	assert.Equal(t, []string{"scheduled", "confirmed"}, sourcesOf(StatusNoShow))
+	assert.Nil(t, sourcesOf(StatusScheduled))
+}
@@ -0,0 +1,54 @@
+package catalog
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+type DBConnection interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type Repo struct {
+	conn DBConnection
+}
+
+func NewRepo(conn DBConnection) *Repo {
+	return &Repo{conn: conn}
+}
+
+// GetActiveServices возвращает услуги, на которые открыта запись
+func (r *Repo) GetActiveServices(ctx context.Context) ([]Service, error) {
+	var services []Service
+	query := "SELECT id, name, duration_minutes, price, description, active FROM services WHERE active ORDER BY name"
+	rows, err := r.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var s Service
+		if err := rows.Scan(&s.ID, &s.Name, &s.DurationMinutes, &s.Price, &s.Description, &s.Active); err != nil {
+			logrus.WithError(err).Error("Failed to scan row in GetActiveServices")
+			continue
+		}
+		services = append(services, s)
+	}
+
+	return services, rows.Err()
+}
+
+func (r *Repo) GetServiceByID(ctx context.Context, id int) (*Service, error) {
+	var s Service
+	query := "SELECT id, name, duration_minutes, price, description, active FROM services WHERE id = $1"
+	err := r.conn.QueryRow(ctx, query, id).
+		Scan(&s.ID, &s.Name, &s.DurationMinutes, &s.Price, &s.Description, &s.Active)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
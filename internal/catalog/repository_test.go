@@ -0,0 +1,80 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var serviceColumns = []string{"id", "name", "duration_minutes", "price", "description", "active"}
+
+func TestCatalogRepo_GetActiveServices(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+
+	rows := pgxmock.NewRows(serviceColumns).
+		AddRow(1, "Осмотр", 30, 1000, gofakeit.Sentence(), true).
+		AddRow(2, "Консультация по имплантации", 90, 3000, gofakeit.Sentence(), true)
+
+	mock.ExpectQuery(`SELECT id, name, duration_minutes, price, description, active FROM services WHERE active`).
+		WillReturnRows(rows)
+
+	services, err := repo.GetActiveServices(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, services, 2)
+	assert.Equal(t, 90*time.Minute, services[1].Duration())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatalogRepo_GetServiceByID(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	serviceID := gofakeit.Number(1, 1000)
+
+	mock.ExpectQuery(`SELECT id, name, duration_minutes, price, description, active FROM services WHERE id = \$1`).
+		WithArgs(serviceID).
+		WillReturnRows(pgxmock.NewRows(serviceColumns).AddRow(serviceID, "Осмотр", 30, 1000, "", true))
+
+	service, err := repo.GetServiceByID(context.Background(), serviceID)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, service.Duration())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCatalogRepo_GetServiceByID_Error(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	serviceID := gofakeit.Number(1, 1000)
+
+	mock.ExpectQuery(`SELECT .* FROM services WHERE id = \$1`).
+		WithArgs(serviceID).
+		WillReturnError(assert.AnError)
+
+	_, err = repo.GetServiceByID(context.Background(), serviceID)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestService_Title(t *testing.T) {
+	s := Service{Name: "Осмотр", DurationMinutes: 30, Price: 1000}
+	assert.Equal(t, "Осмотр — 30 мин, 1000 ₽", s.Title())
+
+	s.Price = 0
+	assert.Equal(t, "Осмотр — 30 мин", s.Title())
+}
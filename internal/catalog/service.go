@@ -0,0 +1,29 @@
+package catalog
+
+import (
+	"fmt"
+	"time"
+)
+
+// Service - услуга клиники с длительностью приема
+type Service struct {
+	ID              int    `db:"id"`
+	Name            string `db:"name"`
+	DurationMinutes int    `db:"duration_minutes"`
+	Price           int    `db:"price"` // Стоимость в рублях
+	Description     string `db:"description"`
+	Active          bool   `db:"active"`
+}
+
+// Duration возвращает длительность приема
+func (s *Service) Duration() time.Duration {
+	return time.Duration(s.DurationMinutes) * time.Minute
+}
+
+// Title возвращает название услуги с длительностью и ценой для кнопки выбора
+func (s *Service) Title() string {
+	if s.Price > 0 {
+		return fmt.Sprintf("%s — %d мин, %d ₽", s.Name, s.DurationMinutes, s.Price)
+	}
+	return fmt.Sprintf("%s — %d мин", s.Name, s.DurationMinutes)
+}
@@ -0,0 +1,36 @@
+package doctor
+
+import (
+	"fmt"
+	"stomatology_bot/internal/schedule"
+)
+
+// Doctor - врач клиники со своим календарем и расписанием
+type Doctor struct {
+	ID         int    `db:"id"`
+	Name       string `db:"name"`
+	Specialty  string `db:"specialty"`
+	CalendarID string `db:"calendar_id"`
+	Schedule   string `db:"schedule"` // Пустая строка - врач работает по расписанию клиники
+	Active     bool   `db:"active"`
+}
+
+// Title возвращает имя врача вместе со специальностью для отображения пациенту
+func (d *Doctor) Title() string {
+	if d.Specialty == "" {
+		return d.Name
+	}
+	return d.Name + " (" + d.Specialty + ")"
+}
+
+// Week возвращает расписание врача; если оно не задано, используется расписание клиники
+func (d *Doctor) Week(clinic schedule.Week) (schedule.Week, error) {
+	if d.Schedule == "" {
+		return clinic, nil
+	}
+	week, err := schedule.Parse(d.Schedule)
+	if err != nil {
+		return schedule.Week{}, fmt.Errorf("invalid schedule of doctor %d: %v", d.ID, err)
+	}
+	return week, nil
+}
@@ -0,0 +1,54 @@
+package doctor
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+type DBConnection interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+type Repo struct {
+	conn DBConnection
+}
+
+func NewRepo(conn DBConnection) *Repo {
+	return &Repo{conn: conn}
+}
+
+// GetActiveDoctors возвращает врачей, к которым открыта запись
+func (r *Repo) GetActiveDoctors(ctx context.Context) ([]Doctor, error) {
+	var doctors []Doctor
+	query := "SELECT id, name, specialty, calendar_id, schedule, active FROM doctors WHERE active ORDER BY name"
+	rows, err := r.conn.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d Doctor
+		if err := rows.Scan(&d.ID, &d.Name, &d.Specialty, &d.CalendarID, &d.Schedule, &d.Active); err != nil {
+			logrus.WithError(err).Error("Failed to scan row in GetActiveDoctors")
+			continue
+		}
+		doctors = append(doctors, d)
+	}
+
+	return doctors, rows.Err()
+}
+
+func (r *Repo) GetDoctorByID(ctx context.Context, id int) (*Doctor, error) {
+	var d Doctor
+	query := "SELECT id, name, specialty, calendar_id, schedule, active FROM doctors WHERE id = $1"
+	err := r.conn.QueryRow(ctx, query, id).
+		Scan(&d.ID, &d.Name, &d.Specialty, &d.CalendarID, &d.Schedule, &d.Active)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
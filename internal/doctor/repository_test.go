@@ -0,0 +1,102 @@
+package doctor
+
+import (
+	"context"
+	"stomatology_bot/internal/schedule"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var doctorColumns = []string{"id", "name", "specialty", "calendar_id", "schedule", "active"}
+
+func TestDoctorRepo_GetActiveDoctors(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+
+	rows := pgxmock.NewRows(doctorColumns).
+		AddRow(1, gofakeit.Name(), "Терапевт", gofakeit.Email(), "", true).
+		AddRow(2, gofakeit.Name(), "Хирург", gofakeit.Email(), "mon-fri 10:00-16:00", true)
+
+	mock.ExpectQuery(`SELECT id, name, specialty, calendar_id, schedule, active FROM doctors WHERE active`).
+		WillReturnRows(rows)
+
+	doctors, err := repo.GetActiveDoctors(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, doctors, 2)
+	assert.Equal(t, "Хирург", doctors[1].Specialty)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDoctorRepo_GetDoctorByID(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	doctorID := gofakeit.Number(1, 1000)
+	calendarID := gofakeit.Email()
+
+	rows := pgxmock.NewRows(doctorColumns).
+		AddRow(doctorID, gofakeit.Name(), "Ортодонт", calendarID, "", true)
+
+	mock.ExpectQuery(`SELECT id, name, specialty, calendar_id, schedule, active FROM doctors WHERE id = \$1`).
+		WithArgs(doctorID).
+		WillReturnRows(rows)
+
+	d, err := repo.GetDoctorByID(context.Background(), doctorID)
+	assert.NoError(t, err)
+	assert.Equal(t, calendarID, d.CalendarID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDoctorRepo_GetDoctorByID_Error(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	doctorID := gofakeit.Number(1, 1000)
+
+	mock.ExpectQuery(`SELECT .* FROM doctors WHERE id = \$1`).
+		WithArgs(doctorID).
+		WillReturnError(assert.AnError)
+
+	_, err = repo.GetDoctorByID(context.Background(), doctorID)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDoctor_Title(t *testing.T) {
+	d := Doctor{Name: "Иванова Мария", Specialty: "Терапевт"}
+	assert.Equal(t, "Иванова Мария (Терапевт)", d.Title())
+
+	d.Specialty = ""
+	assert.Equal(t, "Иванова Мария", d.Title())
+}
+
+func TestDoctor_Week(t *testing.T) {
+	clinic := schedule.Default(9, 18)
+
+	d := Doctor{ID: 1}
+	week, err := d.Week(clinic)
+	assert.NoError(t, err)
+	assert.Equal(t, clinic, week)
+
+	d.Schedule = "tue,thu 14:00-20:00"
+	week, err = d.Week(clinic)
+	assert.NoError(t, err)
+	assert.Equal(t, "tue 14:00-20:00; thu 14:00-20:00", week.String())
+
+	d.Schedule = "tue 20:00-14:00"
+	_, err = d.Week(clinic)
+	assert.Error(t, err)
+}
@@ -0,0 +1,351 @@
+// Package i18n содержит шаблоны сообщений бота на поддерживаемых языках.
+// Встроенные шаблоны проверяются при запуске; оператор может переопределить их файлами
+// в TEMPLATES_DIR без пересборки бота (см. Load и Watch)
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultLanguage - язык клиники: на нем пишутся сообщения, если язык пациента неизвестен
+	DefaultLanguage = "ru"
+	// FallbackLanguage - язык для пациентов, чей язык Telegram не поддерживается
+	FallbackLanguage = "en"
+	// staffPrefix - сообщения администраторам и тексты событий календаря; они пишутся на языке клиники,
+	// поэтому переводить их на другие языки не обязательно
+	staffPrefix = "admin."
+)
+
+// Languages - поддерживаемые языки в порядке показа в меню выбора
+var Languages = []string{"ru", "en", "kk"}
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// builtin - встроенные шаблоны; ошибка в них - ошибка сборки, а не данных.
+// Они же задают допустимые переменные шаблонов и используются, если правка оператора не сработала
+var builtin = mustLoadBuiltin()
+
+// current - действующие шаблоны: встроенные с правками оператора
+var current atomic.Pointer[catalog]
+
+func init() {
+	current.Store(&builtin)
+}
+
+// Vars - переменные шаблона сообщения, например {{.Name}} или {{.Datetime}}
+type Vars map[string]interface{}
+
+// Locale - шаблоны сообщений одного языка
+type Locale struct {
+	Name           string                       `json:"name"`            // Название языка для меню выбора
+	DateLayout     string                       `json:"date_layout"`     // Дата, например на кнопках выбора дня
+	DateTimeLayout string                       `json:"datetime_layout"` // Дата и время приема
+	DayLayout      string                       `json:"day_layout"`      // День без года
+	TimeLayout     string                       `json:"time_layout"`     // Время без даты, например на кнопках выбора времени
+	Messages       map[string]string            `json:"messages"`
+	Plurals        map[string]map[string]string `json:"plurals"` // Ключ -> форма (one, few, many, other) -> текст
+
+	messages map[string]*template.Template
+	plurals  map[string]map[string]*template.Template
+}
+
+type catalog map[string]*Locale
+
+// Localizer форматирует сообщения на языке пациента
+type Localizer struct {
+	language string
+	locale   *Locale
+}
+
+// For возвращает Localizer для языка; неподдерживаемый язык заменяется языком клиники
+func For(language string) *Localizer {
+	locales := *current.Load()
+	locale, ok := locales[language]
+	if !ok {
+		language, locale = DefaultLanguage, locales[DefaultLanguage]
+	}
+	return &Localizer{language: language, locale: locale}
+}
+
+// Default возвращает Localizer языка клиники
+func Default() *Localizer {
+	return For(DefaultLanguage)
+}
+
+// Match подбирает поддерживаемый язык по коду языка Telegram ("en-US", "kk");
+// для пустого кода - язык клиники, для неподдерживаемого - FallbackLanguage
+func Match(code string) string {
+	if code == "" {
+		return DefaultLanguage
+	}
+	base, _, _ := strings.Cut(strings.ToLower(code), "-")
+	if Supported(base) {
+		return base
+	}
+	return FallbackLanguage
+}
+
+// Supported сообщает, есть ли шаблоны для языка
+func Supported(language string) bool {
+	_, ok := builtin[language]
+	return ok
+}
+
+// Language возвращает код языка
+func (l *Localizer) Language() string {
+	return l.language
+}
+
+// Name возвращает название языка на нем самом
+func (l *Localizer) Name() string {
+	return l.locale.Name
+}
+
+// T возвращает сообщение по ключу; vars - необязательные переменные шаблона.
+// Если шаблона нет в каталоге языка или его не удалось выполнить, используется встроенный шаблон языка клиники
+func (l *Localizer) T(key string, vars ...Vars) string {
+	var data Vars
+	if len(vars) > 0 {
+		data = vars[0]
+	}
+	if text, ok := render(l.locale.messages[key], data); ok {
+		return text
+	}
+	if text, ok := render(builtin[DefaultLanguage].messages[key], data); ok {
+		return text
+	}
+	return key
+}
+
+// N возвращает сообщение в форме множественного числа для n; n доступно в шаблоне как {{.Count}}
+func (l *Localizer) N(key string, n int, vars ...Vars) string {
+	data := Vars{"Count": n}
+	if len(vars) > 0 {
+		for name, value := range vars[0] {
+			data[name] = value
+		}
+	}
+	if text, ok := render(pluralForm(l.locale.plurals[key], pluralRules[l.language](n)), data); ok {
+		return text
+	}
+	if text, ok := render(pluralForm(builtin[DefaultLanguage].plurals[key], pluralRules[DefaultLanguage](n)), data); ok {
+		return text
+	}
+	return key
+}
+
+// Date форматирует дату
+func (l *Localizer) Date(t time.Time) string {
+	return t.Format(l.locale.DateLayout)
+}
+
+// DateTime форматирует дату и время приема
+func (l *Localizer) DateTime(t time.Time) string {
+	return t.Format(l.locale.DateTimeLayout)
+}
+
+// Time форматирует время без даты
+func (l *Localizer) Time(t time.Time) string {
+	return t.Format(l.locale.TimeLayout)
+}
+
+// Day форматирует день приема без года
+func (l *Localizer) Day(t time.Time) string {
+	return t.Format(l.locale.DayLayout)
+}
+
+type contextKey struct{}
+
+// WithLocalizer сохраняет язык пациента в контексте обработки обновления
+func WithLocalizer(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext возвращает язык пациента из контекста; если он не задан - язык клиники
+func FromContext(ctx context.Context) *Localizer {
+	if l, ok := ctx.Value(contextKey{}).(*Localizer); ok {
+		return l
+	}
+	return Default()
+}
+
+func pluralForm(forms map[string]*template.Template, form string) *template.Template {
+	if tmpl, ok := forms[form]; ok {
+		return tmpl
+	}
+	return forms["other"]
+}
+
+func render(tmpl *template.Template, data Vars) (string, bool) {
+	if tmpl == nil {
+		return "", false
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		logrus.WithError(err).WithField("template", tmpl.Name()).Error("Failed to render message template")
+		return "", false
+	}
+	return sb.String(), true
+}
+
+func mustLoadBuiltin() catalog {
+	result, err := loadBuiltin()
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// loadBuiltin читает встроенные шаблоны и проверяет, что в каждом языке есть все сообщения
+// и формы множественного числа языка клиники с теми же переменными
+func loadBuiltin() (catalog, error) {
+	result := make(catalog, len(Languages))
+	for _, language := range Languages {
+		data, err := localeFiles.ReadFile(path.Join("locales", language+".json"))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read locale %s: %v", language, err)
+		}
+		if _, ok := pluralRules[language]; !ok {
+			return nil, fmt.Errorf("no plural rule for locale %s", language)
+		}
+		locale, err := parseLocale(language, data)
+		if err != nil {
+			return nil, err
+		}
+		result[language] = locale
+	}
+
+	base := result[DefaultLanguage]
+	for language, locale := range result {
+		if err := validate(language, locale, base, false); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// parseLocale разбирает файл шаблонов языка и компилирует шаблоны
+func parseLocale(language string, data []byte) (*Locale, error) {
+	var locale Locale
+	if err := json.Unmarshal(data, &locale); err != nil {
+		return nil, fmt.Errorf("unable to parse locale %s: %v", language, err)
+	}
+
+	locale.messages = make(map[string]*template.Template, len(locale.Messages))
+	for key, text := range locale.Messages {
+		tmpl, err := parseTemplate(language+"/"+key, text)
+		if err != nil {
+			return nil, err
+		}
+		locale.messages[key] = tmpl
+	}
+	locale.plurals = make(map[string]map[string]*template.Template, len(locale.Plurals))
+	for key, forms := range locale.Plurals {
+		locale.plurals[key] = make(map[string]*template.Template, len(forms))
+		for form, text := range forms {
+			tmpl, err := parseTemplate(language+"/"+key+"#"+form, text)
+			if err != nil {
+				return nil, err
+			}
+			locale.plurals[key][form] = tmpl
+		}
+	}
+	return &locale, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	// Опечатка в имени переменной должна приводить к ошибке, а не к "<no value>" в сообщении
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template %s: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// validate проверяет шаблоны языка по шаблонам языка клиники base: известны ли ключи и формы
+// и используются ли только те переменные, которые есть во встроенном шаблоне.
+// partial - правка оператора, в которой достаточно указать только изменяемые сообщения
+func validate(language string, locale, base *Locale, partial bool) error {
+	if !partial && (locale.Name == "" || locale.DateLayout == "" || locale.DateTimeLayout == "" || locale.DayLayout == "" || locale.TimeLayout == "") {
+		return fmt.Errorf("locale %s: name and date layouts are required", language)
+	}
+
+	for key, tmpl := range locale.messages {
+		ref, ok := base.messages[key]
+		if !ok {
+			return fmt.Errorf("locale %s: unknown message %q", language, key)
+		}
+		if name, ok := unknownField(tmpl, fields(ref)); ok {
+			return fmt.Errorf("locale %s: message %q uses unknown variable .%s", language, key, name)
+		}
+	}
+	if !partial {
+		for key := range base.messages {
+			if _, ok := locale.messages[key]; !ok && !strings.HasPrefix(key, staffPrefix) {
+				return fmt.Errorf("locale %s: missing message %q", language, key)
+			}
+		}
+	}
+
+	for key, forms := range locale.plurals {
+		refForms, ok := base.plurals[key]
+		if !ok {
+			return fmt.Errorf("locale %s: unknown plural message %q", language, key)
+		}
+		allowed := map[string]bool{"Count": true}
+		for _, ref := range refForms {
+			for name := range fields(ref) {
+				allowed[name] = true
+			}
+		}
+		for form, tmpl := range forms {
+			if !hasForm(language, form) {
+				return fmt.Errorf("locale %s: unknown form %q of %q", language, form, key)
+			}
+			if name, ok := unknownField(tmpl, allowed); ok {
+				return fmt.Errorf("locale %s: plural message %q uses unknown variable .%s", language, key, name)
+			}
+		}
+	}
+	if !partial {
+		for key := range base.plurals {
+			for _, form := range pluralForms[language] {
+				if _, ok := locale.plurals[key][form]; !ok {
+					return fmt.Errorf("locale %s: missing %q form of %q", language, form, key)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasForm(language, form string) bool {
+	for _, f := range pluralForms[language] {
+		if f == form {
+			return true
+		}
+	}
+	return false
+}
+
+// unknownField возвращает первую переменную шаблона, которой нет в allowed
+func unknownField(tmpl *template.Template, allowed map[string]bool) (string, bool) {
+	for _, name := range sortedFields(fields(tmpl)) {
+		if !allowed[name] {
+			return name, true
+		}
+	}
+	return "", false
+}
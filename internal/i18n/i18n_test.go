@@ -0,0 +1,87 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad_AllLocalesComplete(t *testing.T) {
+	loaded, err := loadBuiltin()
+	assert.NoError(t, err)
+	assert.Len(t, loaded, len(Languages))
+	for _, language := range Languages {
+		assert.True(t, Supported(language), language)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"", "ru"},
+		{"ru", "ru"},
+		{"en-US", "en"},
+		{"KK", "kk"},
+		{"de", "en"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Match(tt.code), tt.code)
+	}
+}
+
+func TestLocalizer_T(t *testing.T) {
+	assert.Equal(t, "Выберите врача:", For("ru").T("doctors.choose"))
+	assert.Equal(t, "Choose a doctor:", For("en").T("doctors.choose"))
+	assert.Equal(t, "Ваша запись перенесена на 01.02.2025 в 10:00.", For("ru").T("reschedule.done", Vars{"Datetime": "01.02.2025 в 10:00"}))
+	assert.Equal(t, "Вы успешно записаны на 01.02.2025 в 10:00.\nВрач: Иванова И.И.",
+		For("ru").T("booking.created", Vars{"Datetime": "01.02.2025 в 10:00", "Doctor": "Иванова И.И.", "Service": ""}))
+	// Неизвестный язык заменяется языком клиники, неизвестный ключ возвращается как есть
+	assert.Equal(t, "Выберите врача:", For("de").T("doctors.choose"))
+	assert.Equal(t, "no.such.key", For("en").T("no.such.key"))
+	// Сообщения администраторам есть только на языке клиники
+	assert.Equal(t, "Некорректный ID записи.", For("en").T("admin.invalid_id"))
+	// Если переменная не передана, шаблон не выполняется и возвращается ключ
+	assert.Equal(t, "confirm.done", For("ru").T("confirm.done"))
+}
+
+func TestLocalizer_N(t *testing.T) {
+	ru := For("ru")
+	assert.Equal(t, "У вас 1 предстоящая запись:", ru.N("bookings.count", 1))
+	assert.Equal(t, "У вас 2 предстоящие записи:", ru.N("bookings.count", 2))
+	assert.Equal(t, "У вас 5 предстоящих записей:", ru.N("bookings.count", 5))
+	assert.Equal(t, "У вас 11 предстоящих записей:", ru.N("bookings.count", 11))
+	assert.Equal(t, "У вас 21 предстоящая запись:", ru.N("bookings.count", 21))
+	assert.Equal(t, "У вас 22 предстоящие записи:", ru.N("bookings.count", 22))
+
+	en := For("en")
+	assert.Equal(t, "You have 1 upcoming appointment:", en.N("bookings.count", 1))
+	assert.Equal(t, "You have 3 upcoming appointments:", en.N("bookings.count", 3))
+}
+
+func TestLocalizer_Dates(t *testing.T) {
+	visit := time.Date(2025, time.March, 7, 9, 30, 0, 0, time.UTC)
+
+	assert.Equal(t, "07.03.2025 в 09:30", For("ru").DateTime(visit))
+	assert.Equal(t, "Mar 7, 2025 at 09:30", For("en").DateTime(visit))
+	assert.Equal(t, "07.03.2025, 09:30", For("kk").DateTime(visit))
+	assert.Equal(t, "Fri, Mar 7, 2025", For("en").Date(visit))
+	assert.Equal(t, "07.03", For("ru").Day(visit))
+	assert.Equal(t, "09:30", For("en").Time(visit))
+}
+
+func TestFromContext(t *testing.T) {
+	assert.Equal(t, DefaultLanguage, FromContext(context.Background()).Language())
+
+	ctx := WithLocalizer(context.Background(), For("kk"))
+	assert.Equal(t, "kk", FromContext(ctx).Language())
+}
+
+func TestFields(t *testing.T) {
+	tmpl, err := parseTemplate("test", "{{.Name}}{{if .Doctor}}, {{.Doctor}}{{else}}{{.Clinic}}{{end}}{{range .Items}}{{end}}")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Clinic", "Doctor", "Items", "Name"}, sortedFields(fields(tmpl)))
+}
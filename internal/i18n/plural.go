@@ -0,0 +1,37 @@
+package i18n
+
+// pluralRules выбирает форму множественного числа по правилам CLDR для целых чисел
+var pluralRules = map[string]func(n int) string{
+	"ru": russianPlural,
+	"en": oneOther,
+	"kk": oneOther,
+}
+
+// pluralForms перечисляет формы, которые должны быть в каталоге языка
+var pluralForms = map[string][]string{
+	"ru": {"one", "few", "many"},
+	"en": {"one", "other"},
+	"kk": {"one", "other"},
+}
+
+// russianPlural: 1, 21 запись; 2, 3, 4, 22 записи; 5, 11, 12 записей
+func russianPlural(n int) string {
+	if n < 0 {
+		n = -n
+	}
+	switch {
+	case n%10 == 1 && n%100 != 11:
+		return "one"
+	case n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		return "few"
+	default:
+		return "many"
+	}
+}
+
+func oneOther(n int) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
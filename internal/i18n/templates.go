@@ -0,0 +1,241 @@
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// loadMu упорядочивает применение правок; loaded - состояние файлов, из которых собраны действующие шаблоны
+	loadMu sync.Mutex
+	loaded string
+)
+
+// Load применяет правки шаблонов оператора из каталога dir поверх встроенных.
+// Файл <язык>.json имеет тот же формат, что и встроенный, но содержит только изменяемые сообщения.
+// Если правки некорректны, возвращается ошибка, а действующие шаблоны не меняются.
+// Пустой dir - только встроенные шаблоны
+func Load(dir string) error {
+	loadMu.Lock()
+	defer loadMu.Unlock()
+
+	// Состояние снимается до чтения: изменение во время загрузки будет применено при следующей проверке
+	state, err := snapshot(dir)
+	if err != nil {
+		return fmt.Errorf("unable to read templates dir: %v", err)
+	}
+	overrides := make(map[string]*Locale)
+	if dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("unable to read templates dir: %v", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+				continue
+			}
+			language := strings.TrimSuffix(entry.Name(), ".json")
+			if !Supported(language) {
+				return fmt.Errorf("templates for unsupported language %q", language)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return fmt.Errorf("unable to read templates %s: %v", entry.Name(), err)
+			}
+			override, err := parseLocale(language, data)
+			if err != nil {
+				return err
+			}
+			if err := validate(language, override, builtin[DefaultLanguage], true); err != nil {
+				return err
+			}
+			overrides[language] = override
+		}
+	}
+
+	next := make(catalog, len(builtin))
+	for language, locale := range builtin {
+		next[language] = merge(locale, overrides[language])
+	}
+	current.Store(&next)
+	loaded = state
+	return nil
+}
+
+// Watch проверяет каталог dir каждые interval и применяет изменения шаблонов без перезапуска бота.
+// Некорректные правки отклоняются с записью в лог; бот продолжает работать с прежними шаблонами
+func Watch(ctx context.Context, dir string, interval time.Duration) {
+	loadMu.Lock()
+	last := loaded
+	loadMu.Unlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := snapshot(dir)
+		if err != nil {
+			logrus.WithError(err).WithField("dir", dir).Error("Failed to read templates dir")
+			continue
+		}
+		if next == last {
+			continue
+		}
+		last = next
+		if err := Load(dir); err != nil {
+			logrus.WithError(err).WithField("dir", dir).Error("Invalid message templates, keeping previous version")
+			continue
+		}
+		logrus.WithField("dir", dir).Info("Message templates reloaded")
+	}
+}
+
+// snapshot описывает состояние файлов шаблонов: имя, размер и время изменения
+func snapshot(dir string) (string, error) {
+	if dir == "" {
+		return "", nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d;", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return sb.String(), nil
+}
+
+// merge возвращает шаблоны языка с правками оператора; встроенные шаблоны не изменяются
+func merge(locale, override *Locale) *Locale {
+	if override == nil {
+		return locale
+	}
+	merged := *locale
+	if override.Name != "" {
+		merged.Name = override.Name
+	}
+	if override.DateLayout != "" {
+		merged.DateLayout = override.DateLayout
+	}
+	if override.DateTimeLayout != "" {
+		merged.DateTimeLayout = override.DateTimeLayout
+	}
+	if override.DayLayout != "" {
+		merged.DayLayout = override.DayLayout
+	}
+	if override.TimeLayout != "" {
+		merged.TimeLayout = override.TimeLayout
+	}
+
+	merged.messages = make(map[string]*template.Template, len(locale.messages))
+	for key, tmpl := range locale.messages {
+		merged.messages[key] = tmpl
+	}
+	for key, tmpl := range override.messages {
+		merged.messages[key] = tmpl
+	}
+
+	merged.plurals = make(map[string]map[string]*template.Template, len(locale.plurals))
+	for key, forms := range locale.plurals {
+		merged.plurals[key] = forms
+	}
+	for key, forms := range override.plurals {
+		combined := make(map[string]*template.Template, len(forms))
+		for form, tmpl := range locale.plurals[key] {
+			combined[form] = tmpl
+		}
+		for form, tmpl := range forms {
+			combined[form] = tmpl
+		}
+		merged.plurals[key] = combined
+	}
+	return &merged
+}
+
+// fields возвращает имена переменных верхнего уровня, которые использует шаблон ({{.Name}} -> Name)
+func fields(tmpl *template.Template) map[string]bool {
+	result := make(map[string]bool)
+	if tmpl.Tree != nil {
+		collectFields(tmpl.Tree.Root, result)
+	}
+	return result
+}
+
+func collectFields(node parse.Node, result map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			collectFields(child, result)
+		}
+	case *parse.ActionNode:
+		collectFields(n.Pipe, result)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			collectFields(cmd, result)
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			collectFields(arg, result)
+		}
+	case *parse.FieldNode:
+		result[n.Ident[0]] = true
+	case *parse.ChainNode:
+		collectFields(n.Node, result)
+	case *parse.IfNode:
+		collectBranch(&n.BranchNode, result)
+	case *parse.RangeNode:
+		collectBranch(&n.BranchNode, result)
+	case *parse.WithNode:
+		collectBranch(&n.BranchNode, result)
+	}
+}
+
+func collectBranch(n *parse.BranchNode, result map[string]bool) {
+	collectFields(n.Pipe, result)
+	collectFields(n.List, result)
+	collectFields(n.ElseList, result)
+}
+
+// sortedFields возвращает имена переменных в алфавитном порядке, чтобы ошибки проверки были воспроизводимы
+func sortedFields(names map[string]bool) []string {
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTemplates записывает правки шаблонов языка в каталог dir
+func writeTemplates(t *testing.T, dir, language, content string) {
+	t.Helper()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, language+".json"), []byte(content), 0o644))
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { _ = Load("") })
+	writeTemplates(t, dir, "ru", `{
+		"messages": {
+			"menu.welcome": "Стоматология «Улыбка». Чем можем помочь?",
+			"confirm.done": "{{.Datetime}} - ждём вас!"
+		},
+		"plurals": {"bookings.count": {"one": "Одна запись:"}}
+	}`)
+
+	assert.NoError(t, Load(dir))
+	ru := For("ru")
+	assert.Equal(t, "Стоматология «Улыбка». Чем можем помочь?", ru.T("menu.welcome"))
+	assert.Equal(t, "01.02.2025 в 10:00 - ждём вас!", ru.T("confirm.done", Vars{"Datetime": "01.02.2025 в 10:00"}))
+	assert.Equal(t, "Одна запись:", ru.N("bookings.count", 1))
+	// Непереопределенные сообщения и формы остаются встроенными
+	assert.Equal(t, "Выберите врача:", ru.T("doctors.choose"))
+	assert.Equal(t, "У вас 5 предстоящих записей:", ru.N("bookings.count", 5))
+	assert.Equal(t, "Welcome! Choose an action:", For("en").T("menu.welcome"))
+
+	// Пустой каталог - только встроенные шаблоны
+	assert.NoError(t, Load(""))
+	assert.Equal(t, "Добро пожаловать! Выберите действие:", For("ru").T("menu.welcome"))
+}
+
+func TestLoad_InvalidOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+	}{
+		{"syntax", "ru", `{"messages": {"menu.welcome": "{{.Name"}}`},
+		{"unknown variable", "ru", `{"messages": {"confirm.done": "{{.Name}}, ждём вас {{.Datetime}}"}}`},
+		{"unknown message", "en", `{"messages": {"menu.goodbye": "Bye"}}`},
+		{"unknown plural form", "en", `{"plurals": {"bookings.count": {"few": "{{.Count}}"}}}`},
+		{"unsupported language", "de", `{"messages": {}}`},
+		{"json", "ru", `{"messages": `},
+	}
+	t.Cleanup(func() { _ = Load("") })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTemplates(t, dir, tt.language, tt.content)
+
+			assert.Error(t, Load(dir))
+			// Действующие шаблоны не меняются
+			assert.Equal(t, "Добро пожаловать! Выберите действие:", For("ru").T("menu.welcome"))
+		})
+	}
+}
+
+func TestWatch_ReloadsChangedTemplates(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { _ = Load("") })
+	assert.NoError(t, Load(dir))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Watch(ctx, dir, 10*time.Millisecond)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	writeTemplates(t, dir, "ru", `{"messages": {"menu.welcome": "Новое приветствие"}}`)
+	assert.Eventually(t, func() bool {
+		return For("ru").T("menu.welcome") == "Новое приветствие"
+	}, time.Second, 10*time.Millisecond)
+
+	// Некорректная правка не применяется, бот продолжает работать с прежними шаблонами
+	writeTemplates(t, dir, "ru", `{"messages": {"menu.welcome": "{{.Oops}}"}}`)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "Новое приветствие", For("ru").T("menu.welcome"))
+}
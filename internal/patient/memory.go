@@ -0,0 +1,120 @@
+package patient
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// MemoryStore - хранилище профилей в памяти процесса (для тестов и локального запуска)
+type MemoryStore struct {
+	mu      sync.Mutex
+	nextID  int
+	entries map[int]Patient
+	users   map[int64]int // ID собственного профиля пользователя Telegram
+	// Заменяет проверку таблицы bookings при удалении члена семьи; nil - записей нет
+	hasBookings func(patientID int, statuses []string) bool
+}
+
+// NewMemoryStore создает хранилище профилей в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{nextID: 1, entries: make(map[int]Patient), users: make(map[int64]int)}
+}
+
+// SetBookingCheck задает проверку активных записей члена семьи, которую Repo выполняет в базе
+func (s *MemoryStore) SetBookingCheck(hasBookings func(patientID int, statuses []string) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.hasBookings = hasBookings
+}
+
+// GetByUserID возвращает копию профиля пользователя или nil, если он еще не заполнен
+func (s *MemoryStore) GetByUserID(_ context.Context, userID int64) (*Patient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id, ok := s.users[userID]
+	if !ok {
+		return nil, nil
+	}
+	p := s.entries[id]
+	return &p, nil
+}
+
+// GetByID возвращает копию профиля по ID или nil, если его нет
+func (s *MemoryStore) GetByID(_ context.Context, id int) (*Patient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.entries[id]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// GetDependents возвращает членов семьи, которых записывает владелец профиля guardianID
+func (s *MemoryStore) GetDependents(_ context.Context, guardianID int) ([]Patient, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var dependents []Patient
+	for _, p := range s.entries {
+		if p.GuardianID != nil && *p.GuardianID == guardianID {
+			dependents = append(dependents, p)
+		}
+	}
+	sort.Slice(dependents, func(i, j int) bool { return dependents[i].ID < dependents[j].ID })
+	return dependents, nil
+}
+
+// Save создает или обновляет профиль и записывает его ID в p.ID. Собственный профиль
+// определяется по p.UserID, профиль члена семьи (p.GuardianID задан) - по p.ID
+func (s *MemoryStore) Save(_ context.Context, p *Patient) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p.GuardianID != nil {
+		if p.ID != 0 {
+			existing, ok := s.entries[p.ID]
+			if !ok || existing.GuardianID == nil || *existing.GuardianID != *p.GuardianID {
+				return pgx.ErrNoRows
+			}
+		} else {
+			p.ID = s.nextID
+			s.nextID++
+		}
+		s.entries[p.ID] = *p
+		return nil
+	}
+
+	if id, ok := s.users[p.UserID]; ok {
+		p.ID = id
+	} else {
+		p.ID = s.nextID
+		s.nextID++
+		s.users[p.UserID] = p.ID
+	}
+	s.entries[p.ID] = *p
+	return nil
+}
+
+// DeleteDependent удаляет члена семьи id из профиля guardianID, если у него нет записей в статусах
+// activeStatuses. Возвращает ErrNotRemoved, если такого члена семьи нет или у него есть активные записи
+func (s *MemoryStore) DeleteDependent(_ context.Context, guardianID, id int, activeStatuses []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.entries[id]
+	if !ok || p.GuardianID == nil || *p.GuardianID != guardianID {
+		return ErrNotRemoved
+	}
+	if s.hasBookings != nil && s.hasBookings(id, activeStatuses) {
+		return ErrNotRemoved
+	}
+	delete(s.entries, id)
+	return nil
+}
@@ -0,0 +1,71 @@
+package patient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_Save(t *testing.T) {
+	store := NewMemoryStore()
+	userID := gofakeit.Int64()
+
+	got, err := store.GetByUserID(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	p := &Patient{UserID: userID, Name: "Иван Петров"}
+	assert.NoError(t, store.Save(context.Background(), p))
+	assert.NotZero(t, p.ID)
+	assert.False(t, p.Complete())
+
+	// Повторное сохранение обновляет тот же профиль
+	updated := &Patient{UserID: userID, Name: "Иван Петров", Phone: "+79161234567"}
+	assert.NoError(t, store.Save(context.Background(), updated))
+	assert.Equal(t, p.ID, updated.ID)
+
+	got, err = store.GetByUserID(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, got)
+	assert.True(t, got.Complete())
+}
+
+func TestMemoryStore_Dependents(t *testing.T) {
+	store := NewMemoryStore()
+	guardian := &Patient{UserID: gofakeit.Int64(), Name: "Иван Петров", Phone: "+79161234567"}
+	assert.NoError(t, store.Save(context.Background(), guardian))
+
+	child := &Patient{Name: "Маша Петрова", GuardianID: &guardian.ID}
+	assert.NoError(t, store.Save(context.Background(), child))
+	assert.NotEqual(t, guardian.ID, child.ID)
+
+	got, err := store.GetDependents(context.Background(), guardian.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []Patient{*child}, got)
+
+	byID, err := store.GetByID(context.Background(), child.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, child, byID)
+
+	// Член семьи не подменяет собственный профиль родителя
+	own, err := store.GetByUserID(context.Background(), guardian.UserID)
+	assert.NoError(t, err)
+	assert.Equal(t, guardian, own)
+
+	otherID := guardian.ID + 100
+	active := []string{"scheduled"}
+	assert.ErrorIs(t, store.DeleteDependent(context.Background(), otherID, child.ID, active), ErrNotRemoved)
+
+	// Член семьи с активной записью не удаляется
+	store.SetBookingCheck(func(patientID int, statuses []string) bool {
+		return patientID == child.ID && len(statuses) > 0
+	})
+	assert.ErrorIs(t, store.DeleteDependent(context.Background(), guardian.ID, child.ID, active), ErrNotRemoved)
+	store.SetBookingCheck(nil)
+	assert.NoError(t, store.DeleteDependent(context.Background(), guardian.ID, child.ID, active))
+	got, err = store.GetDependents(context.Background(), guardian.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
@@ -0,0 +1,28 @@
+// Package patient хранит профили пациентов: данные вводятся один раз и используются во всех записях
+package patient
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotRemoved возвращается, если члена семьи нет в профиле или у него остались активные записи
+var ErrNotRemoved = errors.New("family member not found or has active bookings")
+
+// Patient - профиль пациента
+type Patient struct {
+	ID        int
+	UserID    int64      // Пользователь Telegram, которому принадлежит профиль; 0 - у члена семьи
+	Name      string     // Имя и фамилия
+	Phone     string     // Телефон в формате E.164
+	BirthDate *time.Time // nil - не указана
+	Notes     string     // Заметки для клиники, например аллергии
+	// Профиль родителя, который записывает члена семьи (например, ребенка) из своего чата;
+	// nil - собственный профиль пользователя
+	GuardianID *int
+}
+
+// Complete сообщает, хватает ли данных профиля для записи без повторного ввода
+func (p *Patient) Complete() bool {
+	return p.Name != "" && p.Phone != ""
+}
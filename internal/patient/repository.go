@@ -0,0 +1,127 @@
+package patient
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+)
+
+type DBConnection interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Repo - хранилище профилей пациентов в PostgreSQL
+type Repo struct {
+	conn DBConnection
+}
+
+func NewRepo(conn DBConnection) *Repo {
+	return &Repo{conn: conn}
+}
+
+// selectPatients - начало запроса профилей; у членов семьи user_id не задан и читается как 0
+const selectPatients = `SELECT id, COALESCE(user_id, 0), name, phone, birth_date, notes, guardian_id FROM patients`
+
+// GetByUserID возвращает профиль пользователя Telegram или nil, если он еще не заполнен
+func (r *Repo) GetByUserID(ctx context.Context, userID int64) (*Patient, error) {
+	return r.get(ctx, selectPatients+` WHERE user_id = $1`, userID)
+}
+
+// GetByID возвращает профиль по ID или nil, если его нет
+func (r *Repo) GetByID(ctx context.Context, id int) (*Patient, error) {
+	return r.get(ctx, selectPatients+` WHERE id = $1`, id)
+}
+
+func (r *Repo) get(ctx context.Context, query string, arg interface{}) (*Patient, error) {
+	var p Patient
+	err := r.conn.QueryRow(ctx, query, arg).Scan(&p.ID, &p.UserID, &p.Name, &p.Phone, &p.BirthDate, &p.Notes, &p.GuardianID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// GetDependents возвращает членов семьи, которых записывает владелец профиля guardianID
+func (r *Repo) GetDependents(ctx context.Context, guardianID int) ([]Patient, error) {
+	var dependents []Patient
+	rows, err := r.conn.Query(ctx, selectPatients+` WHERE guardian_id = $1 ORDER BY id`, guardianID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p Patient
+		if err := rows.Scan(&p.ID, &p.UserID, &p.Name, &p.Phone, &p.BirthDate, &p.Notes, &p.GuardianID); err != nil {
+			logrus.WithError(err).Error("Failed to scan row in GetDependents")
+			continue
+		}
+		dependents = append(dependents, p)
+	}
+
+	return dependents, rows.Err()
+}
+
+// Save создает или обновляет профиль и записывает его ID в p.ID. Собственный профиль
+// определяется по p.UserID, профиль члена семьи (p.GuardianID задан) - по p.ID
+func (r *Repo) Save(ctx context.Context, p *Patient) error {
+	if p.GuardianID != nil {
+		return r.saveDependent(ctx, p)
+	}
+	query := `
+	INSERT INTO patients (user_id, name, phone, birth_date, notes)
+	VALUES ($1, $2, $3, $4, $5)
+	ON CONFLICT (user_id) DO UPDATE
+	SET name = EXCLUDED.name, phone = EXCLUDED.phone, birth_date = EXCLUDED.birth_date, notes = EXCLUDED.notes, updated_at = now()
+	RETURNING id`
+	return r.conn.QueryRow(ctx, query, p.UserID, p.Name, p.Phone, p.BirthDate, p.Notes).Scan(&p.ID)
+}
+
+// saveDependent добавляет члена семьи или обновляет его профиль; возвращает pgx.ErrNoRows,
+// если профиль с p.ID не принадлежит p.GuardianID
+func (r *Repo) saveDependent(ctx context.Context, p *Patient) error {
+	if p.ID == 0 {
+		query := `
+		INSERT INTO patients (guardian_id, name, phone, birth_date, notes)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+		return r.conn.QueryRow(ctx, query, *p.GuardianID, p.Name, p.Phone, p.BirthDate, p.Notes).Scan(&p.ID)
+	}
+	query := `
+	UPDATE patients
+	SET name = $3, phone = $4, birth_date = $5, notes = $6, updated_at = now()
+	WHERE id = $1 AND guardian_id = $2`
+	tag, err := r.conn.Exec(ctx, query, p.ID, *p.GuardianID, p.Name, p.Phone, p.BirthDate, p.Notes)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteDependent удаляет члена семьи id из профиля guardianID, если у него нет записей в статусах
+// activeStatuses; прошлые записи остаются в истории. Проверка и удаление выполняются одним запросом,
+// поэтому запись, созданная параллельно, не останется без пациента.
+// Возвращает ErrNotRemoved, если такого члена семьи нет или у него есть активные записи
+func (r *Repo) DeleteDependent(ctx context.Context, guardianID, id int, activeStatuses []string) error {
+	query := `DELETE FROM patients WHERE id = $1 AND guardian_id = $2
+	AND NOT EXISTS (SELECT 1 FROM bookings WHERE patient_id = $1 AND status = ANY($3))`
+	tag, err := r.conn.Exec(ctx, query, id, guardianID, activeStatuses)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotRemoved
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package patient
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var patientColumns = []string{"id", "user_id", "name", "phone", "birth_date", "notes", "guardian_id"}
+
+func TestPatientRepo_GetByUserID(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	userID := gofakeit.Int64()
+	birthDate := time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT id, COALESCE\(user_id, 0\), name, phone, birth_date, notes, guardian_id FROM patients WHERE user_id = \$1`).
+		WithArgs(userID).
+		WillReturnRows(pgxmock.NewRows(patientColumns).AddRow(3, userID, "Иван Петров", "+79161234567", &birthDate, "Аллергия на лидокаин", nil))
+
+	got, err := repo.GetByUserID(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Equal(t, &Patient{ID: 3, UserID: userID, Name: "Иван Петров", Phone: "+79161234567", BirthDate: &birthDate, Notes: "Аллергия на лидокаин"}, got)
+	assert.True(t, got.Complete())
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPatientRepo_GetByUserID_NotFound(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	userID := gofakeit.Int64()
+
+	mock.ExpectQuery(`SELECT id, COALESCE\(user_id, 0\), name, phone, birth_date, notes, guardian_id FROM patients`).
+		WithArgs(userID).
+		WillReturnError(pgx.ErrNoRows)
+
+	got, err := repo.GetByUserID(context.Background(), userID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPatientRepo_Save(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	p := &Patient{UserID: gofakeit.Int64(), Name: "Иван Петров", Phone: "+79161234567"}
+
+	mock.ExpectQuery(`INSERT INTO patients .* ON CONFLICT \(user_id\) DO UPDATE .* RETURNING id`).
+		WithArgs(p.UserID, p.Name, p.Phone, p.BirthDate, p.Notes).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(7))
+
+	assert.NoError(t, repo.Save(context.Background(), p))
+	assert.Equal(t, 7, p.ID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPatientRepo_GetDependents(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	guardianID := gofakeit.Number(1, 1000)
+
+	mock.ExpectQuery(`SELECT .* FROM patients WHERE guardian_id = \$1 ORDER BY id`).
+		WithArgs(guardianID).
+		WillReturnRows(pgxmock.NewRows(patientColumns).
+			AddRow(4, int64(0), "Маша Петрова", "", nil, "", &guardianID).
+			AddRow(5, int64(0), "Петя Петров", "", nil, "", &guardianID))
+
+	got, err := repo.GetDependents(context.Background(), guardianID)
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, Patient{ID: 4, Name: "Маша Петрова", GuardianID: &guardianID}, got[0])
+		assert.Equal(t, "Петя Петров", got[1].Name)
+	}
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPatientRepo_SaveDependent(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	guardianID := gofakeit.Number(1, 1000)
+	p := &Patient{Name: "Маша Петрова", GuardianID: &guardianID}
+
+	// Новый член семьи создается без пользователя Telegram
+	mock.ExpectQuery(`INSERT INTO patients \(guardian_id, name, phone, birth_date, notes\) .* RETURNING id`).
+		WithArgs(guardianID, p.Name, p.Phone, p.BirthDate, p.Notes).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(8))
+	assert.NoError(t, repo.Save(context.Background(), p))
+	assert.Equal(t, 8, p.ID)
+
+	// Профиль чужого члена семьи не обновляется
+	p.Notes = "Боится уколов"
+	mock.ExpectExec(`UPDATE patients .* WHERE id = \$1 AND guardian_id = \$2`).
+		WithArgs(p.ID, guardianID, p.Name, p.Phone, p.BirthDate, p.Notes).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 0))
+	assert.ErrorIs(t, repo.Save(context.Background(), p), pgx.ErrNoRows)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPatientRepo_DeleteDependent(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+
+	active := []string{"scheduled", "confirmed"}
+	mock.ExpectExec(`DELETE FROM patients WHERE id = \$1 AND guardian_id = \$2\s+AND NOT EXISTS \(SELECT 1 FROM bookings WHERE patient_id = \$1 AND status = ANY\(\$3\)\)`).
+		WithArgs(8, 3, active).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	assert.NoError(t, repo.DeleteDependent(context.Background(), 3, 8, active))
+
+	// Нет такого члена семьи или у него есть активные записи
+	mock.ExpectExec(`DELETE FROM patients`).
+		WithArgs(8, 4, active).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	assert.ErrorIs(t, repo.DeleteDependent(context.Background(), 4, 8, active), ErrNotRemoved)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
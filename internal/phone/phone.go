@@ -0,0 +1,73 @@
+// Package phone приводит номера телефонов пациентов к формату E.164 (+79161234567)
+package phone
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrInvalid - строка не похожа на номер телефона
+var ErrInvalid = errors.New("invalid phone number")
+
+const (
+	// По E.164 номер вместе с кодом страны содержит не больше 15 цифр
+	maxDigits = 15
+	// Короче 8 цифр международных номеров не бывает
+	minDigits = 8
+	// Российские и казахстанские номера: 7 и 10 цифр номера
+	russianDigits = 11
+)
+
+// Normalize приводит номер к формату E.164. Принимаются номера с пробелами, дефисами, точками
+// и скобками: "+7 916 123-45-67", "8 (916) 123-45-67", "9161234567", "+44 20 7946 0958", "0049 30 901820".
+// Номер без "+" считается российским: 8 и 7 в начале заменяются на +7, 10 цифр дополняются +7
+func Normalize(input string) (string, error) {
+	s := strings.TrimSpace(input)
+	international := false
+	switch {
+	case strings.HasPrefix(s, "+"):
+		international = true
+		s = s[1:]
+	case strings.HasPrefix(s, "00"):
+		international = true
+		s = s[2:]
+	}
+
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.':
+			// Разделители при вводе номера не важны
+		default:
+			return "", ErrInvalid
+		}
+	}
+	digits := sb.String()
+
+	if !international {
+		switch {
+		case len(digits) == russianDigits && (digits[0] == '8' || digits[0] == '7'):
+			digits = "7" + digits[1:]
+		case len(digits) == russianDigits-1:
+			digits = "7" + digits
+		default:
+			return "", ErrInvalid
+		}
+	}
+
+	if len(digits) < minDigits || len(digits) > maxDigits || digits[0] == '0' {
+		return "", ErrInvalid
+	}
+	if digits[0] == '7' && len(digits) != russianDigits {
+		return "", ErrInvalid
+	}
+	return "+" + digits, nil
+}
+
+// NormalizeContact приводит к E.164 номер из контакта Telegram: он всегда международный,
+// но приходит как с "+", так и без него
+func NormalizeContact(number string) (string, error) {
+	return Normalize("+" + strings.TrimPrefix(strings.TrimSpace(number), "+"))
+}
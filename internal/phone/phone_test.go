@@ -0,0 +1,58 @@
+package phone
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	valid := map[string]string{
+		"+79161234567":       "+79161234567",
+		"+7 916 123-45-67":   "+79161234567",
+		"8 (916) 123-45-67":  "+79161234567",
+		"89161234567":        "+79161234567",
+		"79161234567":        "+79161234567",
+		"9161234567":         "+79161234567",
+		"916.123.45.67":      "+79161234567",
+		" +7 (727) 2501234 ": "+77272501234", // Казахстан
+		"+44 20 7946 0958":   "+442079460958",
+		"0049 30 901820":     "+4930901820",
+		"+1 (212) 555-0100":  "+12125550100",
+	}
+	for input, want := range valid {
+		got, err := Normalize(input)
+		if assert.NoError(t, err, input) {
+			assert.Equal(t, want, got, input)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"12345",
+		"+7916123456",       // Не хватает цифры
+		"+791612345678",     // Лишняя цифра
+		"8916123456789",     // Без "+" - только российские номера
+		"+0123456789",       // Код страны не начинается с 0
+		"+1234567890123456", // Длиннее 15 цифр
+		"+7916abc4567",
+		"+7 916 123 45 67 доб. 12",
+	}
+	for _, input := range invalid {
+		_, err := Normalize(input)
+		assert.ErrorIs(t, err, ErrInvalid, input)
+	}
+}
+
+func TestNormalizeContact(t *testing.T) {
+	got, err := NormalizeContact("79161234567")
+	assert.NoError(t, err)
+	assert.Equal(t, "+79161234567", got)
+
+	got, err = NormalizeContact("+442079460958")
+	assert.NoError(t, err)
+	assert.Equal(t, "+442079460958", got)
+
+	_, err = NormalizeContact("")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"stomatology_bot/internal/schedule"
 	"time"
 
 	"golang.org/x/oauth2/google"
@@ -11,16 +12,26 @@ import (
 	"google.golang.org/api/option"
 )
 
+// slotStep - шаг, с которым предлагаются времена начала приема
+const slotStep = 30 * time.Minute
+
+// ClosureChecker сообщает, закрыта ли запись к врачу в определенный день (праздник, отпуск);
+// doctorID = 0 - общий календарь клиники
+type ClosureChecker interface {
+	IsClosed(ctx context.Context, doctorID int, date time.Time) (bool, error)
+}
+
 // CalendarService - сервис для работы с Google Calendar
 type Service struct {
-	srv           *calendar.Service
-	calID         string
-	workStartHour int
-	workEndHour   int
+	srv      *calendar.Service
+	calID    string
+	week     schedule.Week
+	closures ClosureChecker
+	doctorID int
 }
 
-// NewService создает новый сервис для работы с календарем
-func NewService(credentialFile, calendarID string, workStartHour, workEndHour int) (*Service, error) {
+// NewService создает новый сервис для работы с календарем; closures может быть nil
+func NewService(credentialFile, calendarID string, week schedule.Week, closures ClosureChecker) (*Service, error) {
 	ctx := context.Background()
 	b, err := os.ReadFile(credentialFile)
 	if err != nil {
@@ -40,15 +51,27 @@ func NewService(credentialFile, calendarID string, workStartHour, workEndHour in
 	}
 
 	return &Service{
-		srv:           srv,
-		calID:         calendarID,
-		workStartHour: workStartHour,
-		workEndHour:   workEndHour,
+		srv:      srv,
+		calID:    calendarID,
+		week:     week,
+		closures: closures,
 	}, nil
 }
 
+// ForCalendar возвращает сервис для календаря врача с собственным расписанием;
+// подключение к Google Calendar API и проверка закрытий переиспользуются
+func (s *Service) ForCalendar(calendarID string, doctorID int, week schedule.Week) Provider {
+	return &Service{
+		srv:      s.srv,
+		calID:    calendarID,
+		week:     week,
+		closures: s.closures,
+		doctorID: doctorID,
+	}
+}
+
 // CreateEvent создает новое событие в календаре
-func (s *Service) CreateEvent(summary, description string, start, end time.Time) (string, string, error) {
+func (s *Service) CreateEvent(ctx context.Context, summary, description string, start, end time.Time) (string, string, error) {
 	event := &calendar.Event{
 		Summary:     summary,
 		Description: description,
@@ -62,7 +85,7 @@ func (s *Service) CreateEvent(summary, description string, start, end time.Time)
 		},
 	}
 
-	event, err := s.srv.Events.Insert(s.calID, event).Do()
+	event, err := s.srv.Events.Insert(s.calID, event).Context(ctx).Do()
 	if err != nil {
 		return "", "", fmt.Errorf("unable to create event: %v", err)
 	}
@@ -70,14 +93,15 @@ func (s *Service) CreateEvent(summary, description string, start, end time.Time)
 	return event.HtmlLink, event.Id, nil
 }
 
-// GetFreeSlots возвращает список свободных слотов на определенный день
-func (s *Service) GetFreeSlots(date time.Time) ([]time.Time, error) {
-	// Устанавливаем начало и конец дня
-	loc, err := time.LoadLocation("Europe/Moscow")
-	if err != nil {
-		return nil, fmt.Errorf("could not load location: %v", err)
+// GetFreeSlots возвращает список времен начала приема заданной длительности на определенный день.
+// Слоты идут с шагом slotStep, прием должен целиком укладываться в рабочий интервал расписания
+// и не пересекаться с событиями календаря, кроме excludeEventID
+func (s *Service) GetFreeSlots(ctx context.Context, date time.Time, duration time.Duration, excludeEventID string) ([]time.Time, error) {
+	// В выходной и в дни закрытия не обращаемся к календарю
+	startOfDay, candidates, err := dayCandidates(ctx, date, duration, s.week, s.closures, s.doctorID)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
 	}
-	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
 	endOfDay := startOfDay.Add(24 * time.Hour)
 
 	// Запрашиваем события на этот день
@@ -86,59 +110,69 @@ func (s *Service) GetFreeSlots(date time.Time) ([]time.Time, error) {
 		TimeMax(endOfDay.Format(time.RFC3339)).
 		SingleEvents(true).
 		OrderBy("startTime").
+		Context(ctx).
 		Do()
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve next ten of the user's events: %v", err)
 	}
 
-	var freeSlots []time.Time
-
-	// Генерируем все возможные слоты в течение рабочего дня
-	for hour := s.workStartHour; hour <= s.workEndHour; hour++ {
-		slot := time.Date(date.Year(), date.Month(), date.Day(), hour, 0, 0, 0, loc)
-		isBusy := false
-
-		// Проверяем, занят ли этот слот
-		for _, item := range events.Items {
-			eventStart, _ := time.Parse(time.RFC3339, item.Start.DateTime)
-			eventEnd, _ := time.Parse(time.RFC3339, item.End.DateTime)
-
-			if slot.After(eventStart) && slot.Before(eventEnd) {
-				isBusy = true
-				break
-			}
-			if slot.Equal(eventStart) {
-				isBusy = true
-				break
-			}
-		}
-
-		if !isBusy {
-			freeSlots = append(freeSlots, slot)
+	busy := make([]busyPeriod, 0, len(events.Items))
+	for _, item := range events.Items {
+		if excludeEventID != "" && item.Id == excludeEventID {
+			continue
 		}
+		eventStart, _ := time.Parse(time.RFC3339, item.Start.DateTime)
+		eventEnd, _ := time.Parse(time.RFC3339, item.End.DateTime)
+		busy = append(busy, busyPeriod{start: eventStart, end: eventEnd})
 	}
 
-	return freeSlots, nil
+	return freeSlots(candidates, duration, busy), nil
 }
 
-// IsSlotFree проверяет, свободен ли временной слот
-func (s *Service) IsSlotFree(start time.Time, end time.Time) (bool, error) {
+// IsSlotFree проверяет, свободен ли временной слот; событие excludeEventID не считается занятым
+func (s *Service) IsSlotFree(ctx context.Context, start time.Time, end time.Time, excludeEventID string) (bool, error) {
 	events, err := s.srv.Events.List(s.calID).
 		TimeMin(start.Format(time.RFC3339)).
 		TimeMax(end.Format(time.RFC3339)).
-		MaxResults(1). // Нам достаточно одного события, чтобы понять, что слот занят
+		MaxResults(2). // Одно из событий может оказаться исключенным, второго достаточно, чтобы понять, что слот занят
 		SingleEvents(true).
+		Context(ctx).
 		Do()
 	if err != nil {
 		return false, fmt.Errorf("unable to retrieve events: %v", err)
 	}
 
-	return len(events.Items) == 0, nil
+	for _, item := range events.Items {
+		if excludeEventID == "" || item.Id != excludeEventID {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// PatchEvent переносит существующее событие на новое время, сохраняя его описание и ID
+func (s *Service) PatchEvent(ctx context.Context, eventID string, start, end time.Time) error {
+	event := &calendar.Event{
+		Start: &calendar.EventDateTime{
+			DateTime: start.Format(time.RFC3339),
+			TimeZone: "Europe/Moscow",
+		},
+		End: &calendar.EventDateTime{
+			DateTime: end.Format(time.RFC3339),
+			TimeZone: "Europe/Moscow",
+		},
+	}
+
+	_, err := s.srv.Events.Patch(s.calID, eventID, event).Context(ctx).Do()
+	if err != nil {
+		return fmt.Errorf("unable to patch event: %v", err)
+	}
+	return nil
 }
 
 // DeleteEvent удаляет событие из календаря по его ID
-func (s *Service) DeleteEvent(eventID string) error {
-	err := s.srv.Events.Delete(s.calID, eventID).Do()
+func (s *Service) DeleteEvent(ctx context.Context, eventID string) error {
+	err := s.srv.Events.Delete(s.calID, eventID).Context(ctx).Do()
 	if err != nil {
 		return fmt.Errorf("unable to delete event: %v", err)
 	}
@@ -3,8 +3,10 @@ package calendar
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"stomatology_bot/internal/schedule"
 	"testing"
 	"time"
 
@@ -21,10 +23,9 @@ func newTestCalendarService(serverURL string) (*Service, error) {
 		return nil, err
 	}
 	return &Service{
-		srv:           srv,
-		calID:         gofakeit.UUID(),
-		workStartHour: 9,
-		workEndHour:   18,
+		srv:   srv,
+		calID: gofakeit.UUID(),
+		week:  schedule.Default(9, 18),
 	}, nil
 }
 
@@ -51,14 +52,55 @@ func TestCalendarService_GetFreeSlots(t *testing.T) {
 	assert.NoError(t, err)
 
 	testDate := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
-	freeSlots, err := service.GetFreeSlots(testDate)
+	freeSlots, err := service.GetFreeSlots(context.Background(), testDate, time.Hour, "")
 
 	assert.NoError(t, err)
 	assert.NotNil(t, freeSlots)
-	// Ожидаем, что слоты в 10 и 14 будут заняты
-	// 9, 11, 12, 13, 15, 16, 17, 18 - свободны (8 слотов)
-	// 9, 11, 12, 13, 15, 16, 17, 18, 19 - свободны (9 слотов)
-	assert.Len(t, freeSlots, 8)
+	// Часовой прием с шагом 30 минут, события 10-11 и 14-15 заняты, прием заканчивается до 18:00:
+	// 9:00, 11:00, 11:30, 12:00, 12:30, 13:00, 15:00, 15:30, 16:00, 16:30, 17:00 (11 слотов)
+	assert.Len(t, freeSlots, 11)
+}
+
+func TestCalendarService_GetFreeSlots_LongService(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		response := `{
+			"items": [
+				{
+					"start": {"dateTime": "2025-10-24T10:00:00+03:00"},
+					"end": {"dateTime": "2025-10-24T11:00:00+03:00"}
+				},
+				{
+					"start": {"dateTime": "2025-10-24T14:00:00+03:00"},
+					"end": {"dateTime": "2025-10-24T15:00:00+03:00"}
+				}
+			]
+		}`
+		fmt.Fprintln(w, response)
+	}))
+	defer server.Close()
+
+	service, err := newTestCalendarService(server.URL)
+	assert.NoError(t, err)
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+
+	testDate := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
+	freeSlots, err := service.GetFreeSlots(context.Background(), testDate, 90*time.Minute, "")
+	assert.NoError(t, err)
+
+	// Полуторачасовой прием не должен задевать события и выходить за конец рабочего дня
+	expected := []time.Time{
+		time.Date(2025, 10, 24, 11, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 11, 30, 0, 0, loc),
+		time.Date(2025, 10, 24, 12, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 12, 30, 0, 0, loc),
+		time.Date(2025, 10, 24, 15, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 15, 30, 0, 0, loc),
+		time.Date(2025, 10, 24, 16, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 16, 30, 0, 0, loc),
+	}
+	assert.Equal(t, expected, freeSlots)
 }
 
 func TestCalendarService_CreateEvent(t *testing.T) {
@@ -75,7 +117,7 @@ func TestCalendarService_CreateEvent(t *testing.T) {
 	assert.NoError(t, err)
 
 	now := gofakeit.Date()
-	link, eventID, err := service.CreateEvent(gofakeit.Sentence(), gofakeit.Sentence(), now, now.Add(time.Hour))
+	link, eventID, err := service.CreateEvent(context.Background(), gofakeit.Sentence(), gofakeit.Sentence(), now, now.Add(time.Hour))
 
 	assert.NoError(t, err)
 	assert.NotEmpty(t, link)
@@ -92,8 +134,110 @@ func TestCalendarService_DeleteEvent(t *testing.T) {
 	service, err := newTestCalendarService(server.URL)
 	assert.NoError(t, err)
 
-	err = service.DeleteEvent(gofakeit.UUID())
+	err = service.DeleteEvent(context.Background(), gofakeit.UUID())
+	assert.NoError(t, err)
+}
+
+func TestCalendarService_GetFreeSlots_Schedule(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		fmt.Fprintln(w, `{"items": []}`)
+	}))
+	defer server.Close()
+
+	service, err := newTestCalendarService(server.URL)
+	assert.NoError(t, err)
+	week, err := schedule.Parse("mon-fri 09:00-11:00,12:00-13:00; sat 10:00-12:00")
+	assert.NoError(t, err)
+	provider := service.ForCalendar(gofakeit.UUID(), 1, week)
+
+	loc, err := time.LoadLocation("Europe/Moscow")
 	assert.NoError(t, err)
+
+	// Пятница: прием не задевает обеденный перерыв
+	freeSlots, err := provider.GetFreeSlots(context.Background(), time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC), time.Hour, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 10, 24, 9, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 9, 30, 0, 0, loc),
+		time.Date(2025, 10, 24, 10, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 12, 0, 0, 0, loc),
+	}, freeSlots)
+
+	// Воскресенье - выходной, календарь не запрашивается
+	freeSlots, err = provider.GetFreeSlots(context.Background(), time.Date(2025, 10, 26, 0, 0, 0, 0, time.UTC), time.Hour, "")
+	assert.NoError(t, err)
+	assert.Empty(t, freeSlots)
+	assert.Equal(t, 1, requests)
+}
+
+type stubClosures struct {
+	doctorID int
+	date     time.Time
+}
+
+func (c *stubClosures) IsClosed(_ context.Context, doctorID int, date time.Time) (bool, error) {
+	return doctorID == c.doctorID && date.Format("2006-01-02") == c.date.Format("2006-01-02"), nil
+}
+
+func TestCalendarService_GetFreeSlots_Closed(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		fmt.Fprintln(w, `{"items": []}`)
+	}))
+	defer server.Close()
+
+	service, err := newTestCalendarService(server.URL)
+	assert.NoError(t, err)
+	// Врач 2 в отпуске 24.10.2025
+	service.closures = &stubClosures{doctorID: 2, date: time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)}
+
+	testDate := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
+	freeSlots, err := service.ForCalendar(gofakeit.UUID(), 2, schedule.Default(9, 18)).GetFreeSlots(context.Background(), testDate, time.Hour, "")
+	assert.NoError(t, err)
+	assert.Empty(t, freeSlots)
+	assert.Equal(t, 0, requests)
+
+	// У другого врача этот день рабочий
+	freeSlots, err = service.ForCalendar(gofakeit.UUID(), 3, schedule.Default(9, 18)).GetFreeSlots(context.Background(), testDate, time.Hour, "")
+	assert.NoError(t, err)
+	assert.Len(t, freeSlots, 17)
+	assert.Equal(t, 1, requests)
+}
+
+func TestCalendarService_PatchEvent(t *testing.T) {
+	var method, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		fmt.Fprintf(w, `{"id": "%s"}`, gofakeit.UUID())
+	}))
+	defer server.Close()
+
+	service, err := newTestCalendarService(server.URL)
+	assert.NoError(t, err)
+
+	start := time.Date(2025, 10, 24, 12, 0, 0, 0, time.UTC)
+	err = service.PatchEvent(context.Background(), gofakeit.UUID(), start, start.Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPatch, method)
+	assert.Contains(t, body, "2025-10-24T12:30:00Z")
+}
+
+func TestCalendarService_PatchEvent_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	service, err := newTestCalendarService(server.URL)
+	assert.NoError(t, err)
+
+	err = service.PatchEvent(context.Background(), gofakeit.UUID(), time.Now(), time.Now().Add(time.Hour))
+	assert.Error(t, err)
 }
 
 func TestCalendarService_GetFreeSlots_Error(t *testing.T) {
@@ -106,7 +250,7 @@ func TestCalendarService_GetFreeSlots_Error(t *testing.T) {
 	assert.NoError(t, err)
 
 	testDate := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
-	_, err = service.GetFreeSlots(testDate)
+	_, err = service.GetFreeSlots(context.Background(), testDate, time.Hour, "")
 	assert.Error(t, err)
 }
 
@@ -120,7 +264,7 @@ func TestCalendarService_CreateEvent_Error(t *testing.T) {
 	assert.NoError(t, err)
 
 	now := gofakeit.Date()
-	_, _, err = service.CreateEvent(gofakeit.Sentence(), gofakeit.Sentence(), now, now.Add(time.Hour))
+	_, _, err = service.CreateEvent(context.Background(), gofakeit.Sentence(), gofakeit.Sentence(), now, now.Add(time.Hour))
 	assert.Error(t, err)
 }
 
@@ -135,11 +279,31 @@ func TestCalendarService_IsSlotFree(t *testing.T) {
 	assert.NoError(t, err)
 
 	now := gofakeit.Date()
-	isFree, err := service.IsSlotFree(now, now.Add(time.Hour))
+	isFree, err := service.IsSlotFree(context.Background(), now, now.Add(time.Hour), "")
 	assert.NoError(t, err)
 	assert.True(t, isFree)
 }
 
+func TestCalendarService_IsSlotFree_ExcludesEvent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(w, `{"items": [{"id": "moved"}]}`)
+	}))
+	defer server.Close()
+
+	service, err := newTestCalendarService(server.URL)
+	assert.NoError(t, err)
+
+	// Переносимое событие не занимает слот, другое событие - занимает
+	now := gofakeit.Date()
+	isFree, err := service.IsSlotFree(context.Background(), now, now.Add(time.Hour), "moved")
+	assert.NoError(t, err)
+	assert.True(t, isFree)
+
+	isFree, err = service.IsSlotFree(context.Background(), now, now.Add(time.Hour), "")
+	assert.NoError(t, err)
+	assert.False(t, isFree)
+}
+
 func TestCalendarService_IsSlotFree_Error(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -150,13 +314,13 @@ func TestCalendarService_IsSlotFree_Error(t *testing.T) {
 	assert.NoError(t, err)
 
 	now := gofakeit.Date()
-	_, err = service.IsSlotFree(now, now.Add(time.Hour))
+	_, err = service.IsSlotFree(context.Background(), now, now.Add(time.Hour), "")
 	assert.Error(t, err)
 }
 
 func TestNewCalendarService_Error(t *testing.T) {
 	// Тест на ошибку чтения файла credentials
-	_, err := NewService("non-existent-file.json", "test-id", 9, 18)
+	_, err := NewService("non-existent-file.json", "test-id", schedule.Default(9, 18), nil)
 	assert.Error(t, err)
 }
 
@@ -170,7 +334,7 @@ func TestCalendarService_GetFreeSlots_InvalidDate(t *testing.T) {
 	assert.NoError(t, err)
 
 	// Передаем некорректную дату (нулевое время)
-	_, err = service.GetFreeSlots(time.Time{})
+	_, err = service.GetFreeSlots(context.Background(), time.Time{}, time.Hour, "")
 	// Ожидаем ошибку, так как LoadLocation вернет ошибку для нулевого времени
 	assert.Error(t, err)
 }
@@ -184,6 +348,28 @@ func TestCalendarService_DeleteEvent_Error(t *testing.T) {
 	service, err := newTestCalendarService(server.URL)
 	assert.NoError(t, err)
 
-	err = service.DeleteEvent(gofakeit.UUID())
+	err = service.DeleteEvent(context.Background(), gofakeit.UUID())
 	assert.Error(t, err)
 }
+
+func TestCalendarService_ForCalendar(t *testing.T) {
+	var requestedPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestedPath = r.URL.Path
+		fmt.Fprintln(w, `{"items": []}`)
+	}))
+	defer server.Close()
+
+	service, err := newTestCalendarService(server.URL)
+	assert.NoError(t, err)
+
+	doctorCalendarID := gofakeit.UUID()
+	doctorService := service.ForCalendar(doctorCalendarID, 1, schedule.Default(10, 12))
+
+	testDate := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
+	freeSlots, err := doctorService.GetFreeSlots(context.Background(), testDate, time.Hour, "")
+	assert.NoError(t, err)
+	// Рабочие часы врача с 10 до 12: 10:00, 10:30, 11:00
+	assert.Len(t, freeSlots, 3)
+	assert.Contains(t, requestedPath, doctorCalendarID)
+}
@@ -0,0 +1,152 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"stomatology_bot/internal/schedule"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DBConnection interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// LocalService - календарь, хранящий события в PostgreSQL (таблица calendar_events);
+// не требует Google Workspace и работает без доступа в интернет
+type LocalService struct {
+	conn     DBConnection
+	calID    string
+	week     schedule.Week
+	closures ClosureChecker
+	doctorID int
+}
+
+// NewLocalService создает локальный календарь; closures может быть nil
+func NewLocalService(conn DBConnection, calendarID string, week schedule.Week, closures ClosureChecker) *LocalService {
+	return &LocalService{
+		conn:     conn,
+		calID:    calendarID,
+		week:     week,
+		closures: closures,
+	}
+}
+
+// ForCalendar возвращает локальный календарь врача с собственным расписанием
+func (s *LocalService) ForCalendar(calendarID string, doctorID int, week schedule.Week) Provider {
+	return &LocalService{
+		conn:     s.conn,
+		calID:    calendarID,
+		week:     week,
+		closures: s.closures,
+		doctorID: doctorID,
+	}
+}
+
+// CreateEvent сохраняет событие; ссылки на событие у локального календаря нет, поэтому она пустая
+func (s *LocalService) CreateEvent(ctx context.Context, summary, description string, start, end time.Time) (string, string, error) {
+	query := `
+	INSERT INTO calendar_events (calendar_id, summary, description, start_time, end_time)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id`
+	var id int
+	err := s.conn.QueryRow(ctx, query, s.calID, summary, description, start, end).Scan(&id)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to create event: %v", err)
+	}
+	return "", strconv.Itoa(id), nil
+}
+
+// GetFreeSlots возвращает времена начала приема заданной длительности на определенный день,
+// по тем же правилам, что и Google Calendar
+func (s *LocalService) GetFreeSlots(ctx context.Context, date time.Time, duration time.Duration, excludeEventID string) ([]time.Time, error) {
+	startOfDay, candidates, err := dayCandidates(ctx, date, duration, s.week, s.closures, s.doctorID)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+
+	// ID событий начинаются с 1, поэтому 0 ничего не исключает
+	excludeID, _ := strconv.Atoi(excludeEventID)
+	busy, err := s.busyPeriods(ctx, startOfDay, startOfDay.Add(24*time.Hour), excludeID)
+	if err != nil {
+		return nil, err
+	}
+	return freeSlots(candidates, duration, busy), nil
+}
+
+// IsSlotFree проверяет, что промежуток не пересекается ни с одним событием календаря, кроме excludeEventID
+func (s *LocalService) IsSlotFree(ctx context.Context, start time.Time, end time.Time, excludeEventID string) (bool, error) {
+	excludeID, _ := strconv.Atoi(excludeEventID)
+	query := `
+	SELECT NOT EXISTS (
+		SELECT 1 FROM calendar_events
+		WHERE calendar_id = $1 AND start_time < $3 AND end_time > $2 AND id <> $4
+	)`
+	var free bool
+	if err := s.conn.QueryRow(ctx, query, s.calID, start, end, excludeID).Scan(&free); err != nil {
+		return false, fmt.Errorf("unable to retrieve events: %v", err)
+	}
+	return free, nil
+}
+
+// PatchEvent переносит событие на новое время
+func (s *LocalService) PatchEvent(ctx context.Context, eventID string, start, end time.Time) error {
+	id, err := strconv.Atoi(eventID)
+	if err != nil {
+		return fmt.Errorf("invalid event id %q", eventID)
+	}
+	tag, err := s.conn.Exec(ctx,
+		`UPDATE calendar_events SET start_time = $1, end_time = $2 WHERE id = $3 AND calendar_id = $4`,
+		start, end, id, s.calID)
+	if err != nil {
+		return fmt.Errorf("unable to patch event: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("event %s not found", eventID)
+	}
+	return nil
+}
+
+// DeleteEvent удаляет событие по его ID
+func (s *LocalService) DeleteEvent(ctx context.Context, eventID string) error {
+	id, err := strconv.Atoi(eventID)
+	if err != nil {
+		return fmt.Errorf("invalid event id %q", eventID)
+	}
+	tag, err := s.conn.Exec(ctx,
+		`DELETE FROM calendar_events WHERE id = $1 AND calendar_id = $2`, id, s.calID)
+	if err != nil {
+		return fmt.Errorf("unable to delete event: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("event %s not found", eventID)
+	}
+	return nil
+}
+
+func (s *LocalService) busyPeriods(ctx context.Context, from, to time.Time, excludeID int) ([]busyPeriod, error) {
+	query := `
+	SELECT start_time, end_time FROM calendar_events
+	WHERE calendar_id = $1 AND start_time < $3 AND end_time > $2 AND id <> $4
+	ORDER BY start_time`
+	rows, err := s.conn.Query(ctx, query, s.calID, from, to, excludeID)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve events: %v", err)
+	}
+	defer rows.Close()
+
+	var busy []busyPeriod
+	for rows.Next() {
+		var period busyPeriod
+		if err := rows.Scan(&period.start, &period.end); err != nil {
+			return nil, fmt.Errorf("unable to scan event: %v", err)
+		}
+		busy = append(busy, period)
+	}
+	return busy, rows.Err()
+}
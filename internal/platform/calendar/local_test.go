@@ -0,0 +1,129 @@
+package calendar
+
+import (
+	"context"
+	"stomatology_bot/internal/schedule"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocalService_CreateEvent(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	calendarID := gofakeit.UUID()
+	service := NewLocalService(mock, calendarID, schedule.Default(9, 18), nil)
+	summary := gofakeit.Name()
+	start := gofakeit.Date()
+	end := start.Add(time.Hour)
+
+	mock.ExpectQuery(`INSERT INTO calendar_events`).
+		WithArgs(calendarID, summary, "", start, end).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(42))
+
+	link, eventID, err := service.CreateEvent(context.Background(), summary, "", start, end)
+	assert.NoError(t, err)
+	assert.Empty(t, link)
+	assert.Equal(t, "42", eventID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocalService_GetFreeSlots(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+
+	doctorCalendarID := gofakeit.UUID()
+	service := NewLocalService(mock, gofakeit.UUID(), schedule.Default(9, 18), nil).
+		ForCalendar(doctorCalendarID, 1, schedule.Default(10, 13))
+
+	// Пятница, занято 11:00-12:00
+	mock.ExpectQuery(`SELECT start_time, end_time FROM calendar_events`).
+		WithArgs(doctorCalendarID, time.Date(2025, 10, 24, 0, 0, 0, 0, loc), time.Date(2025, 10, 25, 0, 0, 0, 0, loc), 0).
+		WillReturnRows(pgxmock.NewRows([]string{"start_time", "end_time"}).
+			AddRow(time.Date(2025, 10, 24, 11, 0, 0, 0, loc), time.Date(2025, 10, 24, 12, 0, 0, 0, loc)))
+
+	freeSlots, err := service.GetFreeSlots(context.Background(), time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC), time.Hour, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 10, 24, 10, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 12, 0, 0, 0, loc),
+	}, freeSlots)
+
+	// Воскресенье - выходной, база не запрашивается
+	freeSlots, err = service.GetFreeSlots(context.Background(), time.Date(2025, 10, 26, 0, 0, 0, 0, time.UTC), time.Hour, "")
+	assert.NoError(t, err)
+	assert.Empty(t, freeSlots)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocalService_GetFreeSlots_Closed(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	testDate := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
+	service := NewLocalService(mock, gofakeit.UUID(), schedule.Default(9, 18), &stubClosures{doctorID: 0, date: testDate})
+
+	freeSlots, err := service.GetFreeSlots(context.Background(), testDate, time.Hour, "")
+	assert.NoError(t, err)
+	assert.Empty(t, freeSlots)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocalService_IsSlotFree(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	calendarID := gofakeit.UUID()
+	service := NewLocalService(mock, calendarID, schedule.Default(9, 18), nil)
+	start := gofakeit.Date()
+	end := start.Add(time.Hour)
+
+	mock.ExpectQuery(`SELECT NOT EXISTS`).
+		WithArgs(calendarID, start, end, 42).
+		WillReturnRows(pgxmock.NewRows([]string{"free"}).AddRow(false))
+
+	free, err := service.IsSlotFree(context.Background(), start, end, "42")
+	assert.NoError(t, err)
+	assert.False(t, free)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestLocalService_PatchAndDeleteEvent(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	calendarID := gofakeit.UUID()
+	service := NewLocalService(mock, calendarID, schedule.Default(9, 18), nil)
+	start := gofakeit.Date()
+	end := start.Add(time.Hour)
+
+	mock.ExpectExec(`UPDATE calendar_events SET start_time = \$1, end_time = \$2 WHERE id = \$3 AND calendar_id = \$4`).
+		WithArgs(start, end, 42, calendarID).
+		WillReturnResult(pgxmock.NewResult("UPDATE", 1))
+	mock.ExpectExec(`DELETE FROM calendar_events WHERE id = \$1 AND calendar_id = \$2`).
+		WithArgs(42, calendarID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	// Событие другого календаря или уже удаленное
+	mock.ExpectExec(`DELETE FROM calendar_events`).
+		WithArgs(43, calendarID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	assert.NoError(t, service.PatchEvent(context.Background(), "42", start, end))
+	assert.NoError(t, service.DeleteEvent(context.Background(), "42"))
+	assert.Error(t, service.DeleteEvent(context.Background(), "43"))
+	// ID событий Google Calendar в локальном календаре не найти
+	assert.Error(t, service.DeleteEvent(context.Background(), gofakeit.UUID()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
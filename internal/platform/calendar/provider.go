@@ -0,0 +1,73 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"stomatology_bot/internal/schedule"
+	"time"
+)
+
+// Бэкенды календаря, выбираемые переменной CALENDAR_BACKEND
+const (
+	BackendGoogle = "google"
+	BackendLocal  = "local"
+)
+
+// Provider - календарь, в котором бот ищет свободное время и хранит события приемов
+type Provider interface {
+	// GetFreeSlots и IsSlotFree не считают занятым время события excludeEventID (например, переносимого приема);
+	// пустая строка - учитываются все события
+	GetFreeSlots(ctx context.Context, date time.Time, duration time.Duration, excludeEventID string) ([]time.Time, error)
+	IsSlotFree(ctx context.Context, start, end time.Time, excludeEventID string) (bool, error)
+	CreateEvent(ctx context.Context, summary, description string, start, end time.Time) (string, string, error)
+	PatchEvent(ctx context.Context, eventID string, start, end time.Time) error
+	DeleteEvent(ctx context.Context, eventID string) error
+	// ForCalendar возвращает календарь врача с собственным расписанием
+	ForCalendar(calendarID string, doctorID int, week schedule.Week) Provider
+}
+
+// busyPeriod - занятый промежуток календаря
+type busyPeriod struct {
+	start time.Time
+	end   time.Time
+}
+
+// dayCandidates возвращает начало дня по Москве и возможные времена начала приема по расписанию;
+// в выходной и в дни закрытия кандидатов нет
+func dayCandidates(ctx context.Context, date time.Time, duration time.Duration, week schedule.Week, closures ClosureChecker, doctorID int) (time.Time, []time.Time, error) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		return time.Time{}, nil, fmt.Errorf("could not load location: %v", err)
+	}
+	startOfDay := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+
+	if closures != nil {
+		closed, err := closures.IsClosed(ctx, doctorID, startOfDay)
+		if err != nil {
+			return startOfDay, nil, fmt.Errorf("unable to check closures: %v", err)
+		}
+		if closed {
+			return startOfDay, nil, nil
+		}
+	}
+	return startOfDay, week.Slots(startOfDay, duration, slotStep), nil
+}
+
+// freeSlots оставляет из кандидатов те времена, прием с которых не пересекается с занятыми промежутками
+func freeSlots(candidates []time.Time, duration time.Duration, busy []busyPeriod) []time.Time {
+	var free []time.Time
+	for _, slot := range candidates {
+		slotEnd := slot.Add(duration)
+		isBusy := false
+		for _, period := range busy {
+			if slot.Before(period.end) && slotEnd.After(period.start) {
+				isBusy = true
+				break
+			}
+		}
+		if !isBusy {
+			free = append(free, slot)
+		}
+	}
+	return free
+}
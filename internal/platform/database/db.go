@@ -4,19 +4,79 @@ import (
 	"context"
 	"fmt"
 	"stomatology_bot/configs"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sirupsen/logrus"
+)
 
-	"github.com/jackc/pgx/v5"
+const (
+	// Задержка перед повторным подключением удваивается после каждой неудачной попытки
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
 )
 
-func GetConnect(dbConfig configs.DBConfig) (*pgx.Conn, error) {
+// NewPool создает пул соединений с БД. Пока база недоступна, подключение повторяется
+// с нарастающей задержкой, не более ConnectRetries попыток
+func NewPool(ctx context.Context, dbConfig configs.DBConfig) (*pgxpool.Pool, error) {
 	dbURL := fmt.Sprintf("postgres://%s:%s@%s:%s/%s", dbConfig.User, dbConfig.Password, dbConfig.Host, dbConfig.Port, dbConfig.Name)
 
-	conn, err := pgx.Connect(context.Background(), dbURL)
+	poolConfig, err := pgxpool.ParseConfig(dbURL)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse database config: %v", err)
+	}
+	if dbConfig.MaxConns > 0 {
+		poolConfig.MaxConns = int32(dbConfig.MaxConns)
+	}
+	if dbConfig.MinConns > 0 {
+		poolConfig.MinConns = int32(dbConfig.MinConns)
+	}
+	if dbConfig.HealthCheckSeconds > 0 {
+		poolConfig.HealthCheckPeriod = time.Duration(dbConfig.HealthCheckSeconds) * time.Second
+	}
+
+	var pool *pgxpool.Pool
+	err = retry(ctx, dbConfig.ConnectRetries, initialBackoff, func() error {
+		p, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			return err
+		}
+		// NewWithConfig не устанавливает соединение, поэтому проверяем доступность базы явно
+		if err := p.Ping(ctx); err != nil {
+			p.Close()
+			return err
+		}
+		pool = p
+		return nil
+	})
 	if err != nil {
-		logrus.WithError(err).Fatal("Unable to connect to database")
+		return nil, fmt.Errorf("unable to connect to database: %v", err)
+	}
+	logrus.WithField("max_conns", poolConfig.MaxConns).Info("Connect database")
+	return pool, nil
+}
+
+// retry вызывает fn, пока она не завершится успешно или не закончатся попытки;
+// задержка между попытками удваивается, но не превышает maxBackoff
+func retry(ctx context.Context, attempts int, backoff time.Duration, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if attempt >= attempts {
+			return err
+		}
+		logrus.WithError(err).WithField("attempt", attempt).Warnf("Database is unavailable, retrying in %s", backoff)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
 	}
-	logrus.Info("Connect database")
-	return conn, nil
 }
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	calls := 0
+	err := retry(context.Background(), 5, time.Millisecond, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_GivesUp(t *testing.T) {
+	calls := 0
+	err := retry(context.Background(), 3, time.Millisecond, func() error {
+		calls++
+		return errors.New("connection refused")
+	})
+	assert.EqualError(t, err, "connection refused")
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetry_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := retry(ctx, 10, time.Hour, func() error {
+		calls++
+		cancel()
+		return errors.New("connection refused")
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,627 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/i18n"
+	"stomatology_bot/internal/platform/calendar"
+	"stomatology_bot/internal/schedule"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// isAdmin проверяет, входит ли пользователь в список администраторов
+func (b *TgBot) isAdmin(userID int64) bool {
+	for _, id := range b.cfg.Telegram.AdminIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// handleAdminCommand обрабатывает команды администратора.
+// Возвращает false, если команда не относится к администрированию или отправитель не администратор.
+func (b *TgBot) handleAdminCommand(ctx context.Context, message *tgbot.Message) bool {
+	if message.From == nil || !b.isAdmin(message.From.ID) {
+		return false
+	}
+
+	chatID := message.Chat.ID
+	args := strings.Fields(message.CommandArguments())
+
+	switch message.Command() {
+	case "today":
+		b.handleAdminDay(ctx, chatID, 0)
+	case "tomorrow":
+		b.handleAdminDay(ctx, chatID, 1)
+	case "week":
+		b.handleAdminWeek(ctx, chatID)
+	case "find":
+		b.handleAdminFind(ctx, chatID, args)
+	case "cancel":
+		b.handleAdminCancel(ctx, chatID, args)
+	case "done":
+		b.handleAdminStatus(ctx, chatID, args, booking.StatusCompleted)
+	case "noshow":
+		b.handleAdminStatus(ctx, chatID, args, booking.StatusNoShow)
+	case "block":
+		b.handleAdminBlock(ctx, chatID, args)
+	case "doctors":
+		b.handleAdminDoctors(ctx, chatID)
+	case "closures":
+		b.handleAdminClosures(ctx, chatID)
+	case "close":
+		b.handleAdminClose(ctx, chatID, args)
+	case "open":
+		b.handleAdminOpen(ctx, chatID, args)
+	case "import_holidays":
+		b.handleAdminImportHolidays(ctx, chatID)
+	default:
+		return false
+	}
+	return true
+}
+
+func (b *TgBot) handleAdminDay(ctx context.Context, chatID int64, offsetDays int) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		b.replyStaff(chatID, "admin.server_error")
+		return
+	}
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day()+offsetDays, 0, 0, 0, 0, loc)
+	staff := i18n.Default()
+	title := staff.T("admin.schedule_day", i18n.Vars{"Date": staff.Date(from)})
+	b.sendSchedule(ctx, chatID, from, from.AddDate(0, 0, 1), title)
+}
+
+func (b *TgBot) handleAdminWeek(ctx context.Context, chatID int64) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		b.replyStaff(chatID, "admin.server_error")
+		return
+	}
+	now := time.Now().In(loc)
+	from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	to := from.AddDate(0, 0, 7)
+	staff := i18n.Default()
+	title := staff.T("admin.schedule_week", i18n.Vars{
+		"From": staff.Date(from),
+		"To":   staff.Date(to.AddDate(0, 0, -1)),
+	})
+	b.sendSchedule(ctx, chatID, from, to, title)
+}
+
+// sendSchedule отправляет администратору список записей в интервале [from, to) во всех статусах
+func (b *TgBot) sendSchedule(ctx context.Context, chatID int64, from, to time.Time, title string) {
+	bookings, err := b.repo.GetUpcomingBookings(ctx, from, to, nil)
+	if err != nil {
+		logrus.WithError(err).WithFields(logrus.Fields{"from": from, "to": to}).Error("Failed to get bookings for admin")
+		b.replyStaff(chatID, "admin.bookings_error")
+		return
+	}
+	b.replyStaff(chatID, "admin.schedule", i18n.Vars{
+		"Title":    title,
+		"Bookings": formatAdminBookings(bookings, from.Location(), b.doctorNames(ctx)),
+	})
+}
+
+func (b *TgBot) handleAdminFind(ctx context.Context, chatID int64, args []string) {
+	query := digitsOnly(strings.Join(args, ""))
+	// Сравниваем по последним 10 цифрам, чтобы 8 и +7 в начале номера не мешали поиску
+	if len(query) > 10 {
+		query = query[len(query)-10:]
+	}
+	if query == "" {
+		b.replyStaff(chatID, "admin.find_usage")
+		return
+	}
+
+	all, err := b.repo.GetAllBooking(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get all bookings for admin search")
+		b.replyStaff(chatID, "admin.bookings_error")
+		return
+	}
+
+	var found []booking.Booking
+	for _, item := range all {
+		if strings.Contains(digitsOnly(item.Contact), query) {
+			found = append(found, item)
+		}
+	}
+	if len(found) == 0 {
+		b.replyStaff(chatID, "admin.find_none")
+		return
+	}
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		loc = time.UTC
+	}
+	b.replyStaff(chatID, "admin.find_result", i18n.Vars{"Bookings": formatAdminBookings(found, loc, b.doctorNames(ctx))})
+}
+
+func (b *TgBot) handleAdminCancel(ctx context.Context, chatID int64, args []string) {
+	if len(args) == 0 {
+		b.replyStaff(chatID, "admin.cancel_usage")
+		return
+	}
+	bookingID, err := strconv.Atoi(args[0])
+	if err != nil {
+		b.replyStaff(chatID, "admin.invalid_id")
+		return
+	}
+
+	bookingToCancel, err := b.repo.GetBookingByID(ctx, bookingID)
+	if err != nil {
+		logrus.WithError(err).WithField("bookingID", bookingID).Error("Failed to get booking by ID for admin cancellation")
+		b.replyStaff(chatID, "admin.not_found")
+		return
+	}
+	if !bookingToCancel.Status.Active() {
+		b.replyStaff(chatID, "admin.inactive")
+		return
+	}
+
+	if bookingToCancel.EventID != nil {
+		if err := b.deleteBookingEvent(ctx, bookingToCancel); err != nil {
+			logrus.WithError(err).WithField("eventID", *bookingToCancel.EventID).Error("Failed to delete calendar event")
+			b.replyStaff(chatID, "admin.cancel_calendar_error")
+			return
+		}
+	}
+
+	reason := strings.Join(args[1:], " ")
+	if err := b.repo.UpdateStatus(ctx, bookingID, booking.StatusCancelledByClinic, reason); err != nil {
+		logrus.WithError(err).WithField("bookingID", bookingID).Error("CRITICAL: failed to cancel booking in DB after calendar event was deleted")
+		b.replyStaff(chatID, "admin.cancel_db_error")
+		return
+	}
+
+	b.replyStaff(chatID, "admin.cancelled", i18n.Vars{"ID": bookingID})
+
+	// Пациенту пишем на его языке, а не на языке администратора
+	l := i18n.For(b.userLanguage(ctx, bookingToCancel.UserID, nil))
+	b.sendMessage(bookingToCancel.UserID, l.T("cancel.by_clinic", i18n.Vars{
+		"Datetime": l.DateTime(inMoscow(bookingToCancel.Datetime)),
+		"Reason":   reason,
+	}))
+}
+
+// handleAdminStatus отмечает итог приема: /done - прием состоялся, /noshow - пациент не пришел
+func (b *TgBot) handleAdminStatus(ctx context.Context, chatID int64, args []string, status booking.Status) {
+	if len(args) != 1 {
+		b.replyStaff(chatID, "admin.status_usage")
+		return
+	}
+	bookingID, err := strconv.Atoi(args[0])
+	if err != nil {
+		b.replyStaff(chatID, "admin.invalid_id")
+		return
+	}
+
+	err = b.repo.UpdateStatus(ctx, bookingID, status, "")
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		b.replyStaff(chatID, "admin.not_found")
+	case errors.Is(err, booking.ErrInvalidTransition):
+		b.replyStaff(chatID, "admin.status_locked")
+	case err != nil:
+		logrus.WithError(err).WithFields(logrus.Fields{"bookingID": bookingID, "status": status}).Error("Failed to update booking status")
+		b.replyStaff(chatID, "admin.status_error")
+	default:
+		b.replyStaff(chatID, "admin.status_changed", i18n.Vars{"ID": bookingID, "Status": statusLabel(status)})
+	}
+}
+
+func (b *TgBot) handleAdminBlock(ctx context.Context, chatID int64, args []string) {
+	if len(args) != 2 && len(args) != 3 {
+		b.replyStaff(chatID, "admin.block_usage")
+		return
+	}
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		b.replyStaff(chatID, "admin.server_error")
+		return
+	}
+
+	start, end, err := parseBlockArgs(args[0], args[1], loc)
+	if err != nil {
+		b.replyStaff(chatID, "admin.invalid_args", i18n.Vars{"Error": argErrorText(err)})
+		return
+	}
+
+	// Без ID врача блокируем время у всех врачей клиники
+	var doctorIDs []int
+	if len(args) == 3 {
+		doctorID, err := strconv.Atoi(args[2])
+		if err != nil {
+			b.replyStaff(chatID, "admin.invalid_doctor_id")
+			return
+		}
+		doctorIDs = []int{doctorID}
+	} else {
+		doctors, err := b.doctors.GetActiveDoctors(ctx)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to get doctors")
+			b.replyStaff(chatID, "admin.doctors_retry")
+			return
+		}
+		for _, d := range doctors {
+			doctorIDs = append(doctorIDs, d.ID)
+		}
+		if len(doctorIDs) == 0 {
+			doctorIDs = []int{0} // Общий календарь клиники
+		}
+	}
+
+	staff := i18n.Default()
+	summary, description := staff.T("admin.event.block_summary"), staff.T("admin.event.block_description")
+	// Блокировка применяется ко всем врачам или ни к одному: при ошибке созданные события удаляются
+	type blockEvent struct {
+		cal calendar.Provider
+		id  string
+	}
+	var created []blockEvent
+	rollback := func() {
+		for _, event := range created {
+			if err := event.cal.DeleteEvent(ctx, event.id); err != nil {
+				logrus.WithError(err).WithField("eventID", event.id).Error("Failed to roll back blocking event")
+			}
+		}
+	}
+	var links []string
+	for _, doctorID := range doctorIDs {
+		cal, _, err := b.calendarFor(ctx, doctorID)
+		if err != nil {
+			logrus.WithError(err).WithField("doctorID", doctorID).Error("Failed to get doctor calendar")
+			rollback()
+			b.replyStaff(chatID, "admin.doctor_not_found", i18n.Vars{"ID": doctorID})
+			return
+		}
+		link, eventID, err := cal.CreateEvent(ctx, summary, description, start, end)
+		if err != nil {
+			logrus.WithError(err).WithFields(logrus.Fields{"start": start, "end": end, "doctorID": doctorID}).Error("Failed to create blocking event")
+			rollback()
+			b.replyStaff(chatID, "admin.block_error")
+			return
+		}
+		created = append(created, blockEvent{cal: cal, id: eventID})
+		links = append(links, link)
+	}
+
+	b.replyStaff(chatID, "admin.blocked", i18n.Vars{
+		"Date":  staff.Date(start),
+		"From":  staff.Time(start),
+		"To":    staff.Time(end),
+		"Links": strings.Join(links, "\n"),
+	})
+}
+
+func (b *TgBot) handleAdminDoctors(ctx context.Context, chatID int64) {
+	doctors, err := b.doctors.GetActiveDoctors(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get doctors")
+		b.replyStaff(chatID, "admin.doctors_error")
+		return
+	}
+	if len(doctors) == 0 {
+		b.replyStaff(chatID, "admin.doctors_none")
+		return
+	}
+
+	staff := i18n.Default()
+	var sb strings.Builder
+	for _, d := range doctors {
+		week, err := d.Week(b.cfg.Telegram.WorkSchedule)
+		if err != nil {
+			logrus.WithError(err).WithField("doctorID", d.ID).Error("Failed to parse doctor schedule")
+			sb.WriteString(staff.T("admin.doctor_line_invalid", i18n.Vars{"ID": d.ID, "Doctor": d.Title(), "Schedule": d.Schedule}) + "\n")
+			continue
+		}
+		sb.WriteString(staff.T("admin.doctor_line", i18n.Vars{"ID": d.ID, "Doctor": d.Title(), "Schedule": week.String()}) + "\n")
+	}
+	b.replyStaff(chatID, "admin.doctors", i18n.Vars{"Doctors": sb.String()})
+}
+
+func (b *TgBot) handleAdminClosures(ctx context.Context, chatID int64) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		b.replyStaff(chatID, "admin.server_error")
+		return
+	}
+	today := time.Now().In(loc)
+
+	closures, err := b.closures.GetClosures(ctx, today, today.AddDate(1, 0, 0))
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get closures")
+		b.replyStaff(chatID, "admin.closures_error")
+		return
+	}
+	if len(closures) == 0 {
+		b.replyStaff(chatID, "admin.closures_none")
+		return
+	}
+
+	doctorNames := b.doctorNames(ctx)
+	staff := i18n.Default()
+	var sb strings.Builder
+	for _, c := range closures {
+		doctorName := ""
+		if c.DoctorID != nil {
+			doctorName = doctorNames[*c.DoctorID]
+		}
+		sb.WriteString(staff.T("admin.closure_line", i18n.Vars{
+			"ID":       c.ID,
+			"Period":   c.Period(staff.Date),
+			"Reason":   c.Reason,
+			"Doctor":   doctorName,
+			"Imported": c.Source == schedule.SourceProductionCalendar,
+		}) + "\n")
+	}
+	b.replyStaff(chatID, "admin.closures", i18n.Vars{"Closures": sb.String()})
+}
+
+func (b *TgBot) handleAdminClose(ctx context.Context, chatID int64, args []string) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		b.replyStaff(chatID, "admin.server_error")
+		return
+	}
+
+	closure, err := parseCloseArgs(args, loc)
+	if err != nil {
+		b.replyStaff(chatID, "admin.close_usage", i18n.Vars{"Error": argErrorText(err)})
+		return
+	}
+
+	if err := b.closures.AddClosure(ctx, closure); err != nil {
+		logrus.WithError(err).WithField("closure", closure).Error("Failed to add closure")
+		b.replyStaff(chatID, "admin.close_error")
+		return
+	}
+
+	// Предупреждаем о записях, которые уже стоят на закрываемые дни
+	bookings, err := b.repo.GetUpcomingBookings(ctx, closure.DateFrom, closure.DateTo.AddDate(0, 0, 1), booking.ActiveStatuses)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get bookings for closure period")
+	}
+	var affected []booking.Booking
+	for _, item := range bookings {
+		if closure.AppliesTo(bookingDoctorID(&item)) {
+			affected = append(affected, item)
+		}
+	}
+	affectedList := ""
+	if len(affected) > 0 {
+		affectedList = formatAdminBookings(affected, loc, b.doctorNames(ctx))
+	}
+	b.replyStaff(chatID, "admin.closed", i18n.Vars{"Period": closure.Period(i18n.Default().Date), "ID": closure.ID, "Bookings": affectedList})
+}
+
+func (b *TgBot) handleAdminOpen(ctx context.Context, chatID int64, args []string) {
+	if len(args) != 1 {
+		b.replyStaff(chatID, "admin.open_usage")
+		return
+	}
+	closureID, err := strconv.Atoi(args[0])
+	if err != nil {
+		b.replyStaff(chatID, "admin.invalid_closure_id")
+		return
+	}
+
+	if err := b.closures.DeleteClosure(ctx, closureID); err != nil {
+		logrus.WithError(err).WithField("closureID", closureID).Error("Failed to delete closure")
+		b.replyStaff(chatID, "admin.open_not_found")
+		return
+	}
+	b.replyStaff(chatID, "admin.opened", i18n.Vars{"ID": closureID})
+}
+
+func (b *TgBot) handleAdminImportHolidays(ctx context.Context, chatID int64) {
+	path := b.cfg.Telegram.HolidaysFile
+	if path == "" {
+		b.replyStaff(chatID, "admin.holidays_not_configured")
+		return
+	}
+
+	imported, err := b.closures.ImportProductionCalendarFile(ctx, path)
+	if err != nil {
+		logrus.WithError(err).WithField("path", path).Error("Failed to import production calendar")
+		b.replyStaff(chatID, "admin.holidays_error")
+		return
+	}
+	b.replyStaff(chatID, "admin.holidays_imported", i18n.Vars{"Count": imported})
+}
+
+// notifyAdmins отправляет сообщение по шаблону key всем администраторам
+func (b *TgBot) notifyAdmins(key string, vars i18n.Vars) {
+	text := i18n.Default().T(key, vars)
+	for _, adminID := range b.cfg.Telegram.AdminIDs {
+		b.sendMessage(adminID, text)
+	}
+}
+
+// replyStaff отправляет администратору сообщение из каталога на языке клиники
+func (b *TgBot) replyStaff(chatID int64, key string, vars ...i18n.Vars) {
+	b.sendMessage(chatID, i18n.Default().T(key, vars...))
+}
+
+// Ошибки разбора аргументов команд администратора; текст для администратора - в шаблонах admin.arg.*
+var (
+	errArgDate      = errors.New("invalid date")
+	errArgHour      = errors.New("invalid hour")
+	errArgHours     = errors.New("invalid hours range")
+	errArgNoDate    = errors.New("date is missing")
+	errArgDateOrder = errors.New("end date is before start date")
+)
+
+// argError - ошибка разбора аргумента value; Unwrap возвращает одну из ошибок errArg*
+type argError struct {
+	err   error
+	value string
+}
+
+func (e *argError) Error() string {
+	return fmt.Sprintf("%v: %q", e.err, e.value)
+}
+
+func (e *argError) Unwrap() error {
+	return e.err
+}
+
+// argErrorText возвращает описание ошибки разбора аргументов на языке клиники
+func argErrorText(err error) string {
+	vars := i18n.Vars{"Value": ""}
+	var argErr *argError
+	if errors.As(err, &argErr) {
+		vars["Value"] = argErr.value
+	}
+
+	key := "admin.arg.invalid"
+	switch {
+	case errors.Is(err, errArgDate):
+		key = "admin.arg.date"
+	case errors.Is(err, errArgHour):
+		key = "admin.arg.hour"
+	case errors.Is(err, errArgHours):
+		key = "admin.arg.hours"
+	case errors.Is(err, errArgNoDate):
+		key = "admin.arg.no_date"
+	case errors.Is(err, errArgDateOrder):
+		key = "admin.arg.date_order"
+	}
+	return i18n.Default().T(key, vars)
+}
+
+// parseBlockArgs разбирает дату (02.01.2006 или 2006-01-02) и часы ("10" или "10-13")
+func parseBlockArgs(dateArg, hoursArg string, loc *time.Location) (time.Time, time.Time, error) {
+	date, err := parseAdminDate(dateArg, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	fromStr, toStr, isRange := strings.Cut(hoursArg, "-")
+	fromHour, err := strconv.Atoi(fromStr)
+	if err != nil {
+		return time.Time{}, time.Time{}, &argError{err: errArgHour, value: fromStr}
+	}
+	toHour := fromHour + 1
+	if isRange {
+		toHour, err = strconv.Atoi(toStr)
+		if err != nil {
+			return time.Time{}, time.Time{}, &argError{err: errArgHour, value: toStr}
+		}
+	}
+	if fromHour < 0 || toHour > 24 || fromHour >= toHour {
+		return time.Time{}, time.Time{}, &argError{err: errArgHours, value: hoursArg}
+	}
+
+	start := time.Date(date.Year(), date.Month(), date.Day(), fromHour, 0, 0, 0, loc)
+	end := time.Date(date.Year(), date.Month(), date.Day(), toHour, 0, 0, 0, loc)
+	return start, end, nil
+}
+
+// parseAdminDate разбирает дату в формате ДД.ММ.ГГГГ или ГГГГ-ММ-ДД
+func parseAdminDate(dateArg string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"02.01.2006", "2006-01-02"} {
+		date, err := time.ParseInLocation(layout, dateArg, loc)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, &argError{err: errArgDate, value: dateArg}
+}
+
+// parseCloseArgs разбирает аргументы /close: дата начала, необязательные дата окончания
+// и ID врача, остальное - причина закрытия
+func parseCloseArgs(args []string, loc *time.Location) (*schedule.Closure, error) {
+	if len(args) == 0 {
+		return nil, errArgNoDate
+	}
+	from, err := parseAdminDate(args[0], loc)
+	if err != nil {
+		return nil, err
+	}
+	closure := &schedule.Closure{DateFrom: from, DateTo: from, Source: schedule.SourceManual}
+	rest := args[1:]
+
+	if len(rest) > 0 {
+		if to, err := parseAdminDate(rest[0], loc); err == nil {
+			if to.Before(from) {
+				return nil, errArgDateOrder
+			}
+			closure.DateTo = to
+			rest = rest[1:]
+		}
+	}
+	if len(rest) > 0 {
+		if doctorID, err := strconv.Atoi(rest[0]); err == nil {
+			closure.DoctorID = &doctorID
+			rest = rest[1:]
+		}
+	}
+	closure.Reason = strings.Join(rest, " ")
+	return closure, nil
+}
+
+// statusLabel возвращает подпись статуса записи для администратора
+func statusLabel(status booking.Status) string {
+	return i18n.Default().T("admin.status." + string(status))
+}
+
+func formatAdminBookings(bookings []booking.Booking, loc *time.Location, doctorNames map[int]string) string {
+	sort.Slice(bookings, func(i, j int) bool {
+		return bookings[i].Datetime.Before(bookings[j].Datetime)
+	})
+
+	staff := i18n.Default()
+	var sb strings.Builder
+	for _, item := range bookings {
+		period := staff.DateTime(item.Datetime.In(loc))
+		if !item.EndTime.IsZero() {
+			period += "–" + staff.Time(item.EndTime.In(loc))
+		}
+		doctorName := ""
+		if item.DoctorID != nil {
+			doctorName = doctorNames[*item.DoctorID]
+		}
+		sb.WriteString(staff.T("admin.booking_line", i18n.Vars{
+			"Period":  period,
+			"Name":    item.Name,
+			"Contact": item.Contact,
+			"ID":      item.ID,
+			"Doctor":  doctorName,
+			"Status":  statusLabel(item.Status),
+			"Reason":  item.StatusReason,
+		}) + "\n")
+	}
+	return sb.String()
+}
+
+func digitsOnly(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,263 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stomatology_bot/configs"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/doctor"
+	"stomatology_bot/internal/schedule"
+	"stomatology_bot/internal/session"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newCommandUpdate(chatID, fromID int64, text string) tgbot.Update {
+	command, _, _ := strings.Cut(text, " ")
+	return tgbot.Update{
+		Message: &tgbot.Message{
+			Chat:     &tgbot.Chat{ID: chatID},
+			From:     &tgbot.User{ID: fromID},
+			Text:     text,
+			Entities: []tgbot.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+		},
+	}
+}
+
+func TestTgBot_AdminCommand_NotAdmin(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	adminID := gofakeit.Int64()
+	bot := &TgBot{
+		api:    mockAPI,
+		cfg:    &configs.Config{Telegram: configs.TelegramConfig{AdminIDs: []int64{adminID}}},
+		states: session.NewMemoryStore(time.Hour),
+	}
+	userID := adminID + 1
+
+	// Обычный пользователь не должен получить доступ к командам администратора
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return strings.HasPrefix(msg.Text, "Неизвестная команда")
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.processUpdate(context.Background(), newCommandUpdate(userID, userID, "/cancel 1"))
+
+	mockAPI.AssertExpectations(t)
+}
+
+func TestTgBot_AdminCommand_Today(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	adminID := gofakeit.Int64()
+	bot := &TgBot{
+		api:     mockAPI,
+		cfg:     &configs.Config{Telegram: configs.TelegramConfig{AdminIDs: []int64{adminID}}},
+		repo:    booking.NewRepo(dbMock),
+		doctors: doctor.NewRepo(dbMock),
+		states:  session.NewMemoryStore(time.Hour),
+	}
+	name := gofakeit.Name()
+	doctorID := 3
+	doctorName := gofakeit.Name()
+	eventID := gofakeit.UUID()
+
+	rows := pgxmock.NewRows([]string{"id", "user_id", "name", "contact", "datetime", "event_id", "doctor_id", "end_time", "service_id", "confirmed_at", "status", "status_reason", "status_changed_at", "patient_id"}).
+		AddRow(1, gofakeit.Int64(), name, "+79161234567", time.Now(), &eventID, &doctorID, time.Now().Add(time.Hour), nil, nil, booking.StatusScheduled, "", time.Now(), nil)
+	dbMock.ExpectQuery(`SELECT id, user_id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings WHERE datetime >= \$1`).
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg(), []string(nil)).
+		WillReturnRows(rows)
+	expectDoctors(dbMock, doctor.Doctor{ID: doctorID, Name: doctorName, CalendarID: gofakeit.Email(), Active: true})
+
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return msg.ChatID == adminID && strings.Contains(msg.Text, name) && strings.Contains(msg.Text, doctorName) &&
+			strings.Contains(msg.Text, "не подтверждено")
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, "/today"))
+
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_AdminCommand_Find(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	adminID := gofakeit.Int64()
+	bot := &TgBot{
+		api:     mockAPI,
+		cfg:     &configs.Config{Telegram: configs.TelegramConfig{AdminIDs: []int64{adminID}}},
+		repo:    booking.NewRepo(dbMock),
+		doctors: doctor.NewRepo(dbMock),
+		states:  session.NewMemoryStore(time.Hour),
+	}
+
+	rows := pgxmock.NewRows([]string{"id", "name", "contact", "datetime", "event_id", "doctor_id", "end_time", "service_id", "confirmed_at", "status", "status_reason", "status_changed_at", "patient_id"}).
+		AddRow(1, "Иван Петров", "+79161234567", gofakeit.Date(), nil, nil, gofakeit.Date(), nil, nil, booking.StatusScheduled, "", gofakeit.Date(), nil).
+		AddRow(2, "Анна Смирнова", "+79035554433", gofakeit.Date(), nil, nil, gofakeit.Date(), nil, nil, booking.StatusCancelledByPatient, "", gofakeit.Date(), nil)
+	dbMock.ExpectQuery(`SELECT id, name, contact, datetime, event_id, doctor_id, end_time, service_id, confirmed_at, status, status_reason, status_changed_at, patient_id FROM bookings`).
+		WillReturnRows(rows)
+	expectDoctors(dbMock)
+
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return strings.Contains(msg.Text, "Иван Петров") && !strings.Contains(msg.Text, "Анна Смирнова")
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, "/find 8 (916) 123-45-67"))
+
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestParseBlockArgs(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+
+	start, end, err := parseBlockArgs("24.10.2025", "10-13", loc)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 24, 10, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2025, 10, 24, 13, 0, 0, 0, loc), end)
+
+	start, end, err = parseBlockArgs("2025-10-24", "15", loc)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 10, 24, 15, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2025, 10, 24, 16, 0, 0, 0, loc), end)
+
+	_, _, err = parseBlockArgs("24/10/2025", "10", loc)
+	assert.ErrorIs(t, err, errArgDate)
+	assert.Equal(t, "неверный формат даты «24/10/2025»", argErrorText(err))
+	_, _, err = parseBlockArgs("24.10.2025", "13-10", loc)
+	assert.ErrorIs(t, err, errArgHours)
+	_, _, err = parseBlockArgs("24.10.2025", "abc", loc)
+	assert.ErrorIs(t, err, errArgHour)
+	assert.Equal(t, "неверный час «abc»", argErrorText(err))
+}
+
+func TestTgBot_AdminCommand_Open(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	adminID := gofakeit.Int64()
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      &configs.Config{Telegram: configs.TelegramConfig{AdminIDs: []int64{adminID}}},
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+
+	dbMock.ExpectExec(`DELETE FROM clinic_closures WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return msg.ChatID == adminID && strings.Contains(msg.Text, "Закрытие 3 отменено")
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, "/open 3"))
+
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestParseCloseArgs(t *testing.T) {
+	loc := time.FixedZone("MSK", 3*60*60)
+
+	closure, err := parseCloseArgs([]string{"31.12.2025", "08.01.2026", "2", "Новогодние", "праздники"}, loc)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 12, 31, 0, 0, 0, 0, loc), closure.DateFrom)
+	assert.Equal(t, time.Date(2026, 1, 8, 0, 0, 0, 0, loc), closure.DateTo)
+	if assert.NotNil(t, closure.DoctorID) {
+		assert.Equal(t, 2, *closure.DoctorID)
+	}
+	assert.Equal(t, "Новогодние праздники", closure.Reason)
+	assert.Equal(t, schedule.SourceManual, closure.Source)
+
+	// Одна дата без врача закрывает всю клинику на день
+	closure, err = parseCloseArgs([]string{"24.10.2025", "Санитарный", "день"}, loc)
+	assert.NoError(t, err)
+	assert.Equal(t, closure.DateFrom, closure.DateTo)
+	assert.Nil(t, closure.DoctorID)
+	assert.Equal(t, "Санитарный день", closure.Reason)
+
+	_, err = parseCloseArgs(nil, loc)
+	assert.ErrorIs(t, err, errArgNoDate)
+	assert.Equal(t, "не указана дата", argErrorText(err))
+	_, err = parseCloseArgs([]string{"24/10/2025"}, loc)
+	assert.ErrorIs(t, err, errArgDate)
+	_, err = parseCloseArgs([]string{"24.10.2025", "23.10.2025"}, loc)
+	assert.ErrorIs(t, err, errArgDateOrder)
+	_, err = parseCloseArgs([]string{"24.10.2025", "20.10.2025"}, loc)
+	assert.Error(t, err)
+}
+
+func TestTgBot_AdminCommand_StatusLifecycle(t *testing.T) {
+	adminID := gofakeit.Int64()
+	bot, _, _, sent := newScenarioBot(t, adminID)
+	chatID := gofakeit.Int64()
+	start := time.Now().Add(24 * time.Hour)
+	visit := &booking.Booking{UserID: chatID, Name: "Иван Петров", Contact: "+79161234567", Datetime: start, EndTime: start.Add(time.Hour)}
+	done := &booking.Booking{UserID: chatID, Name: "Иван Петров", Contact: "+79161234567", Datetime: start.Add(2 * time.Hour), EndTime: start.Add(3 * time.Hour)}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), done))
+
+	// Причина отмены сохраняется в записи и передается пациенту
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, fmt.Sprintf("/cancel %d Врач заболел", visit.ID)))
+	assert.Contains(t, sent.last(chatID).Text, "Причина: Врач заболел.")
+	cancelled, err := bot.repo.GetBookingByID(context.Background(), visit.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, booking.StatusCancelledByClinic, cancelled.Status)
+	assert.Equal(t, "Врач заболел", cancelled.StatusReason)
+
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, fmt.Sprintf("/cancel %d", visit.ID)))
+	assert.Equal(t, "Эта запись уже отменена или завершена.", sent.last(adminID).Text)
+
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, fmt.Sprintf("/done %d", done.ID)))
+	assert.Equal(t, fmt.Sprintf("Запись %d: прием состоялся.", done.ID), sent.last(adminID).Text)
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, fmt.Sprintf("/noshow %d", done.ID)))
+	assert.Contains(t, sent.last(adminID).Text, "статус нельзя изменить")
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, "/noshow 100"))
+	assert.Equal(t, "Не удалось найти указанную запись.", sent.last(adminID).Text)
+
+	// Пациент больше не видит завершенные и отмененные записи
+	bookings, err := bot.repo.GetUserBookings(context.Background(), chatID, booking.ActiveStatuses)
+	assert.NoError(t, err)
+	assert.Empty(t, bookings)
+}
+
+func TestTgBot_AdminBlock_RollsBackOnFailure(t *testing.T) {
+	adminID := gofakeit.Int64()
+	bot, mockCalendar, dbMock, sent := newScenarioBot(t, adminID)
+	first, second := new(MockCalendarService), new(MockCalendarService)
+
+	expectDoctors(dbMock,
+		doctor.Doctor{ID: 1, Name: "Иванов", Specialty: "Терапевт", CalendarID: gofakeit.Email(), Active: true},
+		doctor.Doctor{ID: 2, Name: "Петров", Specialty: "Хирург", CalendarID: gofakeit.Email(), Active: true})
+	expectDoctor(dbMock, 1, "")
+	expectDoctor(dbMock, 2, "")
+	mockCalendar.On("ForCalendar", mock.Anything, 1, mock.Anything).Return(first)
+	mockCalendar.On("ForCalendar", mock.Anything, 2, mock.Anything).Return(second)
+	first.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("https://calendar/1", "event-1", nil).Once()
+	second.On("CreateEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return("", "", errors.New("quota exceeded")).Once()
+	// Время не должно остаться заблокированным только у первого врача
+	first.On("DeleteEvent", "event-1").Return(nil).Once()
+
+	bot.processUpdate(context.Background(), newCommandUpdate(adminID, adminID, "/block 24.10.2030 10-13"))
+
+	assert.Equal(t, "Не удалось заблокировать время. Попробуйте позже.", sent.last(adminID).Text)
+	first.AssertExpectations(t)
+	second.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Действия inline-кнопок
+const (
+	actionBook         = "book"
+	actionMyBookings   = "my_bookings"
+	actionDoctor       = "doctor"
+	actionService      = "service"
+	actionDate         = "date"
+	actionTime         = "time"
+	actionCancel       = "cancel"
+	actionReschedule   = "reschedule"
+	actionConfirm      = "confirm"
+	actionLanguage     = "language"
+	actionUseProfile   = "use_profile"
+	actionNewDetails   = "new_details"
+	actionProfile      = "profile"
+	actionProfileEdit  = "profile_edit" // Аргумент - поле профиля: name, phone, birth_date, notes
+	actionForPatient   = "for_patient"  // Аргумент - ID профиля члена семьи
+	actionFamilyAdd    = "family_add"
+	actionFamilyRemove = "family_remove" // Аргумент - ID профиля члена семьи
+)
+
+const (
+	// Telegram ограничивает callback_data 64 байтами
+	maxCallbackDataLen = 64
+	// Длина подписи в байтах до кодирования в base64 (96 бит)
+	callbackSignatureLen = 12
+	callbackSeparator    = ":"
+	// Формат даты в аргументах кнопок
+	callbackDateLayout = "20060102"
+)
+
+var (
+	errCallbackTooLong = errors.New("callback data exceeds 64 bytes")
+	errCallbackInvalid = errors.New("callback data is malformed or tampered")
+	errCallbackExpired = errors.New("callback data has expired")
+)
+
+// callbackData - действие inline-кнопки и его аргументы
+type callbackData struct {
+	Action string
+	Args   []string
+}
+
+// arg возвращает i-й аргумент или пустую строку, если аргументов меньше
+func (d callbackData) arg(i int) string {
+	if i >= len(d.Args) {
+		return ""
+	}
+	return d.Args[i]
+}
+
+// callbackCodec кодирует данные кнопок в виде "действие:аргументы:время выдачи:подпись".
+// Подпись HMAC-SHA256 привязана к чату, поэтому кнопку нельзя подделать или переслать в другой чат,
+// а время выдачи ограничивает срок действия кнопки
+type callbackCodec struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+func newCallbackCodec(secret string, ttl time.Duration) *callbackCodec {
+	return &callbackCodec{secret: []byte(secret), ttl: ttl, now: time.Now}
+}
+
+// encode подписывает действие и аргументы для кнопки в чате chatID
+func (c *callbackCodec) encode(chatID int64, action string, args ...string) (string, error) {
+	parts := append([]string{action}, args...)
+	for _, part := range parts {
+		if strings.Contains(part, callbackSeparator) {
+			return "", fmt.Errorf("callback argument %q contains %q", part, callbackSeparator)
+		}
+	}
+	parts = append(parts, strconv.FormatInt(c.now().Unix(), 36))
+
+	payload := strings.Join(parts, callbackSeparator)
+	data := payload + callbackSeparator + c.sign(chatID, payload)
+	if len(data) > maxCallbackDataLen {
+		return "", errCallbackTooLong
+	}
+	return data, nil
+}
+
+// decode проверяет подпись и срок действия данных кнопки, нажатой в чате chatID
+func (c *callbackCodec) decode(chatID int64, data string) (callbackData, error) {
+	if len(data) > maxCallbackDataLen {
+		return callbackData{}, errCallbackTooLong
+	}
+	i := strings.LastIndex(data, callbackSeparator)
+	if i < 0 {
+		return callbackData{}, errCallbackInvalid
+	}
+	payload, signature := data[:i], data[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(c.sign(chatID, payload))) {
+		return callbackData{}, errCallbackInvalid
+	}
+
+	parts := strings.Split(payload, callbackSeparator)
+	if len(parts) < 2 {
+		return callbackData{}, errCallbackInvalid
+	}
+	issued, err := strconv.ParseInt(parts[len(parts)-1], 36, 64)
+	if err != nil {
+		return callbackData{}, errCallbackInvalid
+	}
+	if c.now().Sub(time.Unix(issued, 0)) > c.ttl {
+		return callbackData{}, errCallbackExpired
+	}
+	return callbackData{Action: parts[0], Args: parts[1 : len(parts)-1]}, nil
+}
+
+func (c *callbackCodec) sign(chatID int64, payload string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	fmt.Fprintf(mac, "%d%s%s", chatID, callbackSeparator, payload)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSignatureLen])
+}
+
+// callbacks возвращает кодек с секретом и сроком действия кнопок из конфигурации
+func (b *TgBot) callbacks() *callbackCodec {
+	return newCallbackCodec(b.cfg.Telegram.CallbackSecret, time.Duration(b.cfg.Telegram.CallbackTTLHours)*time.Hour)
+}
+
+// button создает inline-кнопку с подписанными данными для чата chatID
+func (b *TgBot) button(chatID int64, text, action string, args ...string) tgbot.InlineKeyboardButton {
+	data, err := b.callbacks().encode(chatID, action, args...)
+	if err != nil {
+		logrus.WithError(err).WithField("action", action).Error("Failed to encode callback data")
+	}
+	return tgbot.NewInlineKeyboardButtonData(text, data)
+}
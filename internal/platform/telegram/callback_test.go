@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCallbackCodec_RoundTrip(t *testing.T) {
+	codec := newCallbackCodec("secret", time.Hour)
+	chatID := gofakeit.Int64()
+
+	data, err := codec.encode(chatID, actionTime, strconv.FormatInt(time.Now().Unix(), 36))
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(data), maxCallbackDataLen)
+
+	decoded, err := codec.decode(chatID, data)
+	assert.NoError(t, err)
+	assert.Equal(t, actionTime, decoded.Action)
+	assert.Len(t, decoded.Args, 1)
+
+	// Действие без аргументов
+	data, err = codec.encode(chatID, actionMyBookings)
+	assert.NoError(t, err)
+	decoded, err = codec.decode(chatID, data)
+	assert.NoError(t, err)
+	assert.Equal(t, callbackData{Action: actionMyBookings, Args: []string{}}, decoded)
+	assert.Equal(t, "", decoded.arg(0))
+}
+
+func TestCallbackCodec_RejectsTampered(t *testing.T) {
+	codec := newCallbackCodec("secret", time.Hour)
+	chatID := gofakeit.Int64()
+	data, err := codec.encode(chatID, actionCancel, "5")
+	assert.NoError(t, err)
+
+	_, err = codec.decode(chatID, strings.Replace(data, "cancel:5:", "cancel:6:", 1))
+	assert.ErrorIs(t, err, errCallbackInvalid)
+	// Кнопку нельзя переслать в другой чат
+	_, err = codec.decode(chatID+1, data)
+	assert.ErrorIs(t, err, errCallbackInvalid)
+	// Другой ключ - другая подпись
+	_, err = newCallbackCodec("other", time.Hour).decode(chatID, data)
+	assert.ErrorIs(t, err, errCallbackInvalid)
+	// Данные старого формата без подписи
+	_, err = codec.decode(chatID, "cancel_5")
+	assert.ErrorIs(t, err, errCallbackInvalid)
+	_, err = codec.decode(chatID, "")
+	assert.ErrorIs(t, err, errCallbackInvalid)
+}
+
+func TestCallbackCodec_Expired(t *testing.T) {
+	codec := newCallbackCodec("secret", time.Hour)
+	issued := time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC)
+	codec.now = func() time.Time { return issued }
+	data, err := codec.encode(1, actionConfirm, "7")
+	assert.NoError(t, err)
+
+	codec.now = func() time.Time { return issued.Add(time.Hour) }
+	_, err = codec.decode(1, data)
+	assert.NoError(t, err)
+
+	codec.now = func() time.Time { return issued.Add(time.Hour + time.Second) }
+	_, err = codec.decode(1, data)
+	assert.ErrorIs(t, err, errCallbackExpired)
+}
+
+func TestCallbackCodec_Limits(t *testing.T) {
+	codec := newCallbackCodec("secret", time.Hour)
+
+	_, err := codec.encode(1, actionDate, strings.Repeat("9", 40))
+	assert.ErrorIs(t, err, errCallbackTooLong)
+	_, err = codec.decode(1, strings.Repeat("a", maxCallbackDataLen+1))
+	assert.ErrorIs(t, err, errCallbackTooLong)
+
+	_, err = codec.encode(1, actionDate, "2025:10:24")
+	assert.Error(t, err)
+}
+
+func TestTgBot_handleCallbackQuery_RejectsUnsignedData(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	mockRepo := new(MockBookingRepo)
+	bot := &TgBot{api: mockAPI, cfg: newTestConfig(), repo: mockRepo}
+	chatID := gofakeit.Int64()
+
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return msg.ChatID == chatID && strings.HasPrefix(msg.Text, "Эта кнопка устарела")
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), newCallbackUpdate(chatID, "cancel_5"))
+
+	// Обработчик отмены не вызывается
+	mockRepo.AssertNotCalled(t, "GetBookingByID", mock.Anything)
+	mockAPI.AssertExpectations(t)
+}
@@ -0,0 +1,103 @@
+package telegram
+
+import (
+	"runtime/debug"
+	"sync"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Dispatcher распределяет обновления по чатам: обновления одного чата обрабатываются
+// строго по очереди, разные чаты - параллельно, но не более workers одновременно
+type Dispatcher struct {
+	handle func(tgbot.Update)
+	sem    chan struct{}
+
+	mu     sync.Mutex
+	queues map[int64][]tgbot.Update // Очереди необработанных обновлений по чатам
+	wg     sync.WaitGroup
+}
+
+// NewDispatcher создает диспетчер с пулом из workers обработчиков
+func NewDispatcher(workers int, handle func(tgbot.Update)) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Dispatcher{
+		handle: handle,
+		sem:    make(chan struct{}, workers),
+		queues: make(map[int64][]tgbot.Update),
+	}
+}
+
+// Dispatch ставит обновление в очередь его чата
+func (d *Dispatcher) Dispatch(update tgbot.Update) {
+	chatID := updateChatID(update)
+
+	d.mu.Lock()
+	queue, active := d.queues[chatID]
+	d.queues[chatID] = append(queue, update)
+	if !active {
+		// Для чата еще нет обработчика - запускаем его
+		d.wg.Add(1)
+		go d.run(chatID)
+	}
+	d.mu.Unlock()
+}
+
+// Wait блокируется, пока не будут обработаны все поставленные в очередь обновления
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) run(chatID int64) {
+	defer d.wg.Done()
+
+	d.sem <- struct{}{}
+	defer func() { <-d.sem }()
+
+	for {
+		d.mu.Lock()
+		queue := d.queues[chatID]
+		if len(queue) == 0 {
+			delete(d.queues, chatID)
+			d.mu.Unlock()
+			return
+		}
+		update := queue[0]
+		d.queues[chatID] = queue[1:]
+		d.mu.Unlock()
+
+		d.safeHandle(chatID, update)
+	}
+}
+
+// safeHandle обрабатывает обновление; паника в обработчике не должна останавливать бота,
+// поэтому она логируется, а очередь чата продолжает обрабатываться
+func (d *Dispatcher) safeHandle(chatID int64, update tgbot.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			logrus.WithFields(logrus.Fields{
+				"chatID":   chatID,
+				"updateID": update.UpdateID,
+				"panic":    r,
+			}).Errorf("Panic while handling update\n%s", debug.Stack())
+		}
+	}()
+	d.handle(update)
+}
+
+// updateChatID возвращает ID чата, к которому относится обновление
+func updateChatID(update tgbot.Update) int64 {
+	switch {
+	case update.Message != nil:
+		return update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return update.CallbackQuery.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.From != nil:
+		return update.CallbackQuery.From.ID
+	default:
+		return 0
+	}
+}
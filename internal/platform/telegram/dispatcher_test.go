@@ -0,0 +1,95 @@
+package telegram
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatcher_PreservesOrderWithinChat(t *testing.T) {
+	const (
+		chats      = 10
+		perChat    = 50
+		maxWorkers = 3
+	)
+
+	var (
+		mu        sync.Mutex
+		processed = make(map[int64][]int)
+		busy      = make(map[int64]bool)
+		running   int32
+		peak      int32
+	)
+
+	d := NewDispatcher(maxWorkers, func(update tgbot.Update) {
+		chatID := update.Message.Chat.ID
+
+		mu.Lock()
+		assert.False(t, busy[chatID], "updates of one chat must not run concurrently")
+		busy[chatID] = true
+		mu.Unlock()
+
+		current := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if current <= old || atomic.CompareAndSwapInt32(&peak, old, current) {
+				break
+			}
+		}
+		time.Sleep(100 * time.Microsecond)
+		atomic.AddInt32(&running, -1)
+
+		mu.Lock()
+		busy[chatID] = false
+		processed[chatID] = append(processed[chatID], update.Message.MessageID)
+		mu.Unlock()
+	})
+
+	for i := 0; i < perChat; i++ {
+		for chatID := int64(1); chatID <= chats; chatID++ {
+			d.Dispatch(tgbot.Update{Message: &tgbot.Message{MessageID: i, Chat: &tgbot.Chat{ID: chatID}}})
+		}
+	}
+	d.Wait()
+
+	assert.LessOrEqual(t, atomic.LoadInt32(&peak), int32(maxWorkers))
+	assert.Len(t, processed, chats)
+	for chatID, ids := range processed {
+		assert.Len(t, ids, perChat, "chat %d", chatID)
+		for i, id := range ids {
+			assert.Equal(t, i, id, "chat %d processed updates out of order", chatID)
+		}
+	}
+}
+
+func TestDispatcher_RecoversFromPanic(t *testing.T) {
+	var handled []int
+	d := NewDispatcher(1, func(update tgbot.Update) {
+		if update.Message.MessageID == 1 {
+			panic("handler failed")
+		}
+		handled = append(handled, update.Message.MessageID)
+	})
+
+	// Паника в обработке одного обновления не мешает обработать следующие обновления чата
+	for i := 1; i <= 3; i++ {
+		d.Dispatch(tgbot.Update{Message: &tgbot.Message{MessageID: i, Chat: &tgbot.Chat{ID: 1}}})
+	}
+	d.Wait()
+
+	assert.Equal(t, []int{2, 3}, handled)
+}
+
+func TestUpdateChatID(t *testing.T) {
+	assert.Equal(t, int64(1), updateChatID(tgbot.Update{Message: &tgbot.Message{Chat: &tgbot.Chat{ID: 1}}}))
+	assert.Equal(t, int64(2), updateChatID(tgbot.Update{CallbackQuery: &tgbot.CallbackQuery{
+		From:    &tgbot.User{ID: 3},
+		Message: &tgbot.Message{Chat: &tgbot.Chat{ID: 2}},
+	}}))
+	assert.Equal(t, int64(3), updateChatID(tgbot.Update{CallbackQuery: &tgbot.CallbackQuery{From: &tgbot.User{ID: 3}}}))
+	assert.Equal(t, int64(0), updateChatID(tgbot.Update{}))
+}
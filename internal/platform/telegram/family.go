@@ -0,0 +1,194 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/i18n"
+	"stomatology_bot/internal/patient"
+	"stomatology_bot/internal/session"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// handleForPatient записывает на выбранное время члена семьи из профиля пациента.
+// Запись остается в чате родителя: ему приходят напоминания, а в контактах указывается его телефон
+func (b *TgBot) handleForPatient(ctx context.Context, chatID int64, arg string) {
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateAwaitingProfileChoice {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	guardian, dependent := b.loadDependent(ctx, chatID, arg)
+	if dependent == nil {
+		return
+	}
+
+	// Телефона родителя еще нет, например если он сам не записывался: запрашиваем его
+	if guardian.Phone == "" {
+		state.State = StateAwaitingContact
+		state.TempName = dependent.Name
+		state.PatientID = dependent.ID
+		b.saveState(ctx, chatID, state)
+		b.askContact(ctx, chatID, "booking.ask_contact")
+		return
+	}
+	b.completeBooking(ctx, chatID, state, bookingDetails{
+		Name:      dependent.Name,
+		Contact:   guardian.Phone,
+		PatientID: &dependent.ID,
+		Guardian:  guardian.Name,
+	})
+}
+
+// loadDependent возвращает профиль пациента и его члена семьи с ID из arg.
+// Если член семьи не найден или принадлежит другому профилю, отвечает пациенту и возвращает nil
+func (b *TgBot) loadDependent(ctx context.Context, chatID int64, arg string) (*patient.Patient, *patient.Patient) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		b.reply(ctx, chatID, "callback.unknown")
+		return nil, nil
+	}
+
+	guardian, err := b.patients.GetByUserID(ctx, chatID)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load patient profile")
+		b.reply(ctx, chatID, "profile.error")
+		return nil, nil
+	}
+	dependent, err := b.patients.GetByID(ctx, id)
+	if err != nil {
+		logrus.WithError(err).WithField("patientID", id).Error("Failed to load family member")
+		b.reply(ctx, chatID, "profile.error")
+		return nil, nil
+	}
+	if guardian == nil || dependent == nil || dependent.GuardianID == nil || *dependent.GuardianID != guardian.ID {
+		logrus.WithFields(logrus.Fields{"chatID": chatID, "patientID": id}).Warn("Family member not found in patient profile")
+		b.reply(ctx, chatID, "family.not_found")
+		return nil, nil
+	}
+	return guardian, dependent
+}
+
+// saveGuardianPhone сохраняет в профиль родителя телефон, введенный при записи члена семьи,
+// если он еще не указан, и возвращает имя родителя
+func (b *TgBot) saveGuardianPhone(ctx context.Context, chatID int64, contact string) string {
+	guardian, err := b.patients.GetByUserID(ctx, chatID)
+	if err != nil || guardian == nil {
+		if err != nil {
+			logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load patient profile")
+		}
+		return ""
+	}
+	if guardian.Phone == "" {
+		guardian.Phone = contact
+		if err := b.patients.Save(ctx, guardian); err != nil {
+			logrus.WithError(err).WithField("chatID", chatID).Error("Failed to save patient profile")
+		}
+	}
+	return guardian.Name
+}
+
+// handleFamilyAdd начинает добавление члена семьи в профиль
+func (b *TgBot) handleFamilyAdd(ctx context.Context, chatID int64) {
+	// Добавление члена семьи прерывает начатую запись
+	b.releaseHold(ctx, chatID)
+	b.saveState(ctx, chatID, &session.UserState{State: StateAwaitingFamilyName})
+	b.reply(ctx, chatID, "family.ask_name")
+}
+
+func (b *TgBot) handleFamilyNameInput(ctx context.Context, update tgbot.Update) {
+	chatID := update.Message.Chat.ID
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateAwaitingFamilyName {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	name := strings.TrimSpace(update.Message.Text)
+	if name == "" {
+		b.reply(ctx, chatID, "profile.invalid_name")
+		return
+	}
+	state.State = StateAwaitingFamilyBirthDate
+	state.TempName = name
+	b.saveState(ctx, chatID, state)
+	b.reply(ctx, chatID, "family.ask_birth_date")
+}
+
+// handleFamilyBirthDateInput сохраняет члена семьи; "-" пропускает дату рождения
+func (b *TgBot) handleFamilyBirthDateInput(ctx context.Context, update tgbot.Update) {
+	chatID := update.Message.Chat.ID
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateAwaitingFamilyBirthDate {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	dependent := &patient.Patient{Name: state.TempName}
+	if text := strings.TrimSpace(update.Message.Text); text != "-" {
+		birthDate, err := parseBirthDate(text, time.Now())
+		if err != nil {
+			b.reply(ctx, chatID, "profile.invalid_birth_date")
+			return // Оставляем пользователя в том же состоянии, чтобы он мог повторить ввод
+		}
+		dependent.BirthDate = &birthDate
+	}
+
+	// Член семьи привязывается к профилю родителя; если профиля нет, создаем пустой
+	guardian, err := b.patients.GetByUserID(ctx, chatID)
+	if err == nil && guardian == nil {
+		guardian = &patient.Patient{UserID: chatID}
+		err = b.patients.Save(ctx, guardian)
+	}
+	if err == nil {
+		dependent.GuardianID = &guardian.ID
+		err = b.patients.Save(ctx, dependent)
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to save family member")
+		b.reply(ctx, chatID, "profile.save_error")
+		return
+	}
+
+	b.resetState(ctx, chatID)
+	b.reply(ctx, chatID, "family.added", i18n.Vars{"Name": dependent.Name})
+	b.sendProfile(ctx, chatID)
+}
+
+// handleFamilyRemove удаляет члена семьи из профиля; его прошлые записи остаются в истории.
+// Пока у члена семьи есть активные записи, удалить его нельзя: иначе по напоминанию о такой
+// записи родитель не поймет, кто идет на прием
+func (b *TgBot) handleFamilyRemove(ctx context.Context, chatID int64, arg string) {
+	guardian, dependent := b.loadDependent(ctx, chatID, arg)
+	if dependent == nil {
+		return
+	}
+
+	// Наличие активных записей проверяется в том же запросе, что и удаление
+	activeStatuses := make([]string, 0, len(booking.ActiveStatuses))
+	for _, status := range booking.ActiveStatuses {
+		activeStatuses = append(activeStatuses, string(status))
+	}
+	if err := b.patients.DeleteDependent(ctx, guardian.ID, dependent.ID, activeStatuses); err != nil {
+		if !errors.Is(err, patient.ErrNotRemoved) {
+			logrus.WithError(err).WithField("patientID", dependent.ID).Error("Failed to delete family member")
+			b.reply(ctx, chatID, "profile.save_error")
+			return
+		}
+		// Профиль мог быть удален в другом окне; иначе у члена семьи есть активные записи
+		if p, err := b.patients.GetByID(ctx, dependent.ID); err == nil && p == nil {
+			b.reply(ctx, chatID, "family.not_found")
+			return
+		}
+		b.reply(ctx, chatID, "family.has_bookings", i18n.Vars{"Name": dependent.Name})
+		return
+	}
+	b.reply(ctx, chatID, "family.removed", i18n.Vars{"Name": dependent.Name})
+	b.sendProfile(ctx, chatID)
+}
@@ -0,0 +1,164 @@
+package telegram
+
+import (
+	"context"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/patient"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// addFamilyMember добавляет члена семьи через /profile
+func addFamilyMember(bot *TgBot, chatID int64, name, birthDate string) {
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionFamilyAdd)))
+	bot.handleUpdate(newTextUpdate(chatID, name))
+	bot.handleUpdate(newTextUpdate(chatID, birthDate))
+}
+
+func TestTgBot_Family_BookForChild(t *testing.T) {
+	adminID := gofakeit.Int64()
+	bot, mockCalendar, _, sent := newScenarioBot(t, adminID)
+	chatID := gofakeit.Int64()
+	parent := &patient.Patient{UserID: chatID, Name: "Иван Петров", Phone: "+79161234567"}
+	assert.NoError(t, bot.patients.Save(context.Background(), parent))
+
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionFamilyAdd)))
+	assert.Contains(t, sent.last(chatID).Text, "Введите имя и фамилию члена семьи")
+	bot.handleUpdate(newTextUpdate(chatID, "Маша Петрова"))
+	// Неверная дата не сбрасывает ввод
+	bot.handleUpdate(newTextUpdate(chatID, "31.02.2015"))
+	assert.Contains(t, sent.last(chatID).Text, "Не удалось распознать дату")
+	bot.handleUpdate(newTextUpdate(chatID, "17.05.2015"))
+	assert.Contains(t, sent.last(chatID).Text, "Члены семьи:\n• Маша Петрова, 17.05.2015")
+
+	dependents, err := bot.patients.GetDependents(context.Background(), parent.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, dependents, 1) {
+		return
+	}
+	child := dependents[0]
+
+	// При записи пациент выбирает, кого записать
+	slot := pickSlot(t, bot, mockCalendar, chatID)
+	assert.Equal(t, "Кого записать на приём?", sent.last(chatID).Text)
+	keyboard := sent.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	if !assert.Len(t, keyboard.InlineKeyboard, 3) {
+		return
+	}
+	assert.Equal(t, actionUseProfile, unsign(chatID, *keyboard.InlineKeyboard[0][0].CallbackData))
+	assert.Equal(t, "👤 Маша Петрова", keyboard.InlineKeyboard[1][0].Text)
+	assert.Equal(t, actionNewDetails, unsign(chatID, *keyboard.InlineKeyboard[2][0].CallbackData))
+
+	// В календаре указан сам пациент и родитель, который его записал
+	mockCalendar.On("IsSlotFree", sameTime(slot), sameTime(slot.Add(time.Hour)), "").Return(true, nil).Once()
+	mockCalendar.On("CreateEvent", "Запись: Маша Петрова",
+		mock.MatchedBy(func(description string) bool {
+			return strings.Contains(description, "Записал(а): Иван Петров")
+		}),
+		sameTime(slot), sameTime(slot.Add(time.Hour))).Return("", gofakeit.UUID(), nil).Once()
+	bot.handleUpdate(newCallbackUpdate(chatID, *keyboard.InlineKeyboard[1][0].CallbackData))
+	assert.Equal(t, "Вы успешно записаны на "+slot.Format("02.01.2006 в 15:04")+".", sent.last(chatID).Text)
+	assert.Contains(t, sent.last(adminID).Text, "Имя: Маша Петрова\nКонтакт: +79161234567\nЗаписал(а): Иван Петров")
+
+	bookings, err := bot.repo.GetUserBookings(context.Background(), chatID, booking.ActiveStatuses)
+	assert.NoError(t, err)
+	if !assert.Len(t, bookings, 1) {
+		return
+	}
+	assert.Equal(t, "Маша Петрова", bookings[0].Name)
+	assert.Equal(t, "+79161234567", bookings[0].Contact)
+	assert.Equal(t, &child.ID, bookings[0].PatientID)
+
+	// Напоминание приходит родителю и называет пациента
+	assert.NoError(t, bot.sendReminder(context.Background(), bookings[0], slot, slot.Add(-24*time.Hour)))
+	assert.Equal(t, "Напоминание: у пациента Маша Петрова завтра запись на 10:00", sent.last(chatID).Text)
+}
+
+func TestTgBot_Family_ParentWithoutPhone(t *testing.T) {
+	bot, mockCalendar, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+
+	// Члена семьи можно добавить до первой записи: профиль родителя создается пустым
+	addFamilyMember(bot, chatID, "Петя Петров", "-")
+	assert.Contains(t, sent.last(chatID).Text, "Члены семьи:\n• Петя Петров\n")
+
+	slot := pickSlot(t, bot, mockCalendar, chatID)
+	assert.Equal(t, "Кого записать на приём?", sent.last(chatID).Text)
+	forChild := sent.firstButton(t, chatID)
+	assert.True(t, strings.HasPrefix(unsign(chatID, forChild), actionForPatient+"_"))
+
+	// Телефон родителя запрашивается один раз и сохраняется в его профиль
+	bot.handleUpdate(newCallbackUpdate(chatID, forChild))
+	assert.True(t, sent.last(chatID).ReplyMarkup.(tgbot.ReplyKeyboardMarkup).Keyboard[0][0].RequestContact)
+	mockCalendar.On("IsSlotFree", sameTime(slot), sameTime(slot.Add(time.Hour)), "").Return(true, nil).Once()
+	mockCalendar.On("CreateEvent", "Запись: Петя Петров", mock.Anything, sameTime(slot), sameTime(slot.Add(time.Hour))).Return("", gofakeit.UUID(), nil).Once()
+	bot.handleUpdate(newTextUpdate(chatID, "+7 916 123-45-67"))
+
+	bookings, err := bot.repo.GetUserBookings(context.Background(), chatID, booking.ActiveStatuses)
+	assert.NoError(t, err)
+	if assert.Len(t, bookings, 1) {
+		assert.Equal(t, "Петя Петров", bookings[0].Name)
+		assert.NotNil(t, bookings[0].PatientID)
+	}
+	parent, err := bot.patients.GetByUserID(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, "+79161234567", parent.Phone)
+	assert.Empty(t, parent.Name)
+}
+
+func TestTgBot_Family_Remove(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+	otherChatID := gofakeit.Int64()
+	addFamilyMember(bot, chatID, "Маша Петрова", "-")
+	addFamilyMember(bot, otherChatID, "Коля Сидоров", "-")
+
+	parent, err := bot.patients.GetByUserID(context.Background(), chatID)
+	assert.NoError(t, err)
+	other, err := bot.patients.GetByUserID(context.Background(), otherChatID)
+	assert.NoError(t, err)
+	otherChildren, err := bot.patients.GetDependents(context.Background(), other.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, otherChildren, 1) {
+		return
+	}
+
+	// Чужого члена семьи удалить нельзя
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionFamilyRemove, strconv.Itoa(otherChildren[0].ID))))
+	assert.Equal(t, "Член семьи не найден. Откройте профиль командой /profile.", sent.last(chatID).Text)
+	otherChildren, err = bot.patients.GetDependents(context.Background(), other.ID)
+	assert.NoError(t, err)
+	assert.Len(t, otherChildren, 1)
+
+	children, err := bot.patients.GetDependents(context.Background(), parent.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, children, 1) {
+		return
+	}
+	removeChild := signCallback(chatID, actionFamilyRemove, strconv.Itoa(children[0].ID))
+
+	// Пока у члена семьи есть активная запись, удалить его нельзя: напоминание о ней должно называть пациента
+	start := time.Now().Add(48 * time.Hour)
+	visit := &booking.Booking{UserID: chatID, Name: "Маша Петрова", Contact: "+79161234567", Datetime: start, EndTime: start.Add(time.Hour), PatientID: &children[0].ID}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+	bot.handleUpdate(newCallbackUpdate(chatID, removeChild))
+	assert.Equal(t, "Есть предстоящие записи на приём для пациента Маша Петрова. Сначала отмените их в разделе «Мои записи», затем удалите члена семьи из профиля.", sent.last(chatID).Text)
+	children, err = bot.patients.GetDependents(context.Background(), parent.ID)
+	assert.NoError(t, err)
+	assert.Len(t, children, 1)
+
+	// После отмены записи член семьи удаляется, а запись остается в истории
+	assert.NoError(t, bot.repo.UpdateStatus(context.Background(), visit.ID, booking.StatusCancelledByPatient, ""))
+	bot.handleUpdate(newCallbackUpdate(chatID, removeChild))
+	assert.NotContains(t, sent.last(chatID).Text, "Члены семьи")
+	children, err = bot.patients.GetDependents(context.Background(), parent.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, children)
+}
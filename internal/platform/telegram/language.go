@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"stomatology_bot/internal/i18n"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// userLanguage определяет язык пациента: язык, выбранный командой /language, иначе язык
+// интерфейса Telegram, иначе сохраненный ранее. from = nil - сообщение отправляет сам бот (напоминания).
+// Язык Telegram запоминается, чтобы фоновые сообщения приходили на том же языке
+func (b *TgBot) userLanguage(ctx context.Context, chatID int64, from *tgbot.User) string {
+	prefs, err := b.prefs.Get(ctx, chatID)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load user preferences")
+	}
+	if prefs != nil && prefs.LanguageChosen {
+		return prefs.Language
+	}
+
+	if from != nil && from.LanguageCode != "" {
+		language := i18n.Match(from.LanguageCode)
+		if prefs == nil || prefs.Language != language {
+			if err := b.prefs.SetLanguage(ctx, chatID, language, false); err != nil {
+				logrus.WithError(err).WithField("chatID", chatID).Error("Failed to save user language")
+			}
+		}
+		return language
+	}
+
+	if prefs != nil {
+		return prefs.Language
+	}
+	return i18n.DefaultLanguage
+}
+
+// sendLanguageMenu предлагает выбрать язык сообщений бота
+func (b *TgBot) sendLanguageMenu(ctx context.Context, chatID int64) {
+	var row []tgbot.InlineKeyboardButton
+	for _, language := range i18n.Languages {
+		row = append(row, b.button(chatID, i18n.For(language).Name(), actionLanguage, language))
+	}
+	msg := tgbot.NewMessage(chatID, i18n.FromContext(ctx).T("language.choose"))
+	msg.ReplyMarkup = tgbot.NewInlineKeyboardMarkup(row)
+	if _, err := b.api.Send(msg); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+	}
+}
+
+// handleLanguageSelection сохраняет выбранный язык; он важнее языка интерфейса Telegram
+func (b *TgBot) handleLanguageSelection(ctx context.Context, chatID int64, language string) {
+	if !i18n.Supported(language) {
+		b.reply(ctx, chatID, "callback.unknown")
+		return
+	}
+	if err := b.prefs.SetLanguage(ctx, chatID, language, true); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to save user language")
+		b.reply(ctx, chatID, "language.error")
+		return
+	}
+
+	l := i18n.For(language)
+	ctx = i18n.WithLocalizer(ctx, l)
+	b.reply(ctx, chatID, "language.saved", i18n.Vars{"Language": l.Name()})
+	b.sendMainMenu(ctx, chatID)
+}
@@ -0,0 +1,131 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/i18n"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// newLocalizedUpdate - команда от пользователя с языком интерфейса Telegram languageCode
+func newLocalizedUpdate(chatID int64, languageCode, text string) tgbot.Update {
+	update := newCommandUpdate(chatID, chatID, text)
+	update.Message.From.LanguageCode = languageCode
+	return update
+}
+
+func TestTgBot_Language_DetectedFromTelegram(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+
+	bot.handleUpdate(newLocalizedUpdate(chatID, "en-GB", "/start"))
+	assert.Equal(t, "Welcome! Choose an action:", sent.last(chatID).Text)
+	keyboard := sent.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	assert.Equal(t, "Book an appointment", keyboard.InlineKeyboard[0][0].Text)
+
+	// Неподдерживаемый язык заменяется английским, пустой - языком клиники
+	other := gofakeit.Int64()
+	bot.handleUpdate(newLocalizedUpdate(other, "de", "/start"))
+	assert.Equal(t, "Welcome! Choose an action:", sent.last(other).Text)
+	unknown := gofakeit.Int64()
+	bot.handleUpdate(newLocalizedUpdate(unknown, "", "/start"))
+	assert.Equal(t, "Добро пожаловать! Выберите действие:", sent.last(unknown).Text)
+
+	// Определенный язык запоминается для напоминаний, но не считается выбранным
+	prefs, err := bot.prefs.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, "en", prefs.Language)
+	assert.False(t, prefs.LanguageChosen)
+}
+
+func TestTgBot_Language_Command(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	bot.cfg.Telegram.ReminderOffsets = []time.Duration{24 * time.Hour}
+	chatID := gofakeit.Int64()
+
+	bot.handleUpdate(newLocalizedUpdate(chatID, "ru", "/language"))
+	assert.Equal(t, "Выберите язык:", sent.last(chatID).Text)
+	keyboard := sent.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	assert.Len(t, keyboard.InlineKeyboard[0], 3)
+	assert.Equal(t, "Қазақша", keyboard.InlineKeyboard[0][2].Text)
+	assert.Equal(t, "language_kk", unsign(chatID, *keyboard.InlineKeyboard[0][2].CallbackData))
+
+	bot.handleUpdate(newCallbackUpdate(chatID, *keyboard.InlineKeyboard[0][2].CallbackData))
+	assert.Equal(t, "Қош келдіңіз! Әрекетті таңдаңыз:", sent.last(chatID).Text)
+
+	// Выбранный язык важнее языка интерфейса Telegram
+	bot.handleUpdate(newLocalizedUpdate(chatID, "en", "/start"))
+	assert.Equal(t, "Қош келдіңіз! Әрекетті таңдаңыз:", sent.last(chatID).Text)
+
+	// Напоминание приходит на выбранном языке
+	start := time.Now().Add(3 * time.Hour)
+	visit := &booking.Booking{UserID: chatID, Name: "Иван Петров", Contact: "+79161234567", Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+	bot.sendReminders()
+	assert.Contains(t, sent.last(chatID).Text, "Еске салу")
+	keyboard = sent.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	assert.Equal(t, "Растаймын, келемін", keyboard.InlineKeyboard[0][0].Text)
+
+	// Подделанный код языка не сохраняется
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionLanguage, "de")))
+	prefs, err := bot.prefs.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, "kk", prefs.Language)
+}
+
+func TestTgBot_MyBookings_PluralHeader(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+	start := time.Now().Add(24 * time.Hour)
+	for i := 0; i < 2; i++ {
+		visit := &booking.Booking{UserID: chatID, Name: "Иван Петров", Contact: "+79161234567",
+			Datetime: start.Add(time.Duration(i) * time.Hour), EndTime: start.Add(time.Duration(i+1) * time.Hour)}
+		assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+	}
+
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionMyBookings)))
+	var texts []string
+	for _, msg := range sent.messages {
+		if msg.ChatID == chatID {
+			texts = append(texts, msg.Text)
+		}
+	}
+	assert.Len(t, texts, 3)
+	assert.Equal(t, "У вас 2 предстоящие записи:", texts[0])
+	assert.Contains(t, texts[1], "Дата/время: ")
+}
+
+func TestTgBot_TemplateOverrides(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { _ = i18n.Load("") })
+	err := os.WriteFile(filepath.Join(dir, "ru.json"), []byte(`{
+		"messages": {
+			"menu.welcome": "Стоматология «Улыбка». Чем можем помочь?",
+			"admin.help": "Справка для администраторов клиники"
+		}
+	}`), 0o644)
+	assert.NoError(t, err)
+	assert.NoError(t, i18n.Load(dir))
+
+	adminID := gofakeit.Int64()
+	bot, _, _, sent := newScenarioBot(t, adminID)
+	chatID := gofakeit.Int64()
+
+	bot.handleUpdate(newLocalizedUpdate(chatID, "ru", "/start"))
+	assert.Equal(t, "Стоматология «Улыбка». Чем можем помочь?", sent.last(chatID).Text)
+
+	// Непереопределенные языки используют встроенные шаблоны
+	other := gofakeit.Int64()
+	bot.handleUpdate(newLocalizedUpdate(other, "en", "/start"))
+	assert.Equal(t, "Welcome! Choose an action:", sent.last(other).Text)
+
+	bot.handleUpdate(newCommandUpdate(adminID, adminID, "/help"))
+	assert.Equal(t, "Справка для администраторов клиники", sent.last(adminID).Text)
+}
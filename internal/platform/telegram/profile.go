@@ -0,0 +1,299 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"stomatology_bot/internal/i18n"
+	"stomatology_bot/internal/patient"
+	"stomatology_bot/internal/session"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Поля профиля, которые пациент редактирует командой /profile
+const (
+	profileFieldName      = "name"
+	profileFieldPhone     = "phone"
+	profileFieldBirthDate = "birth_date"
+	profileFieldNotes     = "notes"
+)
+
+// birthDateLayout - формат ввода и показа даты рождения
+const birthDateLayout = "02.01.2006"
+
+// askPatientDetails запрашивает данные пациента для новой записи на время из state.
+// Если профиль заполнен, предлагает записаться на сохраненные данные одной кнопкой,
+// а если в профиле есть члены семьи - выбрать, кого записать
+func (b *TgBot) askPatientDetails(ctx context.Context, chatID int64, state *session.UserState) {
+	p, err := b.patients.GetByUserID(ctx, chatID)
+	if err != nil {
+		// Без профиля запись все равно возможна: данные вводятся вручную
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load patient profile")
+	}
+	var dependents []patient.Patient
+	if p != nil {
+		if dependents, err = b.patients.GetDependents(ctx, p.ID); err != nil {
+			logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load family members")
+		}
+	}
+	if (p == nil || !p.Complete()) && len(dependents) == 0 {
+		state.State = StateAwaitingName
+		b.saveState(ctx, chatID, state)
+		b.reply(ctx, chatID, "booking.ask_name")
+		return
+	}
+
+	state.State = StateAwaitingProfileChoice
+	b.saveState(ctx, chatID, state)
+
+	l := i18n.FromContext(ctx)
+	text := l.T("booking.for_whom")
+	var rows [][]tgbot.InlineKeyboardButton
+	if p.Complete() {
+		if len(dependents) == 0 {
+			text = l.T("booking.use_profile", i18n.Vars{"Name": p.Name, "Phone": p.Phone})
+		}
+		rows = append(rows, tgbot.NewInlineKeyboardRow(b.button(chatID, l.T("button.use_profile"), actionUseProfile)))
+	}
+	for _, d := range dependents {
+		rows = append(rows, tgbot.NewInlineKeyboardRow(
+			b.button(chatID, l.T("button.for_patient", i18n.Vars{"Name": d.Name}), actionForPatient, strconv.Itoa(d.ID)),
+		))
+	}
+	rows = append(rows, tgbot.NewInlineKeyboardRow(b.button(chatID, l.T("button.new_details"), actionNewDetails)))
+
+	msg := tgbot.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbot.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.api.Send(msg); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+	}
+}
+
+// handleUseProfile записывает пациента на выбранное время с данными из профиля
+func (b *TgBot) handleUseProfile(ctx context.Context, chatID int64) {
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateAwaitingProfileChoice {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	p, err := b.patients.GetByUserID(ctx, chatID)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load patient profile")
+		b.reply(ctx, chatID, "profile.error")
+		return
+	}
+	// Профиль могли очистить, пока пациент выбирал время
+	if p == nil || !p.Complete() {
+		b.handleNewDetails(ctx, chatID)
+		return
+	}
+	b.completeBooking(ctx, chatID, state, bookingDetails{Name: p.Name, Contact: p.Phone, PatientID: &p.ID})
+}
+
+// handleNewDetails переходит к вводу имени и телефона, например при записи другого человека
+func (b *TgBot) handleNewDetails(ctx context.Context, chatID int64) {
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateAwaitingProfileChoice {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+	state.State = StateAwaitingName
+	b.saveState(ctx, chatID, state)
+	b.reply(ctx, chatID, "booking.ask_name")
+}
+
+// bookingPatient возвращает профиль, с которым связывается запись с введенными вручную данными.
+// Первая запись заполняет профиль; запись с другими именем или телефоном считается записью
+// другого человека и с профилем не связывается
+func (b *TgBot) bookingPatient(ctx context.Context, chatID int64, name, contact string) *int {
+	p, err := b.patients.GetByUserID(ctx, chatID)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load patient profile")
+		return nil
+	}
+	if p == nil {
+		p = &patient.Patient{UserID: chatID}
+	}
+	if (p.Name != "" && p.Name != name) || (p.Phone != "" && p.Phone != contact) {
+		return nil
+	}
+	if p.ID != 0 && p.Complete() {
+		return &p.ID
+	}
+
+	p.Name, p.Phone = name, contact
+	if err := b.patients.Save(ctx, p); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to save patient profile")
+		return nil
+	}
+	return &p.ID
+}
+
+// sendProfile показывает профиль пациента с кнопками изменения полей
+func (b *TgBot) sendProfile(ctx context.Context, chatID int64) {
+	p, err := b.patients.GetByUserID(ctx, chatID)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load patient profile")
+		b.reply(ctx, chatID, "profile.error")
+		return
+	}
+	var dependents []patient.Patient
+	if p == nil {
+		p = &patient.Patient{UserID: chatID}
+	} else if dependents, err = b.patients.GetDependents(ctx, p.ID); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load family members")
+		b.reply(ctx, chatID, "profile.error")
+		return
+	}
+
+	l := i18n.FromContext(ctx)
+	var family strings.Builder
+	for i, d := range dependents {
+		if i > 0 {
+			family.WriteString("\n")
+		}
+		family.WriteString(l.T("profile.family_member", i18n.Vars{"Name": d.Name, "BirthDate": formatBirthDate(d.BirthDate)}))
+	}
+	msg := tgbot.NewMessage(chatID, l.T("profile.view", i18n.Vars{
+		"Name":      p.Name,
+		"Phone":     p.Phone,
+		"BirthDate": formatBirthDate(p.BirthDate),
+		"Notes":     p.Notes,
+		"Family":    family.String(),
+	}))
+
+	rows := [][]tgbot.InlineKeyboardButton{
+		tgbot.NewInlineKeyboardRow(
+			b.button(chatID, l.T("button.profile_name"), actionProfileEdit, profileFieldName),
+			b.button(chatID, l.T("button.profile_phone"), actionProfileEdit, profileFieldPhone),
+		),
+		tgbot.NewInlineKeyboardRow(
+			b.button(chatID, l.T("button.profile_birth_date"), actionProfileEdit, profileFieldBirthDate),
+			b.button(chatID, l.T("button.profile_notes"), actionProfileEdit, profileFieldNotes),
+		),
+		tgbot.NewInlineKeyboardRow(b.button(chatID, l.T("button.family_add"), actionFamilyAdd)),
+	}
+	for _, d := range dependents {
+		rows = append(rows, tgbot.NewInlineKeyboardRow(
+			b.button(chatID, l.T("button.family_remove", i18n.Vars{"Name": d.Name}), actionFamilyRemove, strconv.Itoa(d.ID)),
+		))
+	}
+	msg.ReplyMarkup = tgbot.NewInlineKeyboardMarkup(rows...)
+	if _, err := b.api.Send(msg); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+	}
+}
+
+// handleProfileEdit запрашивает новое значение поля профиля
+func (b *TgBot) handleProfileEdit(ctx context.Context, chatID int64, field string) {
+	switch field {
+	case profileFieldName, profileFieldPhone, profileFieldBirthDate, profileFieldNotes:
+	default:
+		b.reply(ctx, chatID, "callback.unknown")
+		return
+	}
+
+	// Редактирование профиля прерывает начатую запись
+	b.releaseHold(ctx, chatID)
+	b.saveState(ctx, chatID, &session.UserState{State: StateEditingProfile, ProfileField: field})
+	if field == profileFieldPhone {
+		b.askContact(ctx, chatID, "profile.ask_phone")
+		return
+	}
+	b.reply(ctx, chatID, "profile.ask_"+field)
+}
+
+// handleProfileInput сохраняет новое значение поля профиля
+func (b *TgBot) handleProfileInput(ctx context.Context, update tgbot.Update) {
+	chatID := update.Message.Chat.ID
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateEditingProfile {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	p, err := b.patients.GetByUserID(ctx, chatID)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load patient profile")
+		b.reply(ctx, chatID, "profile.error")
+		return
+	}
+	if p == nil {
+		p = &patient.Patient{UserID: chatID}
+	}
+
+	// При неверном вводе состояние сохраняется, чтобы пациент мог повторить ввод
+	text := strings.TrimSpace(update.Message.Text)
+	switch state.ProfileField {
+	case profileFieldName:
+		if text == "" {
+			b.reply(ctx, chatID, "profile.invalid_name")
+			return
+		}
+		p.Name = text
+	case profileFieldPhone:
+		number, err := messagePhone(update.Message)
+		if err != nil {
+			b.askContactAgain(ctx, chatID, err)
+			return
+		}
+		p.Phone = number
+	case profileFieldBirthDate:
+		birthDate, err := parseBirthDate(text, time.Now())
+		if err != nil {
+			b.reply(ctx, chatID, "profile.invalid_birth_date")
+			return
+		}
+		p.BirthDate = &birthDate
+	case profileFieldNotes:
+		// "-" очищает заметки
+		if text == "-" {
+			text = ""
+		}
+		p.Notes = text
+	default:
+		b.resetState(ctx, chatID)
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	if err := b.patients.Save(ctx, p); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to save patient profile")
+		b.reply(ctx, chatID, "profile.save_error")
+		return
+	}
+	b.resetState(ctx, chatID)
+
+	// Убираем кнопку отправки номера, если она была показана
+	msg := tgbot.NewMessage(chatID, i18n.FromContext(ctx).T("profile.saved"))
+	msg.ReplyMarkup = tgbot.NewRemoveKeyboard(true)
+	if _, err := b.api.Send(msg); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+	}
+	b.sendProfile(ctx, chatID)
+}
+
+// formatBirthDate возвращает дату рождения в формате 02.01.2006 или пустую строку, если она не указана
+func formatBirthDate(date *time.Time) string {
+	if date == nil {
+		return ""
+	}
+	return date.Format(birthDateLayout)
+}
+
+// parseBirthDate разбирает дату рождения в формате 02.01.2006; дата не может быть в будущем
+func parseBirthDate(s string, now time.Time) (time.Time, error) {
+	date, err := time.Parse(birthDateLayout, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if date.After(now) || date.Year() < 1900 {
+		return time.Time{}, errors.New("birth date is out of range")
+	}
+	return date, nil
+}
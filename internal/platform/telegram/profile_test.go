@@ -0,0 +1,135 @@
+package telegram
+
+import (
+	"context"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/patient"
+	"stomatology_bot/internal/session"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// pickSlot выбирает время приема 24.10.2025 в 10:00 и возвращает его
+func pickSlot(t *testing.T, bot *TgBot, mockCalendar *MockCalendarService, chatID int64) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	slot := time.Date(2025, 10, 24, 10, 0, 0, 0, loc)
+	mockCalendar.On("GetFreeSlots", mock.Anything, time.Hour, "").Return([]time.Time{slot}, nil)
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionTime, strconv.FormatInt(slot.Unix(), 36))))
+	return slot
+}
+
+func TestTgBot_BookingScenario_SavedDetails(t *testing.T) {
+	bot, mockCalendar, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+	profile := &patient.Patient{UserID: chatID, Name: "Иван Петров", Phone: "+79161234567"}
+	assert.NoError(t, bot.patients.Save(context.Background(), profile))
+
+	// Вместо ввода имени предлагается записаться на данные из профиля
+	slot := pickSlot(t, bot, mockCalendar, chatID)
+	assert.Equal(t, "Записать вас с сохранёнными данными?\n\nИмя: Иван Петров\nТелефон: +79161234567", sent.last(chatID).Text)
+	useProfile := sent.firstButton(t, chatID)
+	assert.Equal(t, actionUseProfile, unsign(chatID, useProfile))
+
+	mockCalendar.On("IsSlotFree", sameTime(slot), sameTime(slot.Add(time.Hour)), "").Return(true, nil).Once()
+	mockCalendar.On("CreateEvent", "Запись: Иван Петров", mock.Anything, sameTime(slot), sameTime(slot.Add(time.Hour))).Return("", gofakeit.UUID(), nil).Once()
+	bot.handleUpdate(newCallbackUpdate(chatID, useProfile))
+	assert.Equal(t, "Вы успешно записаны на "+slot.Format("02.01.2006 в 15:04")+".", sent.last(chatID).Text)
+
+	bookings, err := bot.repo.GetUserBookings(context.Background(), chatID, booking.ActiveStatuses)
+	assert.NoError(t, err)
+	if assert.Len(t, bookings, 1) {
+		assert.Equal(t, "Иван Петров", bookings[0].Name)
+		assert.Equal(t, "+79161234567", bookings[0].Contact)
+		assert.Equal(t, &profile.ID, bookings[0].PatientID)
+	}
+}
+
+func TestTgBot_BookingScenario_OtherDetails(t *testing.T) {
+	bot, mockCalendar, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+	profile := &patient.Patient{UserID: chatID, Name: "Иван Петров", Phone: "+79161234567"}
+	assert.NoError(t, bot.patients.Save(context.Background(), profile))
+
+	slot := pickSlot(t, bot, mockCalendar, chatID)
+	keyboard := sent.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	bot.handleUpdate(newCallbackUpdate(chatID, *keyboard.InlineKeyboard[1][0].CallbackData))
+	assert.Equal(t, "Пожалуйста, введите ваше Имя и Фамилию.", sent.last(chatID).Text)
+
+	// Запись другого человека не меняет профиль и не связывается с ним
+	mockCalendar.On("IsSlotFree", sameTime(slot), sameTime(slot.Add(time.Hour)), "").Return(true, nil).Once()
+	mockCalendar.On("CreateEvent", "Запись: Мария Петрова", mock.Anything, sameTime(slot), sameTime(slot.Add(time.Hour))).Return("", gofakeit.UUID(), nil).Once()
+	bot.handleUpdate(newTextUpdate(chatID, "Мария Петрова"))
+	bot.handleUpdate(newTextUpdate(chatID, "+7 903 555-44-33"))
+
+	bookings, err := bot.repo.GetUserBookings(context.Background(), chatID, booking.ActiveStatuses)
+	assert.NoError(t, err)
+	if assert.Len(t, bookings, 1) {
+		assert.Equal(t, "Мария Петрова", bookings[0].Name)
+		assert.Nil(t, bookings[0].PatientID)
+	}
+	got, err := bot.patients.GetByUserID(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, profile, got)
+}
+
+func TestTgBot_Profile_Edit(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+
+	bot.handleUpdate(newCommandUpdate(chatID, chatID, "/profile"))
+	assert.Contains(t, sent.last(chatID).Text, "Имя: не указано")
+	keyboard := sent.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	assert.Equal(t, "profile_edit_name", unsign(chatID, *keyboard.InlineKeyboard[0][0].CallbackData))
+
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionProfileEdit, profileFieldName)))
+	assert.Equal(t, "Введите имя и фамилию.", sent.last(chatID).Text)
+	bot.handleUpdate(newTextUpdate(chatID, "Иван Петров"))
+	assert.Contains(t, sent.last(chatID).Text, "Имя: Иван Петров")
+
+	// Телефон можно отправить из профиля Telegram
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionProfileEdit, profileFieldPhone)))
+	assert.True(t, sent.last(chatID).ReplyMarkup.(tgbot.ReplyKeyboardMarkup).Keyboard[0][0].RequestContact)
+	update := newTextUpdate(chatID, "")
+	update.Message.Contact = &tgbot.Contact{PhoneNumber: "79161234567", UserID: chatID}
+	bot.handleUpdate(update)
+
+	// Неверная дата не сбрасывает ввод
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionProfileEdit, profileFieldBirthDate)))
+	bot.handleUpdate(newTextUpdate(chatID, "31.02.1990"))
+	assert.Contains(t, sent.last(chatID).Text, "Не удалось распознать дату")
+	bot.handleUpdate(newTextUpdate(chatID, "17.05.1990"))
+
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionProfileEdit, profileFieldNotes)))
+	bot.handleUpdate(newTextUpdate(chatID, "Аллергия на лидокаин"))
+	assert.Equal(t, "Ваш профиль:\n\nИмя: Иван Петров\nТелефон: +79161234567\nДата рождения: 17.05.1990\nЗаметки: Аллергия на лидокаин\n\nЭти данные используются при записи на приём.", sent.last(chatID).Text)
+
+	got, err := bot.patients.GetByUserID(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, "+79161234567", got.Phone)
+	assert.Equal(t, time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), *got.BirthDate)
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+}
+
+func TestParseBirthDate(t *testing.T) {
+	now := time.Date(2025, 10, 24, 12, 0, 0, 0, time.UTC)
+
+	got, err := parseBirthDate("17.05.1990", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC), got)
+
+	for _, input := range []string{"", "1990-05-17", "31.02.1990", "25.10.2025", "01.01.1850"} {
+		_, err := parseBirthDate(input, now)
+		assert.Error(t, err, input)
+	}
+}
@@ -0,0 +1,158 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/i18n"
+	"strconv"
+	"time"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// reminderInterval - как часто проверяются наступившие напоминания
+const reminderInterval = time.Minute
+
+// sendReminders отправляет напоминания, время которых наступило (REMINDER_OFFSETS до приема).
+// Каждое напоминание отмечается в хранилище до отправки, поэтому повторный запуск или перезапуск
+// бота не приводит к дублям; если доставить сообщение не удалось из-за временной ошибки, отметка снимается
+func (b *TgBot) sendReminders() {
+	offsets := b.cfg.Telegram.ReminderOffsets
+	if len(offsets) == 0 {
+		return
+	}
+	ctx, cancel := b.newContext()
+	defer cancel()
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location for reminders")
+		return
+	}
+
+	now := time.Now().In(loc)
+	bookings, err := b.repo.GetUpcomingBookings(ctx, now, now.Add(offsets[len(offsets)-1]), booking.ActiveStatuses)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get upcoming bookings")
+		return
+	}
+
+	for _, booking := range bookings {
+		offset, ok := dueReminder(offsets, booking.Datetime.Sub(now))
+		if !ok {
+			continue
+		}
+		claimed, err := b.repo.ClaimReminder(ctx, booking.ID, offset, booking.Datetime)
+		if err != nil {
+			logrus.WithError(err).WithField("bookingID", booking.ID).Error("Failed to claim reminder")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := b.sendReminder(ctx, booking, booking.Datetime.In(loc), now); err != nil {
+			// Повтор не поможет, если пациент заблокировал бота или чат удален: отметку оставляем,
+			// иначе попытка и ошибка в логе повторялись бы каждую минуту до самого приема
+			if isPermanentSendError(err) {
+				logrus.WithError(err).WithFields(logrus.Fields{"bookingID": booking.ID, "chatID": booking.UserID}).Warn("Reminder cannot be delivered, not retrying")
+				continue
+			}
+			logrus.WithError(err).WithField("bookingID", booking.ID).Error("Failed to send reminder")
+			if err := b.repo.ReleaseReminder(ctx, booking.ID, offset, booking.Datetime); err != nil {
+				logrus.WithError(err).WithField("bookingID", booking.ID).Error("Failed to release reminder")
+			}
+		}
+	}
+}
+
+// isPermanentSendError сообщает, что Telegram отклонил сообщение окончательно: бот заблокирован
+// пациентом (403) или чат не найден (400). Сетевые ошибки, 429 и 5xx считаются временными
+func isPermanentSendError(err error) bool {
+	var apiErr *tgbot.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.Code == http.StatusForbidden || apiErr.Code == http.StatusBadRequest
+}
+
+// dueReminder возвращает напоминание, которое должно быть отправлено, когда до приема осталось left:
+// наименьший из offsets (по возрастанию), не меньший left. Более ранние напоминания, время которых
+// прошло (например, запись сделана за час до приема), не отправляются.
+// Напоминание отправляется и тогда, когда его время уже прошло: так оно не теряется, если бот
+// был остановлен в нужную минуту. Поэтому запись, сделанная за 23 часа до приема, сразу получает
+// напоминание "за сутки" - это намеренно: только в напоминании есть кнопки подтверждения и отмены
+func dueReminder(offsets []time.Duration, left time.Duration) (time.Duration, bool) {
+	if left <= 0 {
+		return 0, false
+	}
+	for _, offset := range offsets {
+		if left <= offset {
+			return offset, true
+		}
+	}
+	return 0, false
+}
+
+// sendReminder отправляет напоминание с кнопками подтверждения, переноса и отмены записи на языке пациента.
+// Напоминание о записи члена семьи приходит в чат родителя и называет, кто идет на прием
+func (b *TgBot) sendReminder(ctx context.Context, bk booking.Booking, visit, now time.Time) error {
+	l := i18n.For(b.userLanguage(ctx, bk.UserID, nil))
+	msg := tgbot.NewMessage(bk.UserID, l.T("reminder.text", i18n.Vars{
+		"Day":     visitDay(l, visit, now),
+		"Time":    l.Time(visit),
+		"Patient": b.dependentName(ctx, bk),
+	}))
+	msg.ReplyMarkup = b.reminderKeyboard(l, bk)
+	_, err := b.api.Send(msg)
+	return err
+}
+
+// dependentName возвращает имя пациента, если запись сделана на члена семьи, иначе пустую строку
+func (b *TgBot) dependentName(ctx context.Context, bk booking.Booking) string {
+	if bk.PatientID == nil {
+		return ""
+	}
+	p, err := b.patients.GetByID(ctx, *bk.PatientID)
+	if err != nil {
+		// Напоминание важнее уточнения, кто идет на прием
+		logrus.WithError(err).WithField("bookingID", bk.ID).Error("Failed to load booking patient")
+		return ""
+	}
+	if p == nil || p.GuardianID == nil {
+		return ""
+	}
+	return bk.Name
+}
+
+// reminderKeyboard возвращает кнопки напоминания; уже подтвержденный визит повторно не подтверждается
+func (b *TgBot) reminderKeyboard(l *i18n.Localizer, bk booking.Booking) tgbot.InlineKeyboardMarkup {
+	var rows [][]tgbot.InlineKeyboardButton
+	if bk.Status != booking.StatusConfirmed {
+		rows = append(rows, tgbot.NewInlineKeyboardRow(
+			b.button(bk.UserID, l.T("button.confirm"), actionConfirm, strconv.Itoa(bk.ID)),
+		))
+	}
+	rows = append(rows, tgbot.NewInlineKeyboardRow(
+		b.button(bk.UserID, l.T("button.reschedule"), actionReschedule, strconv.Itoa(bk.ID)),
+		b.button(bk.UserID, l.T("button.cancel"), actionCancel, strconv.Itoa(bk.ID)),
+	))
+	return tgbot.NewInlineKeyboardMarkup(rows...)
+}
+
+// visitDay описывает день приема относительно now: "сегодня", "завтра" или дата
+func visitDay(l *i18n.Localizer, visit, now time.Time) string {
+	visitDate := time.Date(visit.Year(), visit.Month(), visit.Day(), 0, 0, 0, 0, visit.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, visit.Location())
+	switch visitDate.Sub(today) {
+	case 0:
+		return l.T("reminder.today")
+	case 24 * time.Hour:
+		return l.T("reminder.tomorrow")
+	default:
+		return l.T("reminder.date", i18n.Vars{"Date": l.Day(visit)})
+	}
+}
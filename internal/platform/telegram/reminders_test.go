@@ -0,0 +1,174 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/i18n"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDueReminder(t *testing.T) {
+	offsets := []time.Duration{2 * time.Hour, 24 * time.Hour}
+	tests := []struct {
+		left   time.Duration
+		offset time.Duration
+		ok     bool
+	}{
+		{left: 30 * time.Hour, ok: false},
+		{left: 24 * time.Hour, offset: 24 * time.Hour, ok: true},
+		{left: 23*time.Hour + 59*time.Minute, offset: 24 * time.Hour, ok: true},
+		// Запись за 23 часа до приема сразу получает напоминание "за сутки"
+		{left: 23 * time.Hour, offset: 24 * time.Hour, ok: true},
+		{left: 2*time.Hour + time.Minute, offset: 24 * time.Hour, ok: true},
+		{left: 2 * time.Hour, offset: 2 * time.Hour, ok: true},
+		{left: time.Minute, offset: 2 * time.Hour, ok: true},
+		{left: 0, ok: false},
+		{left: -time.Hour, ok: false},
+	}
+	for _, tt := range tests {
+		offset, ok := dueReminder(offsets, tt.left)
+		assert.Equal(t, tt.ok, ok, tt.left)
+		assert.Equal(t, tt.offset, offset, tt.left)
+	}
+}
+
+func TestVisitDay(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	now := time.Date(2025, 10, 24, 23, 30, 0, 0, loc)
+
+	ru := i18n.Default()
+	assert.Equal(t, "сегодня", visitDay(ru, time.Date(2025, 10, 24, 23, 45, 0, 0, loc), now))
+	assert.Equal(t, "завтра", visitDay(ru, time.Date(2025, 10, 25, 9, 0, 0, 0, loc), now))
+	assert.Equal(t, "27.10", visitDay(ru, time.Date(2025, 10, 27, 9, 0, 0, 0, loc), now))
+
+	en := i18n.For("en")
+	assert.Equal(t, "tomorrow", visitDay(en, time.Date(2025, 10, 25, 9, 0, 0, 0, loc), now))
+	assert.Equal(t, "on Oct 27", visitDay(en, time.Date(2025, 10, 27, 9, 0, 0, 0, loc), now))
+}
+
+func TestTgBot_sendReminders(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	bot.cfg.Telegram.ReminderOffsets = []time.Duration{2 * time.Hour, 24 * time.Hour}
+	now := time.Now().Truncate(time.Minute)
+
+	soon := &booking.Booking{UserID: gofakeit.Int64(), Datetime: now.Add(90 * time.Minute), EndTime: now.Add(150 * time.Minute)}
+	tomorrow := &booking.Booking{UserID: gofakeit.Int64(), Datetime: now.Add(20 * time.Hour), EndTime: now.Add(21 * time.Hour)}
+	later := &booking.Booking{UserID: gofakeit.Int64(), Datetime: now.Add(48 * time.Hour), EndTime: now.Add(49 * time.Hour)}
+	for _, b := range []*booking.Booking{soon, tomorrow, later} {
+		assert.NoError(t, bot.repo.CreateBooking(context.Background(), b))
+	}
+
+	bot.sendReminders()
+	assert.Contains(t, sent.last(soon.UserID).Text, "Напоминание: у вас")
+	assert.Contains(t, sent.last(tomorrow.UserID).Text, "Напоминание: у вас")
+	assert.Empty(t, sent.last(later.UserID).Text)
+
+	// Повторный запуск не дублирует уже отправленные напоминания
+	bot.sendReminders()
+	assert.Len(t, sent.messages, 2)
+
+	// После переноса записи напоминание приходит заново
+	moved := now.Add(100 * time.Minute)
+	assert.NoError(t, bot.repo.RescheduleBooking(context.Background(), soon.ID, moved, moved.Add(time.Hour)))
+	bot.sendReminders()
+	assert.Len(t, sent.messages, 3)
+}
+
+func TestTgBot_sendReminders_RetriesFailedDelivery(t *testing.T) {
+	bot, _, _, _ := newScenarioBot(t, gofakeit.Int64())
+	mockAPI := new(MockBotAPI)
+	bot.api = mockAPI
+	bot.cfg.Telegram.ReminderOffsets = []time.Duration{24 * time.Hour}
+
+	start := time.Now().Add(3 * time.Hour)
+	visit := &booking.Booking{UserID: gofakeit.Int64(), Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+
+	mockAPI.On("Send", mock.Anything).Return(tgbot.Message{}, errors.New("Too Many Requests")).Once()
+	bot.sendReminders()
+
+	// Недоставленное напоминание отправляется при следующем запуске
+	mockAPI.On("Send", mock.Anything).Return(tgbot.Message{}, nil).Once()
+	bot.sendReminders()
+	bot.sendReminders()
+	mockAPI.AssertNumberOfCalls(t, "Send", 2)
+}
+
+func TestTgBot_sendReminders_KeepsClaimForBlockedChat(t *testing.T) {
+	bot, _, _, _ := newScenarioBot(t, gofakeit.Int64())
+	mockAPI := new(MockBotAPI)
+	bot.api = mockAPI
+	bot.cfg.Telegram.ReminderOffsets = []time.Duration{24 * time.Hour}
+
+	start := time.Now().Add(3 * time.Hour)
+	visit := &booking.Booking{UserID: gofakeit.Int64(), Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+
+	// Пациент заблокировал бота: повторять отправку каждую минуту бессмысленно
+	mockAPI.On("Send", mock.Anything).Return(tgbot.Message{}, &tgbot.Error{Code: 403, Message: "Forbidden: bot was blocked by the user"}).Once()
+	bot.sendReminders()
+	bot.sendReminders()
+	mockAPI.AssertNumberOfCalls(t, "Send", 1)
+}
+
+func TestReminderKeyboard(t *testing.T) {
+	bot := &TgBot{cfg: newTestConfig()}
+	bk := booking.Booking{ID: 7, UserID: 42}
+	keyboard := bot.reminderKeyboard(i18n.Default(), bk)
+	assert.Len(t, keyboard.InlineKeyboard, 2)
+	assert.Equal(t, "confirm_7", unsign(bk.UserID, *keyboard.InlineKeyboard[0][0].CallbackData))
+	assert.Equal(t, "reschedule_7", unsign(bk.UserID, *keyboard.InlineKeyboard[1][0].CallbackData))
+	assert.Equal(t, "cancel_7", unsign(bk.UserID, *keyboard.InlineKeyboard[1][1].CallbackData))
+
+	// Подтвержденный визит повторно не подтверждается
+	bk.Status = booking.StatusConfirmed
+	keyboard = bot.reminderKeyboard(i18n.Default(), bk)
+	assert.Len(t, keyboard.InlineKeyboard, 1)
+	assert.Equal(t, "reschedule_7", unsign(bk.UserID, *keyboard.InlineKeyboard[0][0].CallbackData))
+}
+
+func TestTgBot_ReminderButtons(t *testing.T) {
+	adminID := gofakeit.Int64()
+	bot, _, _, sent := newScenarioBot(t, adminID)
+	bot.cfg.Telegram.ReminderOffsets = []time.Duration{24 * time.Hour}
+	chatID := gofakeit.Int64()
+	start := time.Now().Add(3 * time.Hour)
+	visit := &booking.Booking{UserID: chatID, Name: "Иван Петров", Contact: "+79161234567", Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+
+	bot.sendReminders()
+	confirmData := sent.firstButton(t, chatID)
+	assert.Equal(t, fmt.Sprintf("confirm_%d", visit.ID), unsign(chatID, confirmData))
+
+	// Подтверждение сохраняется в записи и видно администратору
+	bot.handleUpdate(newCallbackUpdate(chatID, confirmData))
+	assert.Contains(t, sent.last(chatID).Text, "подтверждён")
+	assert.Contains(t, sent.last(adminID).Text, "Пациент подтвердил визит")
+	confirmed, err := bot.repo.GetBookingByID(context.Background(), visit.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, booking.StatusConfirmed, confirmed.Status)
+
+	bot.handleUpdate(newCallbackUpdate(chatID, confirmData))
+	assert.Equal(t, "Вы уже подтвердили визит. Ждём вас!", sent.last(chatID).Text)
+
+	// Отмена из напоминания освобождает время, запись остается в истории; администратор получает уведомление
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionCancel, strconv.Itoa(visit.ID))))
+	assert.Equal(t, "Ваша запись успешно отменена.", sent.last(chatID).Text)
+	assert.Contains(t, sent.last(adminID).Text, "Пациент отменил запись")
+	cancelled, err := bot.repo.GetBookingByID(context.Background(), visit.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, booking.StatusCancelledByPatient, cancelled.Status)
+
+	bot.handleUpdate(newCallbackUpdate(chatID, confirmData))
+	assert.Equal(t, "Эта запись уже отменена или завершена.", sent.last(chatID).Text)
+}
@@ -1,10 +1,20 @@
 package telegram
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"stomatology_bot/configs"
 	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/catalog"
+	"stomatology_bot/internal/doctor"
+	"stomatology_bot/internal/i18n"
+	"stomatology_bot/internal/patient"
+	"stomatology_bot/internal/phone"
 	"stomatology_bot/internal/platform/calendar"
+	"stomatology_bot/internal/preference"
+	"stomatology_bot/internal/schedule"
+	"stomatology_bot/internal/session"
 	"strconv"
 	"strings"
 	"time"
@@ -14,157 +24,317 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-const slotDuration = time.Hour
+// defaultSlotDuration - длительность приема, если каталог услуг не заполнен
+const defaultSlotDuration = time.Hour
 
 // Состояния пользователя
 const (
 	StateDefault         = ""
+	StateAwaitingDoctor  = "awaiting_doctor"
+	StateAwaitingService = "awaiting_service"
 	StateAwaitingDate    = "awaiting_date"
 	StateAwaitingTime    = "awaiting_time"
 	StateAwaitingName    = "awaiting_name"
 	StateAwaitingContact = "awaiting_contact"
+	// Пациенту предложено записаться на данные из профиля
+	StateAwaitingProfileChoice = "awaiting_profile_choice"
+	// Пациент редактирует поле профиля UserState.ProfileField командой /profile
+	StateEditingProfile = "editing_profile"
+	// Пациент добавляет члена семьи: вводит его имя, затем дату рождения
+	StateAwaitingFamilyName      = "awaiting_family_name"
+	StateAwaitingFamilyBirthDate = "awaiting_family_birth_date"
 )
 
-type UserState struct {
-	State     string
-	TempTime  time.Time // Для хранения выбранной даты/времени
-	TempName  string    // Для хранения имени
-	TempEvent string    // Для хранения ID события календаря
+// StateStore - хранилище состояний диалога; позволяет продолжить запись после перезапуска бота
+type StateStore interface {
+	Get(ctx context.Context, chatID int64) (*session.UserState, error)
+	Set(ctx context.Context, chatID int64, state *session.UserState) error
+	Delete(ctx context.Context, chatID int64) error
+	DeleteExpired(ctx context.Context) error
+}
+
+// PatientStore - хранилище профилей пациентов (patient.Repo или patient.MemoryStore)
+type PatientStore interface {
+	GetByUserID(ctx context.Context, userID int64) (*patient.Patient, error)
+	GetByID(ctx context.Context, id int) (*patient.Patient, error)
+	GetDependents(ctx context.Context, guardianID int) ([]patient.Patient, error)
+	Save(ctx context.Context, p *patient.Patient) error
+	DeleteDependent(ctx context.Context, guardianID, id int, activeStatuses []string) error
+}
+
+// PreferenceStore - хранилище настроек пользователей (preference.Repo или preference.MemoryStore)
+type PreferenceStore interface {
+	Get(ctx context.Context, chatID int64) (*preference.Preferences, error)
+	SetLanguage(ctx context.Context, chatID int64, language string, chosen bool) error
+}
+
+// BookingStore - хранилище записей на прием (booking.Repo или booking.MemoryStore)
+type BookingStore interface {
+	CreateBooking(ctx context.Context, booking *booking.Booking) error
+	GetAllBooking(ctx context.Context) ([]booking.Booking, error)
+	RescheduleBooking(ctx context.Context, id int, start, end time.Time) error
+	UpdateStatus(ctx context.Context, id int, status booking.Status, reason string) error
+	GetUserBookings(ctx context.Context, userID int64, statuses []booking.Status) ([]booking.Booking, error)
+	GetBookingByID(ctx context.Context, id int) (*booking.Booking, error)
+	GetUpcomingBookings(ctx context.Context, from, to time.Time, statuses []booking.Status) ([]booking.Booking, error)
+	HoldSlot(ctx context.Context, userID int64, doctorID int, start, end time.Time, ttl time.Duration, excludeBookingID int) error
+	ReleaseHold(ctx context.Context, userID int64) error
+	GetHeldSlots(ctx context.Context, doctorID int, from, to time.Time, exceptUserID int64) ([]booking.Hold, error)
+	ClaimReminder(ctx context.Context, bookingID int, offset time.Duration, visit time.Time) (bool, error)
+	ReleaseReminder(ctx context.Context, bookingID int, offset time.Duration, visit time.Time) error
 }
 
 type BotAPI interface {
 	Send(c tgbot.Chattable) (tgbot.Message, error)
 	Request(c tgbot.Chattable) (*tgbot.APIResponse, error)
 	GetUpdatesChan(config tgbot.UpdateConfig) tgbot.UpdatesChannel
+	StopReceivingUpdates()
+	MakeRequest(endpoint string, params tgbot.Params) (*tgbot.APIResponse, error)
 }
 
 type TgBot struct {
 	api         BotAPI
 	cfg         *configs.Config
-	repo        *booking.Repo
-	calendarSvc *calendar.Service
-	userStates  map[int64]*UserState
+	repo        BookingStore
+	doctors     *doctor.Repo
+	services    *catalog.Repo
+	closures    *schedule.Repo
+	calendarSvc calendar.Provider
+	states      StateStore
+	prefs       PreferenceStore
+	patients    PatientStore
+	dispatcher  *Dispatcher
+
+	// Родительский контекст обработки обновлений и фоновых задач; отменяется в Stop
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
-func NewBot(api BotAPI, cfg *configs.Config, repo *booking.Repo, calendarSvc *calendar.Service) *TgBot {
-	return &TgBot{
+func NewBot(api BotAPI, cfg *configs.Config, repo BookingStore, doctors *doctor.Repo, services *catalog.Repo, closures *schedule.Repo, calendarSvc calendar.Provider, states StateStore, prefs PreferenceStore, patients PatientStore) *TgBot {
+	b := &TgBot{
 		api:         api,
 		cfg:         cfg,
 		repo:        repo,
+		doctors:     doctors,
+		services:    services,
+		closures:    closures,
 		calendarSvc: calendarSvc,
-		userStates:  make(map[int64]*UserState),
+		states:      states,
+		prefs:       prefs,
+		patients:    patients,
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	b.dispatcher = NewDispatcher(cfg.Telegram.Workers, b.handleUpdate)
+	return b
+}
+
+// Stop прерывает обращения к БД и календарю, которые выполняются в обработчиках и фоновых задачах
+func (b *TgBot) Stop() {
+	b.cancel()
+}
+
+// Start получает обновления, пока не будет отменен ctx, после чего корректно останавливает бота.
+// Способ получения обновлений задается UPDATES_MODE
+func (b *TgBot) Start(ctx context.Context) error {
+	var receive func(ctx context.Context) error
+	switch b.cfg.Telegram.UpdatesMode {
+	case UpdatesModePolling, "":
+		receive = b.poll
+	case UpdatesModeWebhook:
+		receive = b.serveWebhook
+	default:
+		return fmt.Errorf("unknown updates mode %q", b.cfg.Telegram.UpdatesMode)
 	}
+
+	scheduler := b.startReminderCron()
+	err := receive(ctx)
+	b.shutdown(scheduler)
+	return err
 }
 
-func (b *TgBot) Start() {
-	go b.startReminderCron()
+// poll получает обновления через long polling, пока не будет отменен ctx
+func (b *TgBot) poll(ctx context.Context) error {
+	// Пока зарегистрирован вебхук (например, после UPDATES_MODE=webhook), getUpdates отвечает 409.
+	// Ошибку только логируем: получение обновлений само повторяет запросы
+	if _, err := b.api.Request(tgbot.DeleteWebhookConfig{}); err != nil {
+		logrus.WithError(err).Error("Failed to delete webhook before long polling")
+	}
 
 	u := tgbot.NewUpdate(0)
 	u.Timeout = 60
 	updates := b.api.GetUpdatesChan(u)
 
-	// Обрабатываем обновления
-	for update := range updates {
-		switch {
-		case update.Message != nil:
-			go b.processUpdate(update)
-		case update.CallbackQuery != nil:
-			go b.handleCallbackQuery(update)
+	// Обрабатываем обновления: по очереди внутри чата, параллельно между чатами
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case update, ok := <-updates:
+			if !ok {
+				running = false
+				break
+			}
+			b.dispatcher.Dispatch(update)
+		}
+	}
+
+	b.api.StopReceivingUpdates()
+	return nil
+}
+
+// shutdownGracePeriod - сколько после отмены контекста ждать обработчики, которые его не учитывают
+// (например, зависшую отправку сообщения в Telegram), прежде чем завершить работу без них
+var shutdownGracePeriod = 5 * time.Second
+
+// shutdown дожидается обработки уже полученных обновлений и останавливает планировщик.
+// Если обработчики не уложились в SHUTDOWN_TIMEOUT_SECONDS, их запросы к БД и календарю прерываются
+func (b *TgBot) shutdown(scheduler gocron.Scheduler) {
+	logrus.Info("Shutting down bot")
+
+	drained := make(chan struct{})
+	go func() {
+		b.dispatcher.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(time.Duration(b.cfg.Telegram.ShutdownTimeoutSeconds) * time.Second):
+		logrus.Warn("Shutdown timeout exceeded, cancelling in-flight updates")
+		b.Stop()
+		select {
+		case <-drained:
+		case <-time.After(shutdownGracePeriod):
+			logrus.Error("In-flight updates did not stop after cancellation, exiting without waiting for them")
+		}
+	}
+
+	if scheduler != nil {
+		if err := scheduler.Shutdown(); err != nil {
+			logrus.WithError(err).Error("Failed to shut down scheduler")
 		}
 	}
+	b.Stop()
+	logrus.Info("Bot stopped")
 }
 
-func (b *TgBot) startReminderCron() {
+func (b *TgBot) handleUpdate(update tgbot.Update) {
+	// Зависший запрос к БД или календарю не должен навсегда занимать обработчик чата
+	ctx, cancel := b.newContext()
+	defer cancel()
+
+	// Сообщения пациенту пишутся на его языке
+	switch {
+	case update.Message != nil:
+		ctx = i18n.WithLocalizer(ctx, i18n.For(b.userLanguage(ctx, update.Message.Chat.ID, update.Message.From)))
+		b.processUpdate(ctx, update)
+	case update.CallbackQuery != nil:
+		// У колбэков inline-режима и слишком старых сообщений нет Message: отвечаем в личный чат пользователя
+		chatID := updateChatID(update)
+		if chatID == 0 {
+			logrus.WithField("updateID", update.UpdateID).Warn("Dropping callback without chat")
+			return
+		}
+		ctx = i18n.WithLocalizer(ctx, i18n.For(b.userLanguage(ctx, chatID, update.CallbackQuery.From)))
+		b.handleCallbackQuery(ctx, update)
+	}
+}
+
+// startReminderCron запускает фоновые задачи; возвращает nil, если планировщик не удалось создать
+func (b *TgBot) startReminderCron() gocron.Scheduler {
 	s, err := gocron.NewScheduler()
 	if err != nil {
 		logrus.WithError(err).Error("Failed to create scheduler")
-		return
+		return nil
 	}
 
-	// Отправляем напоминания каждый день в 9 утра
+	// Проверяем наступившие напоминания каждую минуту; запуски не накладываются друг на друга
 	_, err = s.NewJob(
-		gocron.CronJob(
-			"0 8 * * *", // Каждую минуту для теста
-			false,
-		),
+		gocron.DurationJob(reminderInterval),
 		gocron.NewTask(b.sendReminders),
+		gocron.WithSingletonMode(gocron.LimitModeReschedule),
 	)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to create cron job")
-		return
+		return s
 	}
-	s.Start()
-	logrus.Info("Reminder cron job started")
-}
-
-func (b *TgBot) sendReminders() {
-	logrus.Info("Running reminder job")
 
-	loc, err := time.LoadLocation("Europe/Moscow")
+	// Периодически удаляем устаревшие состояния диалогов
+	_, err = s.NewJob(
+		gocron.DurationJob(time.Hour),
+		gocron.NewTask(b.cleanupStates),
+	)
 	if err != nil {
-		logrus.WithError(err).Error("Failed to load location for reminders")
-		return
+		logrus.WithError(err).Error("Failed to create state cleanup job")
+		return s
 	}
+	s.Start()
+	logrus.Info("Reminder cron job started")
+	return s
+}
 
-	now := time.Now().In(loc)
-	tomorrow := now.Add(24 * time.Hour)
-
-	from := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 0, 0, 0, 0, loc)
-	to := from.Add(24 * time.Hour)
-
-	bookings, err := b.repo.GetUpcomingBookings(from, to)
-	if err != nil {
-		logrus.WithError(err).Error("Failed to get upcoming bookings")
-		return
-	}
+func (b *TgBot) cleanupStates() {
+	ctx, cancel := b.newContext()
+	defer cancel()
 
-	for _, booking := range bookings {
-		loc, err := time.LoadLocation("Europe/Moscow")
-		if err != nil {
-			logrus.WithError(err).Error("Failed to load location for reminder message")
-			continue
-		}
-		localTime := booking.Datetime.In(loc)
-		msg := fmt.Sprintf("Напоминание: у вас завтра запись на %s", localTime.Format("15:04"))
-		b.sendMessage(booking.UserID, msg)
+	if err := b.states.DeleteExpired(ctx); err != nil {
+		logrus.WithError(err).Error("Failed to delete expired user states")
 	}
 }
 
-func (b *TgBot) processUpdate(update tgbot.Update) {
+func (b *TgBot) processUpdate(ctx context.Context, update tgbot.Update) {
 	if update.Message == nil {
 		return
 	}
 
 	chatID := update.Message.Chat.ID
-	state, exists := b.userStates[chatID]
 
 	if update.Message.IsCommand() {
+		if b.handleAdminCommand(ctx, update.Message) {
+			return
+		}
 		switch update.Message.Command() {
 		case "start", "help":
-			b.userStates[chatID] = &UserState{State: StateDefault}
-			b.sendMainMenu(chatID)
+			b.saveState(ctx, chatID, &session.UserState{State: StateDefault})
+			b.sendMainMenu(ctx, chatID)
+			if update.Message.From != nil && b.isAdmin(update.Message.From.ID) {
+				b.sendMessage(chatID, i18n.Default().T("admin.help"))
+			}
+		case "language":
+			b.sendLanguageMenu(ctx, chatID)
+		case "profile":
+			b.sendProfile(ctx, chatID)
 		default:
-			b.sendMessage(chatID, "Неизвестная команда. Используйте /help для получения списка доступных команд.")
+			b.reply(ctx, chatID, "command.unknown")
 		}
 		return
 	}
 
-	if exists {
+	if state := b.loadState(ctx, chatID); state != nil {
 		switch state.State {
 		case StateAwaitingName:
-			b.handleNameInput(update)
+			b.handleNameInput(ctx, update)
 			return
 		case StateAwaitingContact:
-			b.handleContactInput(update)
+			b.handleContactInput(ctx, update)
+			return
+		case StateEditingProfile:
+			b.handleProfileInput(ctx, update)
+			return
+		case StateAwaitingFamilyName:
+			b.handleFamilyNameInput(ctx, update)
+			return
+		case StateAwaitingFamilyBirthDate:
+			b.handleFamilyBirthDateInput(ctx, update)
 			return
 		}
 	}
 
-	b.sendMessage(chatID, "Пожалуйста, используйте кнопки или команды.")
+	b.reply(ctx, chatID, "input.use_buttons")
 }
 
-func (b *TgBot) handleCallbackQuery(update tgbot.Update) {
-	if update.CallbackQuery == nil {
+func (b *TgBot) handleCallbackQuery(ctx context.Context, update tgbot.Update) {
+	chatID := updateChatID(update)
+	if update.CallbackQuery == nil || chatID == 0 {
 		return
 	}
 
@@ -174,257 +344,594 @@ func (b *TgBot) handleCallbackQuery(update tgbot.Update) {
 		logrus.WithError(err).Error("Failed to send callback request")
 	}
 
-	chatID := update.CallbackQuery.Message.Chat.ID
+	// Разбор данных колбэка: подделанные, чужие и устаревшие кнопки отклоняются
+	data, err := b.callbacks().decode(chatID, update.CallbackQuery.Data)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Warn("Rejected callback data")
+		b.reply(ctx, chatID, "callback.expired")
+		return
+	}
+	switch data.Action {
+	case actionBook:
+		b.handleBookCommand(ctx, chatID)
+	case actionMyBookings:
+		b.handleShowAllBooking(ctx, update) // Передаем весь update
+	case actionDoctor:
+		b.handleDoctorSelection(ctx, chatID, data.arg(0))
+	case actionService:
+		b.handleServiceSelection(ctx, chatID, data.arg(0))
+	case actionDate:
+		b.handleDateSelection(ctx, chatID, data.arg(0))
+	case actionTime:
+		b.handleTimeSelection(ctx, chatID, data.arg(0))
+	case actionCancel:
+		b.handleCancelBooking(ctx, chatID, data.arg(0))
+	case actionReschedule:
+		b.handleRescheduleBooking(ctx, chatID, data.arg(0))
+	case actionConfirm:
+		b.handleConfirmBooking(ctx, chatID, data.arg(0))
+	case actionLanguage:
+		b.handleLanguageSelection(ctx, chatID, data.arg(0))
+	case actionUseProfile:
+		b.handleUseProfile(ctx, chatID)
+	case actionNewDetails:
+		b.handleNewDetails(ctx, chatID)
+	case actionProfile:
+		b.sendProfile(ctx, chatID)
+	case actionProfileEdit:
+		b.handleProfileEdit(ctx, chatID, data.arg(0))
+	case actionForPatient:
+		b.handleForPatient(ctx, chatID, data.arg(0))
+	case actionFamilyAdd:
+		b.handleFamilyAdd(ctx, chatID)
+	case actionFamilyRemove:
+		b.handleFamilyRemove(ctx, chatID, data.arg(0))
+	default:
+		b.reply(ctx, chatID, "callback.unknown")
+	}
+}
 
-	// Разбор данных колбэка
-	data := update.CallbackQuery.Data
-	switch {
-	case data == "book":
-		b.handleBookCommand(chatID)
-	case data == "my_bookings":
-		b.handleShowAllBooking(update) // Передаем весь update
-	case strings.HasPrefix(data, "date_"):
-		b.handleDateSelection(update)
-	case strings.HasPrefix(data, "time_"):
-		b.handleTimeSelection(update)
-	case strings.HasPrefix(data, "cancel_"):
-		b.handleCancelBooking(update)
+func (b *TgBot) handleBookCommand(ctx context.Context, chatID int64) {
+	doctors, err := b.doctors.GetActiveDoctors(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get doctors")
+		b.reply(ctx, chatID, "doctors.error")
+		return
+	}
+
+	switch len(doctors) {
+	case 0:
+		// Врачи не заведены - записываем в общий календарь клиники
+		b.sendServiceKeyboard(ctx, chatID, 0)
+	case 1:
+		b.sendServiceKeyboard(ctx, chatID, doctors[0].ID)
+	default:
+		b.saveState(ctx, chatID, &session.UserState{State: StateAwaitingDoctor})
+
+		var buttons [][]tgbot.InlineKeyboardButton
+		for _, d := range doctors {
+			button := b.button(chatID, d.Title(), actionDoctor, strconv.Itoa(d.ID))
+			buttons = append(buttons, []tgbot.InlineKeyboardButton{button})
+		}
+		msg := tgbot.NewMessage(chatID, i18n.FromContext(ctx).T("doctors.choose"))
+		msg.ReplyMarkup = tgbot.NewInlineKeyboardMarkup(buttons...)
+		if _, err := b.api.Send(msg); err != nil {
+			logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+		}
+	}
+}
+
+func (b *TgBot) handleDoctorSelection(ctx context.Context, chatID int64, arg string) {
+	doctorID, err := strconv.Atoi(arg)
+	if err != nil {
+		b.reply(ctx, chatID, "doctors.invalid")
+		return
+	}
+
+	d, err := b.doctors.GetDoctorByID(ctx, doctorID)
+	if err != nil || !d.Active {
+		logrus.WithError(err).WithField("doctorID", doctorID).Error("Failed to get doctor")
+		b.reply(ctx, chatID, "doctors.unavailable")
+		return
+	}
+
+	b.sendServiceKeyboard(ctx, chatID, d.ID)
+}
+
+// sendServiceKeyboard предлагает выбрать услугу; если выбирать не из чего, сразу переходит к выбору даты
+func (b *TgBot) sendServiceKeyboard(ctx context.Context, chatID int64, doctorID int) {
+	services, err := b.services.GetActiveServices(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get services")
+		b.reply(ctx, chatID, "services.error")
+		return
+	}
+
+	switch len(services) {
+	case 0:
+		// Каталог не заполнен - прием стандартной длительности
+		b.saveState(ctx, chatID, &session.UserState{State: StateAwaitingDate, DoctorID: doctorID})
+		b.sendDateKeyboard(ctx, chatID, doctorID)
+	case 1:
+		b.saveState(ctx, chatID, &session.UserState{State: StateAwaitingDate, DoctorID: doctorID, ServiceID: services[0].ID})
+		b.sendDateKeyboard(ctx, chatID, doctorID)
 	default:
-		b.sendMessage(chatID, "Неизвестное действие.")
+		b.saveState(ctx, chatID, &session.UserState{State: StateAwaitingService, DoctorID: doctorID})
+
+		var buttons [][]tgbot.InlineKeyboardButton
+		for _, s := range services {
+			button := b.button(chatID, s.Title(), actionService, strconv.Itoa(s.ID))
+			buttons = append(buttons, []tgbot.InlineKeyboardButton{button})
+		}
+		msg := tgbot.NewMessage(chatID, i18n.FromContext(ctx).T("services.choose"))
+		msg.ReplyMarkup = tgbot.NewInlineKeyboardMarkup(buttons...)
+		if _, err := b.api.Send(msg); err != nil {
+			logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+		}
+	}
+}
+
+func (b *TgBot) handleServiceSelection(ctx context.Context, chatID int64, arg string) {
+	state := b.loadState(ctx, chatID)
+	if state == nil {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	serviceID, err := strconv.Atoi(arg)
+	if err != nil {
+		b.reply(ctx, chatID, "services.invalid")
+		return
 	}
+
+	s, err := b.services.GetServiceByID(ctx, serviceID)
+	if err != nil || !s.Active {
+		logrus.WithError(err).WithField("serviceID", serviceID).Error("Failed to get service")
+		b.reply(ctx, chatID, "services.unavailable")
+		return
+	}
+
+	b.saveState(ctx, chatID, &session.UserState{State: StateAwaitingDate, DoctorID: state.DoctorID, ServiceID: s.ID})
+	b.sendDateKeyboard(ctx, chatID, state.DoctorID)
 }
 
-func (b *TgBot) handleBookCommand(chatID int64) {
-	b.userStates[chatID] = &UserState{State: StateAwaitingDate}
+// sendDateKeyboard предлагает выбрать дату среди рабочих дней врача; doctorID = 0 - расписание клиники
+func (b *TgBot) sendDateKeyboard(ctx context.Context, chatID int64, doctorID int) {
 	// Предлагаем выбрать дату
 	var buttons [][]tgbot.InlineKeyboardButton
 
 	loc, err := time.LoadLocation("Europe/Moscow")
 	if err != nil {
 		logrus.WithError(err).Error("Failed to load location")
-		b.sendMessage(chatID, "Произошла ошибка сервера, не удалось получить даты.")
+		b.reply(ctx, chatID, "dates.error")
+		return
+	}
+
+	week, err := b.weekFor(ctx, doctorID)
+	if err != nil {
+		logrus.WithError(err).WithField("doctorID", doctorID).Error("Failed to get doctor schedule")
+		b.reply(ctx, chatID, "dates.error")
 		return
 	}
+
+	today := time.Now().In(loc)
+	closures, err := b.closures.GetClosures(ctx, today, today.AddDate(0, 0, 30))
+	if err != nil {
+		// Закрытые дни все равно не покажут свободных слотов в GetFreeSlots
+		logrus.WithError(err).Error("Failed to get closures")
+	}
+
 	// Итерируемся 30 дней вперед от сегодня
 	for i := 0; i < 30; i++ {
-		date := time.Now().In(loc).AddDate(0, 0, i)
-		// Пропускаем воскресенье
-		if date.Weekday() == time.Sunday {
+		date := today.AddDate(0, 0, i)
+		// Пропускаем выходные дни и дни закрытия
+		if !week.IsOpen(date) || isClosed(closures, doctorID, date) {
 			continue
 		}
-		button := tgbot.NewInlineKeyboardButtonData(date.Format("02.01.2006"), "date_"+date.Format("2006-01-02"))
+		button := b.button(chatID, i18n.FromContext(ctx).Date(date), actionDate, date.Format(callbackDateLayout))
 		buttons = append(buttons, []tgbot.InlineKeyboardButton{button})
 	}
 
 	keyboard := tgbot.NewInlineKeyboardMarkup(buttons...)
-	msg := tgbot.NewMessage(chatID, "Выберите дату для записи:")
+	msg := tgbot.NewMessage(chatID, i18n.FromContext(ctx).T("dates.choose"))
 	msg.ReplyMarkup = keyboard
 	if _, err := b.api.Send(msg); err != nil {
 		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
 	}
 }
 
-func (b *TgBot) handleDateSelection(update tgbot.Update) {
-	chatID := update.CallbackQuery.Message.Chat.ID
-	b.userStates[chatID] = &UserState{State: StateAwaitingTime}
+func (b *TgBot) handleDateSelection(ctx context.Context, chatID int64, arg string) {
+	state := b.loadState(ctx, chatID)
+	if state == nil {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+	// Неверная дата не должна сбивать шаг диалога: пациент может выбрать другую дату на той же клавиатуре
+	date, err := time.Parse(callbackDateLayout, arg)
+	if err != nil {
+		b.reply(ctx, chatID, "dates.invalid")
+		return
+	}
+	b.saveState(ctx, chatID, &session.UserState{
+		State:        StateAwaitingTime,
+		DoctorID:     state.DoctorID,
+		ServiceID:    state.ServiceID,
+		RescheduleID: state.RescheduleID,
+	})
+
+	cal, _, err := b.calendarFor(ctx, state.DoctorID)
+	if err != nil {
+		logrus.WithError(err).WithField("doctorID", state.DoctorID).Error("Failed to get doctor calendar")
+		b.reply(ctx, chatID, "slots.error")
+		return
+	}
 
-	dateStr := strings.TrimPrefix(update.CallbackQuery.Data, "date_")
-	date, err := time.Parse("2006-01-02", dateStr)
+	duration, _, err := b.serviceFor(ctx, state.ServiceID)
 	if err != nil {
-		b.sendMessage(chatID, "Неверный формат даты.")
+		logrus.WithError(err).WithField("serviceID", state.ServiceID).Error("Failed to get service")
+		b.reply(ctx, chatID, "slots.error")
 		return
 	}
 
-	freeSlots, err := b.calendarSvc.GetFreeSlots(date)
+	freeSlots, err := cal.GetFreeSlots(ctx, date, duration, b.rescheduledEventID(ctx, chatID, state.RescheduleID))
 	if err != nil {
 		logrus.WithError(err).WithField("date", date).Error("Failed to get free slots")
-		b.sendMessage(update.CallbackQuery.Message.Chat.ID, "Не удалось получить свободные слоты. Попробуйте позже.")
+		b.reply(ctx, chatID, "slots.error")
 		return
 	}
 
+	// Убираем слоты, пересекающиеся с временем, которое сейчас удерживают другие пациенты
+	if len(freeSlots) > 0 {
+		held, err := b.repo.GetHeldSlots(ctx, state.DoctorID, freeSlots[0], freeSlots[len(freeSlots)-1].Add(duration), chatID)
+		if err != nil {
+			logrus.WithError(err).WithField("date", date).Error("Failed to get held slots")
+		}
+		freeSlots = excludeHeld(freeSlots, duration, held)
+	}
+
 	if len(freeSlots) == 0 {
-		b.sendMessage(update.CallbackQuery.Message.Chat.ID, "На выбранную дату нет свободных слотов.")
+		b.reply(ctx, chatID, "slots.none")
 		return
 	}
 
+	l := i18n.FromContext(ctx)
 	var buttons [][]tgbot.InlineKeyboardButton
 	for _, slot := range freeSlots {
-		button := tgbot.NewInlineKeyboardButtonData(slot.Format("15:04"), "time_"+slot.Format(time.RFC3339))
+		// Время передается в секундах Unix: так данные кнопки короче
+		button := b.button(chatID, l.Time(slot), actionTime, strconv.FormatInt(slot.Unix(), 36))
 		buttons = append(buttons, []tgbot.InlineKeyboardButton{button})
 	}
 
 	keyboard := tgbot.NewInlineKeyboardMarkup(buttons...)
-	msg := tgbot.NewMessage(update.CallbackQuery.Message.Chat.ID, "Выберите время для записи:")
+	msg := tgbot.NewMessage(chatID, l.T("slots.choose"))
 	msg.ReplyMarkup = keyboard
 	if _, err := b.api.Send(msg); err != nil {
-		logrus.WithError(err).WithField("chatID", update.CallbackQuery.Message.Chat.ID).Error("Failed to send message")
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
 	}
 }
 
-func (b *TgBot) handleTimeSelection(update tgbot.Update) {
-	chatID := update.CallbackQuery.Message.Chat.ID
-	timeStr := strings.TrimPrefix(update.CallbackQuery.Data, "time_")
-	slot, err := time.Parse(time.RFC3339, timeStr)
+func (b *TgBot) handleTimeSelection(ctx context.Context, chatID int64, arg string) {
+	seconds, err := strconv.ParseInt(arg, 36, 64)
+	if err != nil {
+		b.reply(ctx, chatID, "slots.invalid")
+		return
+	}
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		loc = time.UTC
+	}
+	slot := time.Unix(seconds, 0).In(loc)
+
+	state := b.loadState(ctx, chatID)
+	if state == nil {
+		b.reply(ctx, chatID, "error.state")
+		return
+	}
+
+	duration, _, err := b.serviceFor(ctx, state.ServiceID)
 	if err != nil {
-		b.sendMessage(chatID, "Неверный формат времени.")
+		logrus.WithError(err).WithField("serviceID", state.ServiceID).Error("Failed to get service")
+		b.reply(ctx, chatID, "error.generic")
+		return
+	}
+
+	// Удерживаем время приема, пока пациент вводит свои данные
+	// Переносимая запись не мешает выбрать время, пересекающееся с ней самой
+	if err := b.repo.HoldSlot(ctx, chatID, state.DoctorID, slot, slot.Add(duration), b.slotHoldTTL(), state.RescheduleID); err != nil {
+		if errors.Is(err, booking.ErrSlotTaken) {
+			b.reply(ctx, chatID, "slots.taken")
+			b.resetState(ctx, chatID)
+			return
+		}
+		logrus.WithError(err).WithField("slot", slot).Error("Failed to hold slot")
+		b.reply(ctx, chatID, "error.generic")
 		return
 	}
 
-	// Сохраняем выбранное время и переходим к запросу имени
-	b.userStates[chatID] = &UserState{
-		State:    StateAwaitingName,
-		TempTime: slot,
+	// При переносе данные пациента уже известны - сразу переносим запись
+	if state.RescheduleID != 0 {
+		b.completeReschedule(ctx, chatID, state, slot, slot.Add(duration))
+		return
 	}
 
-	b.sendMessage(chatID, "Пожалуйста, введите ваше Имя и Фамилию.")
+	// Сохраняем выбранное время и переходим к данным пациента
+	b.askPatientDetails(ctx, chatID, &session.UserState{
+		TempTime:  slot,
+		DoctorID:  state.DoctorID,
+		ServiceID: state.ServiceID,
+	})
 }
 
-func (b *TgBot) handleNameInput(update tgbot.Update) {
+func (b *TgBot) handleNameInput(ctx context.Context, update tgbot.Update) {
 	chatID := update.Message.Chat.ID
 	name := update.Message.Text
 
-	state, ok := b.userStates[chatID]
-	if !ok || state.State != StateAwaitingName {
-		b.sendMessage(chatID, "Произошла ошибка состояния. Пожалуйста, начните заново с /start.")
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateAwaitingName {
+		b.reply(ctx, chatID, "error.state")
 		return
 	}
 
 	// Сохраняем имя и переходим к запросу контакта
 	state.State = StateAwaitingContact
 	state.TempName = name
-	b.userStates[chatID] = state
+	b.saveState(ctx, chatID, state)
+
+	b.askContact(ctx, chatID, "booking.ask_contact")
+}
+
+// askContact запрашивает номер телефона с кнопкой, которая отправляет номер из профиля Telegram
+func (b *TgBot) askContact(ctx context.Context, chatID int64, key string) {
+	l := i18n.FromContext(ctx)
+	msg := tgbot.NewMessage(chatID, l.T(key))
+	keyboard := tgbot.NewOneTimeReplyKeyboard(
+		tgbot.NewKeyboardButtonRow(tgbot.NewKeyboardButtonContact(l.T("button.share_contact"))),
+	)
+	keyboard.ResizeKeyboard = true
+	msg.ReplyMarkup = keyboard
+	if _, err := b.api.Send(msg); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+	}
+}
+
+// errForeignContact - вместо своего номера пользователь отправил чужой контакт, например пересланную карточку
+var errForeignContact = errors.New("contact belongs to another user")
 
-	b.sendMessage(chatID, "Спасибо! Теперь, пожалуйста, введите ваш номер телефона для связи.")
+// messagePhone возвращает номер телефона из сообщения в формате E.164: отправленный кнопкой
+// или введенный вручную. Кнопкой принимается только собственный контакт отправителя
+func messagePhone(msg *tgbot.Message) (string, error) {
+	if msg.Contact == nil {
+		return phone.Normalize(msg.Text)
+	}
+	if msg.From == nil || msg.Contact.UserID != msg.From.ID {
+		return "", errForeignContact
+	}
+	return phone.NormalizeContact(msg.Contact.PhoneNumber)
 }
 
-func (b *TgBot) handleContactInput(update tgbot.Update) {
+// askContactAgain повторно запрашивает номер, объясняя, почему отправленный не подошел
+func (b *TgBot) askContactAgain(ctx context.Context, chatID int64, err error) {
+	if errors.Is(err, errForeignContact) {
+		b.askContact(ctx, chatID, "booking.foreign_contact")
+		return
+	}
+	b.askContact(ctx, chatID, "booking.invalid_contact")
+}
+
+func (b *TgBot) handleContactInput(ctx context.Context, update tgbot.Update) {
 	chatID := update.Message.Chat.ID
-	contact := update.Message.Text
 
-	// Валидация номера телефона
-	if !strings.HasPrefix(contact, "+7") || len(contact) != 12 {
-		b.sendMessage(chatID, "Неверный формат номера. Пожалуйста, введите номер в формате +7XXXXXXXXXX (12 цифр).")
+	// Номер либо отправлен кнопкой из профиля Telegram, либо введен вручную; храним его в E.164
+	contact, err := messagePhone(update.Message)
+	if err != nil {
+		b.askContactAgain(ctx, chatID, err)
 		return // Оставляем пользователя в том же состоянии, чтобы он мог повторить ввод
 	}
 
-	state, ok := b.userStates[chatID]
-	if !ok || state.State != StateAwaitingContact {
-		b.sendMessage(chatID, "Произошла ошибка состояния. Пожалуйста, начните заново с /start.")
+	state := b.loadState(ctx, chatID)
+	if state == nil || state.State != StateAwaitingContact {
+		b.reply(ctx, chatID, "error.state")
 		return
 	}
 
-	userName := state.TempName // Используем сохраненное имя
+	// Номер принят: убираем кнопку отправки номера и показываем, в каком виде он сохранен
+	saved := tgbot.NewMessage(chatID, i18n.FromContext(ctx).T("booking.contact_saved", i18n.Vars{"Phone": contact}))
+	saved.ReplyMarkup = tgbot.NewRemoveKeyboard(true)
+	if _, err := b.api.Send(saved); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to send message")
+	}
+
+	details := bookingDetails{Name: state.TempName, Contact: contact}
+	if state.PatientID != 0 {
+		// Член семьи записывается на телефон родителя
+		details.PatientID = &state.PatientID
+		details.Guardian = b.saveGuardianPhone(ctx, chatID, contact)
+	} else {
+		details.PatientID = b.bookingPatient(ctx, chatID, state.TempName, contact)
+	}
+	b.completeBooking(ctx, chatID, state, details)
+}
+
+// bookingDetails - данные пациента для новой записи
+type bookingDetails struct {
+	Name      string
+	Contact   string
+	PatientID *int   // Профиль, с которым связывается запись (nil - данные введены без профиля)
+	Guardian  string // Имя родителя, если записывается член семьи
+}
+
+// completeBooking создает запись на выбранное в state время: событие в календаре и запись в БД
+func (b *TgBot) completeBooking(ctx context.Context, chatID int64, state *session.UserState, details bookingDetails) {
 	slot := state.TempTime
+	// Снимаем удержание слота после завершения попытки записи
+	defer b.releaseHold(ctx, chatID)
+
+	duration, selectedService, err := b.serviceFor(ctx, state.ServiceID)
+	if err != nil {
+		logrus.WithError(err).WithField("serviceID", state.ServiceID).Error("Failed to get service")
+		b.reply(ctx, chatID, "error.generic")
+		return
+	}
+	slotEnd := slot.Add(duration)
+
+	// Продлеваем удержание: если оно истекло и слот успели занять, запись невозможна
+	if err := b.repo.HoldSlot(ctx, chatID, state.DoctorID, slot, slotEnd, b.slotHoldTTL(), 0); err != nil {
+		if errors.Is(err, booking.ErrSlotTaken) {
+			b.reply(ctx, chatID, "slots.taken")
+			b.resetState(ctx, chatID)
+			return
+		}
+		logrus.WithError(err).WithField("slot", slot).Error("Failed to hold slot")
+		b.reply(ctx, chatID, "error.generic")
+		return
+	}
+
+	cal, selectedDoctor, err := b.calendarFor(ctx, state.DoctorID)
+	if err != nil {
+		logrus.WithError(err).WithField("doctorID", state.DoctorID).Error("Failed to get doctor calendar")
+		b.reply(ctx, chatID, "error.generic")
+		return
+	}
 
 	// Повторная проверка, свободен ли слот
-	isFree, err := b.calendarSvc.IsSlotFree(slot, slot.Add(slotDuration))
+	isFree, err := cal.IsSlotFree(ctx, slot, slotEnd, "")
 	if err != nil {
 		logrus.WithError(err).WithField("slot", slot).Error("Failed to check slot availability")
-		b.sendMessage(chatID, "Произошла ошибка. Попробуйте снова.")
+		b.reply(ctx, chatID, "error.generic")
 		return
 	}
 	if !isFree {
-		b.sendMessage(chatID, "К сожалению, этот слот только что заняли. Пожалуйста, выберите другое время.")
-		b.userStates[chatID] = &UserState{State: StateDefault} // Сброс состояния
+		b.reply(ctx, chatID, "slots.taken")
+		b.saveState(ctx, chatID, &session.UserState{State: StateDefault}) // Сброс состояния
 		return
 	}
 
 	// Создаем событие в Google Calendar
-	summary := fmt.Sprintf("Запись: %s", userName)
-	description := fmt.Sprintf("Запись на прием от пользователя %s.\nКонтакт: %s", userName, contact)
-	link, eventID, err := b.calendarSvc.CreateEvent(summary, description, slot, slot.Add(slotDuration))
+	// События календаря читает персонал клиники, поэтому они пишутся на языке клиники
+	staff := i18n.Default()
+	vars := i18n.Vars{
+		"Name":        details.Name,
+		"Contact":     details.Contact,
+		"Guardian":    details.Guardian,
+		"Doctor":      "",
+		"Service":     "",
+		"ServiceName": "",
+	}
+	if selectedDoctor != nil {
+		vars["Doctor"] = selectedDoctor.Title()
+	}
+	if selectedService != nil {
+		vars["Service"] = selectedService.Title()
+		vars["ServiceName"] = selectedService.Name
+	}
+	summary := staff.T("admin.event.summary", vars)
+	description := staff.T("admin.event.description", vars)
+	link, eventID, err := cal.CreateEvent(ctx, summary, description, slot, slotEnd)
 	if err != nil {
 		logrus.WithError(err).WithFields(logrus.Fields{
 			"summary":     summary,
 			"description": description,
 			"slot":        slot,
 		}).Error("Failed to create calendar event")
-		b.sendMessage(chatID, "Не удалось создать запись. Попробуйте позже.")
+		b.reply(ctx, chatID, "booking.create_error")
 		return
 	}
 
 	// Создаем запись в нашей БД
-	booking := &booking.Booking{
-		UserID:   chatID,
-		Name:     userName,
-		Contact:  contact,
-		Datetime: slot,
-		EventID:  &eventID,
+	newBooking := &booking.Booking{
+		UserID:    chatID,
+		Name:      details.Name,
+		Contact:   details.Contact,
+		Datetime:  slot,
+		EndTime:   slotEnd,
+		EventID:   &eventID,
+		DoctorID:  optionalID(state.DoctorID),
+		ServiceID: optionalID(state.ServiceID),
+		PatientID: details.PatientID,
 	}
 
-	if err := b.repo.CreateBooking(booking); err != nil {
-		logrus.WithError(err).WithField("booking", booking).Error("Failed to create booking in DB, rolling back calendar event")
-		if delErr := b.calendarSvc.DeleteEvent(eventID); delErr != nil {
+	if err := b.repo.CreateBooking(ctx, newBooking); err != nil {
+		logrus.WithError(err).WithField("booking", newBooking).Error("Failed to create booking in DB, rolling back calendar event")
+		if delErr := cal.DeleteEvent(ctx, eventID); delErr != nil {
 			logrus.WithFields(logrus.Fields{
 				"eventID": eventID,
 				"error":   delErr,
 			}).Error("CRITICAL: failed to rollback calendar event")
-			b.sendMessage(chatID, "Произошла критическая ошибка. Пожалуйста, свяжитесь с администратором.")
+			b.reply(ctx, chatID, "error.critical")
 		} else {
 			logrus.Infof("Successfully rolled back calendar event %s", eventID)
-			b.sendMessage(chatID, "Ошибка при сохранении записи в базу данных. Попробуйте снова.")
+			if errors.Is(err, booking.ErrSlotTaken) {
+				b.reply(ctx, chatID, "slots.taken")
+			} else {
+				b.reply(ctx, chatID, "booking.save_error")
+			}
 		}
 	} else {
 		// Сообщение для пользователя
-		userResponse := fmt.Sprintf("Вы успешно записаны на %s.", slot.Format("02.01.2006 в 15:04"))
-		b.sendMessage(chatID, userResponse)
-
-		// Сообщение для админа
-		adminID, err := strconv.ParseInt(b.cfg.Telegram.AdminID, 10, 64)
-		if err != nil {
-			logrus.WithError(err).Error("Failed to parse ADMIN_ID")
-		} else {
-			adminResponse := fmt.Sprintf("Новая запись:\n\nИмя: %s\nКонтакт: %s\nДата: %s\n\nСсылка на событие: %s",
-				userName, contact, slot.Format("02.01.2006 в 15:04"), link)
-			b.sendMessage(adminID, adminResponse)
-		}
+		l := i18n.FromContext(ctx)
+		b.sendMessage(chatID, l.T("booking.created", i18n.Vars{
+			"Datetime": l.DateTime(slot),
+			"Doctor":   vars["Doctor"],
+			"Service":  vars["Service"],
+		}))
+
+		// Сообщение для администраторов; у локального календаря нет ссылок на события
+		vars["Datetime"] = staff.DateTime(slot)
+		vars["Link"] = link
+		b.notifyAdmins("admin.new_booking", vars)
 	}
 
 	// Сбрасываем состояние пользователя
-	delete(b.userStates, chatID)
+	b.resetState(ctx, chatID)
 }
 
-func (b *TgBot) handleShowAllBooking(update tgbot.Update) {
-	var chatID int64
-	switch {
-	case update.Message != nil:
-		chatID = update.Message.Chat.ID
-	case update.CallbackQuery != nil:
-		chatID = update.CallbackQuery.Message.Chat.ID
-	default:
+func (b *TgBot) handleShowAllBooking(ctx context.Context, update tgbot.Update) {
+	chatID := updateChatID(update)
+	if chatID == 0 {
 		return // Не можем определить чат
 	}
 
-	bookings, err := b.repo.GetUserBookings(chatID) // Фильтрация на уровне БД
+	bookings, err := b.repo.GetUserBookings(ctx, chatID, booking.ActiveStatuses) // Фильтрация на уровне БД
 	if err != nil {
 		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to get user bookings")
-		b.sendMessage(chatID, "Ошибка при получении записей.")
+		b.reply(ctx, chatID, "bookings.error")
 		return
 	}
 
 	if len(bookings) == 0 {
-		b.sendMessage(chatID, "У вас пока нет записей.")
+		b.reply(ctx, chatID, "bookings.none")
 		return
 	}
 
+	doctorNames := b.doctorNames(ctx)
+	serviceNames := b.serviceNames(ctx)
+	l := i18n.FromContext(ctx)
+	b.sendMessage(chatID, l.N("bookings.count", len(bookings)))
+
 	var response strings.Builder
 	for _, booking := range bookings {
-		loc, err := time.LoadLocation("Europe/Moscow")
-		if err != nil {
-			logrus.WithError(err).Error("Failed to load location")
-			// В случае ошибки выводим как есть (в UTC)
-			response.WriteString(fmt.Sprintf("ID: %d\nИмя: %s\nТелефон: %s\nДата/время: %s\n\n",
-				booking.ID, booking.Name, booking.Contact, booking.Datetime))
-			continue
+		item := i18n.Vars{
+			"ID":       booking.ID,
+			"Name":     booking.Name,
+			"Contact":  booking.Contact,
+			"Datetime": l.DateTime(inMoscow(booking.Datetime)),
+			"Doctor":   "",
+			"Service":  "",
+		}
+		if booking.DoctorID != nil {
+			item["Doctor"] = doctorNames[*booking.DoctorID]
 		}
-		localTime := booking.Datetime.In(loc)
-		response.WriteString(fmt.Sprintf("ID: %d\nИмя: %s\nТелефон: %s\nДата/время: %s\n\n",
-			booking.ID, booking.Name, booking.Contact, localTime.Format("02.01.2006 15:04")))
+		if booking.ServiceID != nil {
+			item["Service"] = serviceNames[*booking.ServiceID]
+		}
+		response.WriteString(l.T("bookings.item", item) + "\n\n")
 
-		// Добавляем кнопку отмены для каждой записи
+		// Добавляем кнопки переноса и отмены для каждой записи
 		keyboard := tgbot.NewInlineKeyboardMarkup(
 			tgbot.NewInlineKeyboardRow(
-				tgbot.NewInlineKeyboardButtonData("Отменить запись", fmt.Sprintf("cancel_%d", booking.ID)),
+				b.button(chatID, l.T("button.reschedule"), actionReschedule, strconv.Itoa(booking.ID)),
+				b.button(chatID, l.T("button.cancel"), actionCancel, strconv.Itoa(booking.ID)),
 			),
 		)
 		msg := tgbot.NewMessage(chatID, response.String())
@@ -436,42 +943,404 @@ func (b *TgBot) handleShowAllBooking(update tgbot.Update) {
 	}
 }
 
-func (b *TgBot) handleCancelBooking(update tgbot.Update) {
-	chatID := update.CallbackQuery.Message.Chat.ID
-	bookingIDStr := strings.TrimPrefix(update.CallbackQuery.Data, "cancel_")
-	bookingID, err := strconv.Atoi(bookingIDStr)
+func (b *TgBot) handleCancelBooking(ctx context.Context, chatID int64, arg string) {
+	bookingID, err := strconv.Atoi(arg)
 	if err != nil {
 		logrus.WithError(err).Error("Failed to parse booking ID from callback")
-		b.sendMessage(chatID, "Некорректный ID записи.")
+		b.reply(ctx, chatID, "booking.invalid_id")
 		return
 	}
 
 	// 1. Получаем запись из БД, чтобы узнать event_id
-	bookingToCancel, err := b.repo.GetBookingByID(bookingID)
+	bookingToCancel, err := b.userBooking(ctx, chatID, bookingID)
 	if err != nil {
 		logrus.WithError(err).WithField("bookingID", bookingID).Error("Failed to get booking by ID for cancellation")
-		b.sendMessage(chatID, "Не удалось найти указанную запись.")
+		b.reply(ctx, chatID, "booking.not_found")
+		return
+	}
+	if !bookingToCancel.Status.Active() {
+		b.reply(ctx, chatID, "booking.inactive")
 		return
 	}
 
 	// 2. Удаляем событие из Google Calendar (если оно есть)
 	if bookingToCancel.EventID != nil {
-		if err := b.calendarSvc.DeleteEvent(*bookingToCancel.EventID); err != nil {
+		if err := b.deleteBookingEvent(ctx, bookingToCancel); err != nil {
 			logrus.WithError(err).WithField("eventID", *bookingToCancel.EventID).Error("Failed to delete calendar event")
-			b.sendMessage(chatID, "Ошибка при отмене записи в календаре. Пожалуйста, попробуйте еще раз.")
+			b.reply(ctx, chatID, "cancel.calendar_error")
 			// Не продолжаем, если не удалось удалить из календаря
 			return
 		}
 	}
 
-	// 3. Удаляем запись из нашей БД
-	if err := b.repo.DeleteBookingByID(bookingID); err != nil {
-		logrus.WithError(err).WithField("bookingID", bookingID).Error("CRITICAL: failed to delete booking from DB after calendar event was deleted")
-		b.sendMessage(chatID, "Произошла критическая ошибка при отмене. Пожалуйста, свяжитесь с администратором.")
+	// 3. Помечаем запись отмененной; она остается в истории пациента и клиники
+	if err := b.repo.UpdateStatus(ctx, bookingID, booking.StatusCancelledByPatient, ""); err != nil {
+		logrus.WithError(err).WithField("bookingID", bookingID).Error("CRITICAL: failed to cancel booking in DB after calendar event was deleted")
+		b.reply(ctx, chatID, "cancel.critical")
+		return
+	}
+
+	b.reply(ctx, chatID, "cancel.done")
+	// Администратор может предложить освободившееся время другим пациентам
+	b.notifyAdmins("admin.patient_cancelled", i18n.Vars{
+		"ID":       bookingToCancel.ID,
+		"Name":     bookingToCancel.Name,
+		"Contact":  bookingToCancel.Contact,
+		"Datetime": i18n.Default().DateTime(inMoscow(bookingToCancel.Datetime)),
+	})
+}
+
+// handleConfirmBooking отмечает, что пациент придет на прием (кнопка в напоминании)
+func (b *TgBot) handleConfirmBooking(ctx context.Context, chatID int64, arg string) {
+	bookingID, err := strconv.Atoi(arg)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to parse booking ID from callback")
+		b.reply(ctx, chatID, "booking.invalid_id")
+		return
+	}
+
+	bk, err := b.userBooking(ctx, chatID, bookingID)
+	if err != nil {
+		logrus.WithError(err).WithField("bookingID", bookingID).Error("Failed to get booking by ID for confirmation")
+		b.reply(ctx, chatID, "booking.not_found")
+		return
+	}
+	if bk.Status == booking.StatusConfirmed {
+		b.reply(ctx, chatID, "confirm.already")
+		return
+	}
+	if !bk.Status.Active() {
+		b.reply(ctx, chatID, "booking.inactive")
+		return
+	}
+
+	if err := b.repo.UpdateStatus(ctx, bookingID, booking.StatusConfirmed, ""); err != nil {
+		logrus.WithError(err).WithField("bookingID", bookingID).Error("Failed to confirm booking")
+		b.reply(ctx, chatID, "confirm.error")
+		return
+	}
+
+	l := i18n.FromContext(ctx)
+	b.sendMessage(chatID, l.T("confirm.done", i18n.Vars{"Datetime": l.DateTime(inMoscow(bk.Datetime))}))
+	b.notifyAdmins("admin.patient_confirmed", i18n.Vars{
+		"ID":       bk.ID,
+		"Name":     bk.Name,
+		"Contact":  bk.Contact,
+		"Datetime": i18n.Default().DateTime(inMoscow(bk.Datetime)),
+	})
+}
+
+// userBooking возвращает запись пациента chatID. Для чужой записи возвращается booking.ErrForbidden:
+// ID в callback-данных приходит от клиента и может быть подделан
+func (b *TgBot) userBooking(ctx context.Context, chatID int64, bookingID int) (*booking.Booking, error) {
+	bk, err := b.repo.GetBookingByID(ctx, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	if err := bk.Authorize(chatID); err != nil {
+		logrus.WithFields(logrus.Fields{"chatID": chatID, "bookingID": bookingID}).Warn("Attempt to access another user's booking")
+		return nil, err
+	}
+	return bk, nil
+}
+
+// rescheduledEventID возвращает событие календаря переносимой записи bookingID, чтобы ее время
+// не считалось занятым при выборе нового; для новой записи (bookingID = 0) - пустую строку
+func (b *TgBot) rescheduledEventID(ctx context.Context, chatID int64, bookingID int) string {
+	if bookingID == 0 {
+		return ""
+	}
+	bk, err := b.userBooking(ctx, chatID, bookingID)
+	if err != nil {
+		logrus.WithError(err).WithField("bookingID", bookingID).Error("Failed to get booking for rescheduling")
+		return ""
+	}
+	if bk.EventID == nil {
+		return ""
+	}
+	return *bk.EventID
+}
+
+// inMoscow переводит время приема в часовой пояс клиники
+func inMoscow(t time.Time) time.Time {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		logrus.WithError(err).Error("Failed to load location")
+		loc = time.UTC
+	}
+	return t.In(loc)
+}
+
+func (b *TgBot) handleRescheduleBooking(ctx context.Context, chatID int64, arg string) {
+	bookingID, err := strconv.Atoi(arg)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to parse booking ID from callback")
+		b.reply(ctx, chatID, "booking.invalid_id")
+		return
+	}
+
+	bk, err := b.userBooking(ctx, chatID, bookingID)
+	if err != nil {
+		logrus.WithError(err).WithField("bookingID", bookingID).Error("Failed to get booking by ID for rescheduling")
+		b.reply(ctx, chatID, "booking.not_found")
+		return
+	}
+	// Старая кнопка в чате не должна переносить отмененную или завершенную запись
+	if !bk.Status.Active() {
+		b.reply(ctx, chatID, "booking.inactive")
+		return
+	}
+
+	// Переносим к тому же врачу на ту же услугу, меняется только время
+	b.saveState(ctx, chatID, &session.UserState{
+		State:        StateAwaitingDate,
+		DoctorID:     bookingDoctorID(bk),
+		ServiceID:    bookingServiceID(bk),
+		RescheduleID: bk.ID,
+	})
+	b.sendDateKeyboard(ctx, chatID, bookingDoctorID(bk))
+}
+
+// completeReschedule переносит событие в календаре и запись в БД на новое время.
+// Если обновить БД не удалось, событие возвращается на прежнее время
+func (b *TgBot) completeReschedule(ctx context.Context, chatID int64, state *session.UserState, start, end time.Time) {
+	defer b.releaseHold(ctx, chatID)
+	defer b.resetState(ctx, chatID)
+
+	bk, err := b.userBooking(ctx, chatID, state.RescheduleID)
+	if err != nil {
+		logrus.WithError(err).WithField("bookingID", state.RescheduleID).Error("Failed to get booking by ID for rescheduling")
+		b.reply(ctx, chatID, "booking.not_found")
+		return
+	}
+
+	cal, _, err := b.calendarFor(ctx, state.DoctorID)
+	if err != nil {
+		logrus.WithError(err).WithField("doctorID", state.DoctorID).Error("Failed to get doctor calendar")
+		b.reply(ctx, chatID, "error.generic")
+		return
+	}
+
+	// Собственное событие записи не мешает сдвинуть прием на пересекающееся время
+	excludeEventID := ""
+	if bk.EventID != nil {
+		excludeEventID = *bk.EventID
+	}
+	isFree, err := cal.IsSlotFree(ctx, start, end, excludeEventID)
+	if err != nil {
+		logrus.WithError(err).WithField("slot", start).Error("Failed to check slot availability")
+		b.reply(ctx, chatID, "error.generic")
+		return
+	}
+	if !isFree {
+		b.reply(ctx, chatID, "slots.taken")
+		return
+	}
+
+	if bk.EventID != nil {
+		if err := cal.PatchEvent(ctx, *bk.EventID, start, end); err != nil {
+			logrus.WithError(err).WithField("eventID", *bk.EventID).Error("Failed to patch calendar event")
+			b.reply(ctx, chatID, "reschedule.calendar_error")
+			return
+		}
+	}
+
+	if err := b.repo.RescheduleBooking(ctx, bk.ID, start, end); err != nil {
+		logrus.WithError(err).WithField("bookingID", bk.ID).Error("Failed to reschedule booking in DB, rolling back calendar event")
+		if bk.EventID != nil {
+			if patchErr := cal.PatchEvent(ctx, *bk.EventID, bk.Datetime, bk.EndTime); patchErr != nil {
+				logrus.WithFields(logrus.Fields{
+					"eventID": *bk.EventID,
+					"error":   patchErr,
+				}).Error("CRITICAL: failed to rollback calendar event")
+				b.reply(ctx, chatID, "error.critical")
+				return
+			}
+		}
+		if errors.Is(err, booking.ErrSlotTaken) {
+			b.reply(ctx, chatID, "slots.taken")
+		} else {
+			b.reply(ctx, chatID, "reschedule.error")
+		}
 		return
 	}
 
-	b.sendMessage(chatID, "Ваша запись успешно отменена.")
+	l := i18n.FromContext(ctx)
+	b.sendMessage(chatID, l.T("reschedule.done", i18n.Vars{"Datetime": l.DateTime(inMoscow(start))}))
+	b.notifyAdmins("admin.rescheduled", i18n.Vars{
+		"ID":      bk.ID,
+		"Name":    bk.Name,
+		"Contact": bk.Contact,
+		"From":    i18n.Default().DateTime(inMoscow(bk.Datetime)),
+		"To":      i18n.Default().DateTime(inMoscow(start)),
+	})
+}
+
+// calendarFor возвращает календарь врача; doctorID = 0 - общий календарь клиники
+func (b *TgBot) calendarFor(ctx context.Context, doctorID int) (calendar.Provider, *doctor.Doctor, error) {
+	if doctorID == 0 {
+		return b.calendarSvc, nil, nil
+	}
+	d, err := b.doctors.GetDoctorByID(ctx, doctorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	week, err := d.Week(b.cfg.Telegram.WorkSchedule)
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.calendarSvc.ForCalendar(d.CalendarID, d.ID, week), d, nil
+}
+
+// weekFor возвращает расписание врача; doctorID = 0 - расписание клиники
+func (b *TgBot) weekFor(ctx context.Context, doctorID int) (schedule.Week, error) {
+	if doctorID == 0 {
+		return b.cfg.Telegram.WorkSchedule, nil
+	}
+	d, err := b.doctors.GetDoctorByID(ctx, doctorID)
+	if err != nil {
+		return schedule.Week{}, err
+	}
+	return d.Week(b.cfg.Telegram.WorkSchedule)
+}
+
+// deleteBookingEvent удаляет событие записи из календаря того врача, к которому она относится
+func (b *TgBot) deleteBookingEvent(ctx context.Context, bk *booking.Booking) error {
+	cal, _, err := b.calendarFor(ctx, bookingDoctorID(bk))
+	if err != nil {
+		return err
+	}
+	return cal.DeleteEvent(ctx, *bk.EventID)
+}
+
+// doctorNames возвращает имена активных врачей по их ID для отображения в списках записей
+func (b *TgBot) doctorNames(ctx context.Context) map[int]string {
+	names := make(map[int]string)
+	doctors, err := b.doctors.GetActiveDoctors(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get doctors")
+		return names
+	}
+	for _, d := range doctors {
+		names[d.ID] = d.Title()
+	}
+	return names
+}
+
+// serviceFor возвращает длительность приема по услуге; serviceID = 0 - каталог услуг не заполнен
+func (b *TgBot) serviceFor(ctx context.Context, serviceID int) (time.Duration, *catalog.Service, error) {
+	if serviceID == 0 {
+		return defaultSlotDuration, nil, nil
+	}
+	s, err := b.services.GetServiceByID(ctx, serviceID)
+	if err != nil {
+		return 0, nil, err
+	}
+	return s.Duration(), s, nil
+}
+
+// serviceNames возвращает названия активных услуг по их ID для отображения в списках записей
+func (b *TgBot) serviceNames(ctx context.Context) map[int]string {
+	names := make(map[int]string)
+	services, err := b.services.GetActiveServices(ctx)
+	if err != nil {
+		logrus.WithError(err).Error("Failed to get services")
+		return names
+	}
+	for _, s := range services {
+		names[s.ID] = s.Name
+	}
+	return names
+}
+
+// optionalID преобразует ID врача или услуги в значение для БД: 0 означает отсутствие
+func optionalID(id int) *int {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func bookingDoctorID(bk *booking.Booking) int {
+	if bk.DoctorID == nil {
+		return 0
+	}
+	return *bk.DoctorID
+}
+
+func bookingServiceID(bk *booking.Booking) int {
+	if bk.ServiceID == nil {
+		return 0
+	}
+	return *bk.ServiceID
+}
+
+// loadState возвращает сохраненное состояние диалога или nil, если его нет
+func (b *TgBot) loadState(ctx context.Context, chatID int64) *session.UserState {
+	state, err := b.states.Get(ctx, chatID)
+	if err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to load user state")
+		return nil
+	}
+	return state
+}
+
+func (b *TgBot) saveState(ctx context.Context, chatID int64, state *session.UserState) {
+	if err := b.states.Set(ctx, chatID, state); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to save user state")
+	}
+}
+
+func (b *TgBot) resetState(ctx context.Context, chatID int64) {
+	if err := b.states.Delete(ctx, chatID); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to delete user state")
+	}
+}
+
+// newContext возвращает контекст с ограничением времени на обработку одного обновления или фоновой задачи
+func (b *TgBot) newContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(b.ctx, time.Duration(b.cfg.Telegram.UpdateTimeoutSeconds)*time.Second)
+}
+
+func (b *TgBot) slotHoldTTL() time.Duration {
+	return time.Duration(b.cfg.Telegram.SlotHoldMinutes) * time.Minute
+}
+
+func (b *TgBot) releaseHold(ctx context.Context, chatID int64) {
+	if err := b.repo.ReleaseHold(ctx, chatID); err != nil {
+		logrus.WithError(err).WithField("chatID", chatID).Error("Failed to release slot hold")
+	}
+}
+
+// isClosed сообщает, попадает ли день на закрытие клиники или врача
+func isClosed(closures []schedule.Closure, doctorID int, date time.Time) bool {
+	for _, c := range closures {
+		if c.AppliesTo(doctorID) && c.Covers(date) {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeHeld возвращает слоты, прием длительностью duration в которые не пересекается с удержаниями
+func excludeHeld(slots []time.Time, duration time.Duration, held []booking.Hold) []time.Time {
+	if len(held) == 0 {
+		return slots
+	}
+	var result []time.Time
+	for _, slot := range slots {
+		taken := false
+		for _, h := range held {
+			if slot.Before(h.End) && slot.Add(duration).After(h.Start) {
+				taken = true
+				break
+			}
+		}
+		if !taken {
+			result = append(result, slot)
+		}
+	}
+	return result
 }
 
 func (b *TgBot) sendMessage(chatID int64, text string) {
@@ -481,12 +1350,21 @@ func (b *TgBot) sendMessage(chatID int64, text string) {
 	}
 }
 
-func (b *TgBot) sendMainMenu(chatID int64) {
-	msg := tgbot.NewMessage(chatID, "Добро пожаловать! Выберите действие:")
+// reply отправляет сообщение из каталога на языке пациента
+func (b *TgBot) reply(ctx context.Context, chatID int64, key string, vars ...i18n.Vars) {
+	b.sendMessage(chatID, i18n.FromContext(ctx).T(key, vars...))
+}
+
+func (b *TgBot) sendMainMenu(ctx context.Context, chatID int64) {
+	l := i18n.FromContext(ctx)
+	msg := tgbot.NewMessage(chatID, l.T("menu.welcome"))
 	keyboard := tgbot.NewInlineKeyboardMarkup(
 		tgbot.NewInlineKeyboardRow(
-			tgbot.NewInlineKeyboardButtonData("Записаться на приём", "book"),
-			tgbot.NewInlineKeyboardButtonData("Мои записи", "my_bookings"),
+			b.button(chatID, l.T("button.book"), actionBook),
+			b.button(chatID, l.T("button.my_bookings"), actionMyBookings),
+		),
+		tgbot.NewInlineKeyboardRow(
+			b.button(chatID, l.T("button.profile"), actionProfile),
 		),
 	)
 	msg.ReplyMarkup = keyboard
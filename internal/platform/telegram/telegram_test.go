@@ -1,12 +1,28 @@
 package telegram
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"stomatology_bot/configs"
 	"stomatology_bot/internal/booking"
+	"stomatology_bot/internal/catalog"
+	"stomatology_bot/internal/doctor"
+	"stomatology_bot/internal/patient"
+	"stomatology_bot/internal/platform/calendar"
+	"stomatology_bot/internal/preference"
+	"stomatology_bot/internal/schedule"
+	"stomatology_bot/internal/session"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/brianvoe/gofakeit/v7"
 	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -14,6 +30,7 @@ import (
 // Mock BotAPI
 type MockBotAPI struct {
 	mock.Mock
+	updates chan tgbot.Update
 }
 
 func (m *MockBotAPI) Send(c tgbot.Chattable) (tgbot.Message, error) {
@@ -22,10 +39,20 @@ func (m *MockBotAPI) Send(c tgbot.Chattable) (tgbot.Message, error) {
 }
 
 func (m *MockBotAPI) GetUpdatesChan(_ tgbot.UpdateConfig) tgbot.UpdatesChannel {
-	// Для тестов нам не нужно получать реальные обновления
+	// Обновления в тестах приходят только через канал updates, если он задан
+	if m.updates != nil {
+		return m.updates
+	}
 	return make(chan tgbot.Update)
 }
 
+func (m *MockBotAPI) StopReceivingUpdates() {}
+
+func (m *MockBotAPI) MakeRequest(endpoint string, params tgbot.Params) (*tgbot.APIResponse, error) {
+	args := m.Called(endpoint, params)
+	return args.Get(0).(*tgbot.APIResponse), args.Error(1)
+}
+
 func (m *MockBotAPI) Request(c tgbot.Chattable) (*tgbot.APIResponse, error) {
 	args := m.Called(c)
 	return args.Get(0).(*tgbot.APIResponse), args.Error(1)
@@ -36,89 +63,647 @@ type MockCalendarService struct {
 	mock.Mock
 }
 
-func (m *MockCalendarService) GetFreeSlots(date time.Time) ([]time.Time, error) {
-	args := m.Called(date)
+func (m *MockCalendarService) GetFreeSlots(_ context.Context, date time.Time, duration time.Duration, excludeEventID string) ([]time.Time, error) {
+	args := m.Called(date, duration, excludeEventID)
 	return args.Get(0).([]time.Time), args.Error(1)
 }
 
-func (m *MockCalendarService) CreateEvent(summary, description string, start, end time.Time) (string, string, error) {
+func (m *MockCalendarService) CreateEvent(_ context.Context, summary, description string, start, end time.Time) (string, string, error) {
 	args := m.Called(summary, description, start, end)
 	return args.String(0), args.String(1), args.Error(2)
 }
 
-func (m *MockCalendarService) DeleteEvent(eventID string) error {
+func (m *MockCalendarService) DeleteEvent(_ context.Context, eventID string) error {
 	args := m.Called(eventID)
 	return args.Error(0)
 }
 
-func (m *MockCalendarService) IsSlotFree(start time.Time, end time.Time) (bool, error) {
-	args := m.Called(start, end)
+func (m *MockCalendarService) PatchEvent(_ context.Context, eventID string, start, end time.Time) error {
+	args := m.Called(eventID, start, end)
+	return args.Error(0)
+}
+
+func (m *MockCalendarService) IsSlotFree(_ context.Context, start time.Time, end time.Time, excludeEventID string) (bool, error) {
+	args := m.Called(start, end, excludeEventID)
 	return args.Bool(0), args.Error(1)
 }
 
+func (m *MockCalendarService) ForCalendar(calendarID string, doctorID int, week schedule.Week) calendar.Provider {
+	args := m.Called(calendarID, doctorID, week)
+	return args.Get(0).(calendar.Provider)
+}
+
 // Mock BookingRepo
 type MockBookingRepo struct {
 	mock.Mock
 }
 
-func (m *MockBookingRepo) CreateBooking(b *booking.Booking) error {
+func (m *MockBookingRepo) CreateBooking(_ context.Context, b *booking.Booking) error {
 	args := m.Called(b)
 	return args.Error(0)
 }
 
-func (m *MockBookingRepo) GetUserBookings(userID int64) ([]booking.Booking, error) {
-	args := m.Called(userID)
+func (m *MockBookingRepo) GetUserBookings(_ context.Context, userID int64, statuses []booking.Status) ([]booking.Booking, error) {
+	args := m.Called(userID, statuses)
 	return args.Get(0).([]booking.Booking), args.Error(1)
 }
 
-func (m *MockBookingRepo) GetBookingByID(id int) (*booking.Booking, error) {
+func (m *MockBookingRepo) GetBookingByID(_ context.Context, id int) (*booking.Booking, error) {
 	args := m.Called(id)
 	return args.Get(0).(*booking.Booking), args.Error(1)
 }
 
-func (m *MockBookingRepo) DeleteBookingByID(id int) error {
-	args := m.Called(id)
+func (m *MockBookingRepo) GetAllBooking(_ context.Context) ([]booking.Booking, error) {
+	args := m.Called()
+	return args.Get(0).([]booking.Booking), args.Error(1)
+}
+
+func (m *MockBookingRepo) RescheduleBooking(_ context.Context, id int, start, end time.Time) error {
+	args := m.Called(id, start, end)
 	return args.Error(0)
 }
 
-func (m *MockBookingRepo) GetAllBooking() ([]booking.Booking, error) {
-	args := m.Called()
+func (m *MockBookingRepo) UpdateStatus(_ context.Context, id int, status booking.Status, reason string) error {
+	args := m.Called(id, status, reason)
+	return args.Error(0)
+}
+
+func (m *MockBookingRepo) GetUpcomingBookings(_ context.Context, from, to time.Time, statuses []booking.Status) ([]booking.Booking, error) {
+	args := m.Called(from, to, statuses)
 	return args.Get(0).([]booking.Booking), args.Error(1)
 }
 
+func (m *MockBookingRepo) HoldSlot(_ context.Context, userID int64, doctorID int, start, end time.Time, ttl time.Duration, excludeBookingID int) error {
+	args := m.Called(userID, doctorID, start, end, ttl, excludeBookingID)
+	return args.Error(0)
+}
+
+func (m *MockBookingRepo) ReleaseHold(_ context.Context, userID int64) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockBookingRepo) GetHeldSlots(_ context.Context, doctorID int, from, to time.Time, exceptUserID int64) ([]booking.Hold, error) {
+	args := m.Called(doctorID, from, to, exceptUserID)
+	return args.Get(0).([]booking.Hold), args.Error(1)
+}
+
+func (m *MockBookingRepo) ClaimReminder(_ context.Context, bookingID int, offset time.Duration, visit time.Time) (bool, error) {
+	args := m.Called(bookingID, offset, visit)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockBookingRepo) ReleaseReminder(_ context.Context, bookingID int, offset time.Duration, visit time.Time) error {
+	args := m.Called(bookingID, offset, visit)
+	return args.Error(0)
+}
+
+var doctorColumns = []string{"id", "name", "specialty", "calendar_id", "schedule", "active"}
+
+// expectDoctors ожидает запрос списка активных врачей
+func expectDoctors(dbMock pgxmock.PgxConnIface, doctors ...doctor.Doctor) {
+	rows := pgxmock.NewRows(doctorColumns)
+	for _, d := range doctors {
+		rows.AddRow(d.ID, d.Name, d.Specialty, d.CalendarID, d.Schedule, d.Active)
+	}
+	dbMock.ExpectQuery(`SELECT .* FROM doctors WHERE active`).WillReturnRows(rows)
+}
+
+// expectDoctor ожидает запрос врача по ID
+func expectDoctor(dbMock pgxmock.PgxConnIface, id int, schedule string) {
+	dbMock.ExpectQuery(`SELECT .* FROM doctors WHERE id = \$1`).
+		WithArgs(id).
+		WillReturnRows(pgxmock.NewRows(doctorColumns).AddRow(id, gofakeit.Name(), "Хирург", gofakeit.Email(), schedule, true))
+}
+
+var closureColumns = []string{"id", "doctor_id", "date_from", "date_to", "reason", "source"}
+
+// expectClosures ожидает запрос закрытий на ближайшие дни
+func expectClosures(dbMock pgxmock.PgxConnIface, closures ...schedule.Closure) {
+	rows := pgxmock.NewRows(closureColumns)
+	for _, c := range closures {
+		rows.AddRow(c.ID, c.DoctorID, c.DateFrom, c.DateTo, c.Reason, c.Source)
+	}
+	dbMock.ExpectQuery(`SELECT .* FROM clinic_closures`).
+		WithArgs(pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnRows(rows)
+}
+
+var serviceColumns = []string{"id", "name", "duration_minutes", "price", "description", "active"}
+
+// expectServices ожидает запрос списка активных услуг
+func expectServices(dbMock pgxmock.PgxConnIface, services ...catalog.Service) {
+	rows := pgxmock.NewRows(serviceColumns)
+	for _, s := range services {
+		rows.AddRow(s.ID, s.Name, s.DurationMinutes, s.Price, s.Description, s.Active)
+	}
+	dbMock.ExpectQuery(`SELECT .* FROM services WHERE active`).WillReturnRows(rows)
+}
+
 func TestTgBot_handleBookCommand(t *testing.T) {
 	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
 	bot := &TgBot{
-		api:        mockAPI,
-		userStates: make(map[int64]*UserState),
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		doctors:  doctor.NewRepo(dbMock),
+		services: catalog.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
 	}
 	chatID := gofakeit.Int64()
 
+	// Врачи и услуги не заведены - сразу предлагаем выбрать дату
+	expectDoctors(dbMock)
+	expectServices(dbMock)
+	expectClosures(dbMock)
+
 	// Ожидаем, что будет отправлено сообщение с клавиатурой
 	mockAPI.On("Send", mock.Anything).Return(tgbot.Message{}, nil).Once()
 
-	bot.handleBookCommand(chatID)
+	bot.handleBookCommand(context.Background(), chatID)
 
 	// Проверяем, что состояние пользователя установлено правильно
-	assert.Equal(t, StateAwaitingDate, bot.userStates[chatID].State)
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingDate, state.State)
 	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_handleBookCommand_SeveralDoctors(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		doctors:  doctor.NewRepo(dbMock),
+		services: catalog.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+
+	expectDoctors(dbMock,
+		doctor.Doctor{ID: 1, Name: gofakeit.Name(), Specialty: "Терапевт", CalendarID: gofakeit.Email(), Active: true},
+		doctor.Doctor{ID: 2, Name: gofakeit.Name(), Specialty: "Хирург", CalendarID: gofakeit.Email(), Active: true},
+	)
+
+	// Ожидаем клавиатуру выбора врача
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		keyboard, ok := msg.ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+		return ok && msg.Text == "Выберите врача:" && len(keyboard.InlineKeyboard) == 2 &&
+			unsign(chatID, *keyboard.InlineKeyboard[1][0].CallbackData) == "doctor_2"
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleBookCommand(context.Background(), chatID)
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingDoctor, state.State)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_handleDoctorSelection(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		doctors:  doctor.NewRepo(dbMock),
+		services: catalog.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+
+	expectDoctor(dbMock, 2, "")
+	expectServices(dbMock)
+	// Расписание врача для клавиатуры дат; у врача рабочие дни только по вторникам
+	expectDoctor(dbMock, 2, "tue 09:00-18:00")
+	expectClosures(dbMock)
+
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		keyboard, ok := msg.ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+		if !ok || msg.Text != "Выберите дату для записи:" || len(keyboard.InlineKeyboard) == 0 {
+			return false
+		}
+		for _, row := range keyboard.InlineKeyboard {
+			date, err := time.Parse(callbackDateLayout, strings.TrimPrefix(unsign(chatID, *row[0].CallbackData), "date_"))
+			if err != nil || date.Weekday() != time.Tuesday {
+				return false
+			}
+		}
+		return true
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionDoctor, "2"),
+		},
+	})
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingDate, state.State)
+	assert.Equal(t, 2, state.DoctorID)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_sendDateKeyboard_SkipsClosedDays(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		doctors:  doctor.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	// Врач работает ежедневно, клиника закрыта на 3-5 день от сегодня
+	closedFrom, closedTo := today.AddDate(0, 0, 3), today.AddDate(0, 0, 5)
+
+	expectDoctor(dbMock, 2, "mon-sun 09:00-18:00")
+	expectClosures(dbMock, schedule.Closure{ID: 1, DateFrom: closedFrom, DateTo: closedTo, Source: schedule.SourceManual})
+
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		keyboard, ok := msg.ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+		if !ok || len(keyboard.InlineKeyboard) != 27 {
+			return false
+		}
+		for _, row := range keyboard.InlineKeyboard {
+			date, err := time.ParseInLocation(callbackDateLayout, strings.TrimPrefix(unsign(chatID, *row[0].CallbackData), "date_"), loc)
+			if err != nil || (!date.Before(closedFrom) && !date.After(closedTo)) {
+				return false
+			}
+		}
+		return true
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.sendDateKeyboard(context.Background(), chatID, 2)
+
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_handleDoctorSelection_SeveralServices(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		doctors:  doctor.NewRepo(dbMock),
+		services: catalog.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+
+	expectDoctor(dbMock, 2, "")
+	expectServices(dbMock,
+		catalog.Service{ID: 1, Name: "Осмотр", DurationMinutes: 30, Price: 1000, Active: true},
+		catalog.Service{ID: 3, Name: "Консультация по имплантации", DurationMinutes: 90, Price: 3000, Active: true},
+	)
+
+	// Ожидаем клавиатуру выбора услуги
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		keyboard, ok := msg.ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+		return ok && msg.Text == "Выберите услугу:" && len(keyboard.InlineKeyboard) == 2 &&
+			unsign(chatID, *keyboard.InlineKeyboard[1][0].CallbackData) == "service_3"
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionDoctor, "2"),
+		},
+	})
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingService, state.State)
+	assert.Equal(t, 2, state.DoctorID)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_handleServiceSelection(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		doctors:  doctor.NewRepo(dbMock),
+		services: catalog.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingService, DoctorID: 2}))
+
+	dbMock.ExpectQuery(`SELECT .* FROM services WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnRows(pgxmock.NewRows(serviceColumns).AddRow(3, "Консультация по имплантации", 90, 3000, "", true))
+	expectDoctor(dbMock, 2, "")
+	expectClosures(dbMock)
+
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return msg.Text == "Выберите дату для записи:"
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionService, "3"),
+		},
+	})
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingDate, state.State)
+	assert.Equal(t, 2, state.DoctorID)
+	assert.Equal(t, 3, state.ServiceID)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_handleRescheduleBooking(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		repo:     booking.NewRepo(dbMock),
+		doctors:  doctor.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+	doctorID := 2
+	serviceID := 3
+	eventID := gofakeit.UUID()
+	start := gofakeit.Date()
+
+	dbMock.ExpectQuery(`SELECT .* FROM bookings WHERE id = \$1`).
+		WithArgs(7).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "user_id", "name", "contact", "datetime", "event_id", "doctor_id", "end_time", "service_id", "confirmed_at", "status", "status_reason", "status_changed_at", "patient_id"}).
+			AddRow(7, chatID, gofakeit.Name(), "+79161234567", start, &eventID, &doctorID, start.Add(time.Hour), &serviceID, nil, booking.StatusScheduled, "", start, nil))
+	expectDoctor(dbMock, doctorID, "")
+	expectClosures(dbMock)
+
+	// Пациент сразу переходит к выбору новой даты
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return msg.Text == "Выберите дату для записи:"
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionReschedule, "7"),
+		},
+	})
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingDate, state.State)
+	assert.Equal(t, 7, state.RescheduleID)
+	assert.Equal(t, doctorID, state.DoctorID)
+	assert.Equal(t, serviceID, state.ServiceID)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_Reschedule_OverlappingOwnSlot(t *testing.T) {
+	bot, mockCalendar, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+	eventID := gofakeit.UUID()
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	start := time.Date(2025, 10, 24, 10, 0, 0, 0, loc)
+	bk := &booking.Booking{UserID: chatID, Name: "Иван Петров", Contact: "+79161234567", Datetime: start, EndTime: start.Add(time.Hour), EventID: &eventID}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), bk))
+
+	// Сдвиг на 30 минут пересекается только с собственным событием записи: оно не считается занятым
+	// ни при выборе времени, ни при удержании, ни при финальной проверке
+	slot := start.Add(30 * time.Minute)
+	date := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
+	mockCalendar.On("GetFreeSlots", date, time.Hour, eventID).Return([]time.Time{slot}, nil).Once()
+	mockCalendar.On("IsSlotFree", sameTime(slot), sameTime(slot.Add(time.Hour)), eventID).Return(true, nil).Once()
+	mockCalendar.On("PatchEvent", eventID, sameTime(slot), sameTime(slot.Add(time.Hour))).Return(nil).Once()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate, RescheduleID: bk.ID}))
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionDate, date.Format(callbackDateLayout))))
+	bot.handleUpdate(newCallbackUpdate(chatID, sent.firstButton(t, chatID)))
+
+	assert.Equal(t, "Ваша запись перенесена на "+slot.Format("02.01.2006 в 15:04")+".", sent.last(chatID).Text)
+	moved, err := bot.repo.GetBookingByID(context.Background(), bk.ID)
+	assert.NoError(t, err)
+	assert.True(t, slot.Equal(moved.Datetime))
+	mockCalendar.AssertExpectations(t)
+}
+
+func TestTgBot_handleRescheduleBooking_Inactive(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:    mockAPI,
+		cfg:    newTestConfig(),
+		repo:   booking.NewRepo(dbMock),
+		states: session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+	doctorID := 2
+	eventID := gofakeit.UUID()
+	start := gofakeit.Date()
+
+	dbMock.ExpectQuery(`SELECT .* FROM bookings WHERE id = \$1`).
+		WithArgs(7).
+		WillReturnRows(pgxmock.NewRows([]string{"id", "user_id", "name", "contact", "datetime", "event_id", "doctor_id", "end_time", "service_id", "confirmed_at", "status", "status_reason", "status_changed_at", "patient_id"}).
+			AddRow(7, chatID, gofakeit.Name(), "+79161234567", start, &eventID, &doctorID, start.Add(time.Hour), nil, nil, booking.StatusCancelledByPatient, "", start, nil))
+
+	// Кнопка «Перенести» из старого напоминания не открывает выбор даты для отмененной записи
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return msg.Text == "Эта запись уже отменена или завершена."
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionReschedule, "7"),
+		},
+	})
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func newTestConfig() *configs.Config {
+	return &configs.Config{
+		Telegram: configs.TelegramConfig{SlotHoldMinutes: 10, UpdateTimeoutSeconds: 30, ShutdownTimeoutSeconds: 20, Workers: 10, WorkSchedule: schedule.Default(9, 18),
+			CallbackSecret: "test-secret", CallbackTTLHours: 24},
+	}
+}
+
+func TestTgBot_handleDateSelection(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	mockCalendar := new(MockCalendarService)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:         mockAPI,
+		cfg:         newTestConfig(),
+		repo:        booking.NewRepo(dbMock),
+		services:    catalog.NewRepo(dbMock),
+		calendarSvc: mockCalendar,
+		states:      session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	date := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC)
+	slots := []time.Time{
+		time.Date(2025, 10, 24, 9, 0, 0, 0, loc),
+		time.Date(2025, 10, 24, 9, 30, 0, 0, loc),
+		time.Date(2025, 10, 24, 10, 0, 0, 0, loc),
+	}
+	mockCalendar.On("GetFreeSlots", date, time.Hour, "").Return(slots, nil).Once()
+
+	// 9:00-9:30 удерживает другой пациент
+	dbMock.ExpectQuery(`SELECT slot_start, slot_end FROM slot_holds`).
+		WithArgs(0, slots[0], slots[2].Add(time.Hour), chatID).
+		WillReturnRows(pgxmock.NewRows([]string{"slot_start", "slot_end"}).AddRow(slots[0], slots[1]))
+
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		keyboard, ok := msg.ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+		return ok && msg.Text == "Выберите время для записи:" && len(keyboard.InlineKeyboard) == 2 &&
+			keyboard.InlineKeyboard[0][0].Text == "09:30" && keyboard.InlineKeyboard[1][0].Text == "10:00"
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionDate, "20251024"),
+		},
+	})
+
+	mockAPI.AssertExpectations(t)
+	mockCalendar.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_handleDateSelection_InvalidDate(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate, DoctorID: 2}))
+
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionDate, "24.10.2025")))
+	assert.Equal(t, "Неверный формат даты.", sent.last(chatID).Text)
+
+	// Шаг диалога не меняется: пациент выбирает другую дату на той же клавиатуре
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, state) {
+		assert.Equal(t, StateAwaitingDate, state.State)
+		assert.Equal(t, 2, state.DoctorID)
+	}
 }
 
 func TestTgBot_handleTimeSelection(t *testing.T) {
 	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
 	bot := &TgBot{
-		api:        mockAPI,
-		userStates: make(map[int64]*UserState),
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		repo:     booking.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+		patients: patient.NewMemoryStore(),
 	}
 	chatID := gofakeit.Int64()
-	slot := gofakeit.Date()
+	// Время из кнопки разбирается по Москве
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	slot := gofakeit.Date().Truncate(time.Second).In(loc)
+
+	// Пользователь уже выбрал врача и дату
+	doctorID := gofakeit.Number(1, 100)
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingTime, DoctorID: doctorID}))
+
+	// Услуга не выбрана - слот удерживается на стандартный час
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`DELETE FROM slot_holds WHERE user_id = \$1`).
+		WithArgs(chatID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	dbMock.ExpectQuery(`INSERT INTO slot_holds`).
+		WithArgs(doctorID, slot, slot.Add(time.Hour), chatID, int64(600), 0).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(1))
+	dbMock.ExpectCommit()
 
 	update := tgbot.Update{
 		CallbackQuery: &tgbot.CallbackQuery{
 			ID:      gofakeit.UUID(),
 			From:    &tgbot.User{ID: chatID},
 			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
-			Data:    "time_" + slot.Format(time.RFC3339),
+			Data:    signCallback(chatID, actionTime, strconv.FormatInt(slot.Unix(), 36)),
 		},
 	}
 
@@ -127,11 +712,739 @@ func TestTgBot_handleTimeSelection(t *testing.T) {
 	// Ожидаем, что будет отправлен ответ на callback query
 	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
 
-	bot.handleCallbackQuery(update)
+	bot.handleCallbackQuery(context.Background(), update)
 
 	// Проверяем, что состояние пользователя установлено правильно
-	assert.Equal(t, StateAwaitingName, bot.userStates[chatID].State)
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingName, state.State)
 	// Сравниваем время, обнулив наносекунды
-	assert.Equal(t, slot.Truncate(time.Second), bot.userStates[chatID].TempTime.Truncate(time.Second))
+	assert.Equal(t, slot.Truncate(time.Second), state.TempTime.Truncate(time.Second))
+	assert.Equal(t, doctorID, state.DoctorID)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_handleTimeSelection_ServiceDuration(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		repo:     booking.NewRepo(dbMock),
+		services: catalog.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+		patients: patient.NewMemoryStore(),
+	}
+	chatID := gofakeit.Int64()
+	// Время из кнопки разбирается по Москве
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	slot := gofakeit.Date().Truncate(time.Second).In(loc)
+
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingTime, ServiceID: 3}))
+
+	// Слот удерживается на всю длительность выбранной услуги
+	dbMock.ExpectQuery(`SELECT .* FROM services WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnRows(pgxmock.NewRows(serviceColumns).AddRow(3, "Консультация по имплантации", 90, 3000, "", true))
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`DELETE FROM slot_holds`).
+		WithArgs(chatID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	dbMock.ExpectQuery(`INSERT INTO slot_holds`).
+		WithArgs(0, slot, slot.Add(90*time.Minute), chatID, int64(600), 0).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(1))
+	dbMock.ExpectCommit()
+
+	mockAPI.On("Send", mock.Anything).Return(tgbot.Message{}, nil).Once()
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionTime, strconv.FormatInt(slot.Unix(), 36)),
+		},
+	})
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingName, state.State)
+	assert.Equal(t, 3, state.ServiceID)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestExcludeHeld(t *testing.T) {
+	base := time.Date(2025, 10, 24, 9, 0, 0, 0, time.UTC)
+	slots := []time.Time{base, base.Add(30 * time.Minute), base.Add(time.Hour), base.Add(90 * time.Minute)}
+	// Другой пациент удерживает 10:00-10:30
+	held := []booking.Hold{{Start: base.Add(time.Hour), End: base.Add(90 * time.Minute)}}
+
+	// Часовой прием в 9:30 и 10:00 задевает удержание
+	free := excludeHeld(slots, time.Hour, held)
+	assert.Equal(t, []time.Time{base, base.Add(90 * time.Minute)}, free)
+}
+
+func TestTgBot_handleTimeSelection_SlotTaken(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		repo:     booking.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+		patients: patient.NewMemoryStore(),
+	}
+	chatID := gofakeit.Int64()
+	// Время из кнопки разбирается по Москве
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	slot := gofakeit.Date().Truncate(time.Second).In(loc)
+
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingTime}))
+
+	// Слот удерживается другим пациентом - вставка не возвращает строк
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`DELETE FROM slot_holds WHERE user_id = \$1`).
+		WithArgs(chatID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+	dbMock.ExpectQuery(`INSERT INTO slot_holds`).
+		WithArgs(0, slot, slot.Add(time.Hour), chatID, int64(600), 0).
+		WillReturnError(pgx.ErrNoRows)
+	dbMock.ExpectRollback()
+
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return msg.Text == "К сожалению, этот слот только что заняли. Пожалуйста, выберите другое время."
+	})).Return(tgbot.Message{}, nil).Once()
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    signCallback(chatID, actionTime, strconv.FormatInt(slot.Unix(), 36)),
+		},
+	})
+
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+	mockAPI.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_stateSurvivesRestart(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	store := session.NewMemoryStore(time.Hour)
+	chatID := gofakeit.Int64()
+	slot := gofakeit.Date()
+
+	// Пользователь выбрал время и ввел имя, после чего бот перезапустился
+	assert.NoError(t, store.Set(context.Background(), chatID, &session.UserState{
+		State:    StateAwaitingName,
+		TempTime: slot,
+	}))
+	bot := &TgBot{api: mockAPI, states: store}
+
+	mockAPI.On("Send", mock.Anything).Return(tgbot.Message{}, nil).Once()
+
+	bot.processUpdate(context.Background(), tgbot.Update{
+		Message: &tgbot.Message{
+			Chat: &tgbot.Chat{ID: chatID},
+			Text: gofakeit.Name(),
+		},
+	})
+
+	state, err := store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingContact, state.State)
+	assert.Equal(t, slot.Truncate(time.Second), state.TempTime.Truncate(time.Second))
 	mockAPI.AssertExpectations(t)
 }
+
+// Запускать с флагом -race: одновременные нажатия кнопок не должны приводить к гонкам
+func TestTgBot_handleCallbackQuery_Concurrent(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer dbMock.Close(context.Background())
+	dbMock.MatchExpectationsInOrder(false)
+
+	bot := &TgBot{
+		api:      mockAPI,
+		cfg:      newTestConfig(),
+		doctors:  doctor.NewRepo(dbMock),
+		services: catalog.NewRepo(dbMock),
+		closures: schedule.NewRepo(dbMock),
+		states:   session.NewMemoryStore(time.Hour),
+	}
+	mockAPI.On("Send", mock.Anything).Return(tgbot.Message{}, nil)
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil)
+
+	chatIDs := []int64{gofakeit.Int64(), gofakeit.Int64(), gofakeit.Int64()}
+	// Каждое нажатие "Записаться" запрашивает списки врачей и услуг
+	for i := 0; i < 25*len(chatIDs); i++ {
+		expectDoctors(dbMock)
+		expectServices(dbMock)
+		expectClosures(dbMock)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		for _, chatID := range chatIDs {
+			wg.Add(1)
+			go func(chatID int64, i int) {
+				defer wg.Done()
+				if i%2 == 1 {
+					bot.processUpdate(context.Background(), tgbot.Update{
+						Message: &tgbot.Message{
+							Chat:     &tgbot.Chat{ID: chatID},
+							Text:     "/start",
+							Entities: []tgbot.MessageEntity{{Type: "bot_command", Offset: 0, Length: 6}},
+						},
+					})
+					return
+				}
+				bot.handleCallbackQuery(context.Background(), tgbot.Update{
+					CallbackQuery: &tgbot.CallbackQuery{
+						ID:      gofakeit.UUID(),
+						From:    &tgbot.User{ID: chatID},
+						Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+						Data:    signCallback(chatID, actionBook),
+					},
+				})
+			}(chatID, i)
+		}
+	}
+	wg.Wait()
+
+	for _, chatID := range chatIDs {
+		state, err := bot.states.Get(context.Background(), chatID)
+		assert.NoError(t, err)
+		assert.NotNil(t, state)
+	}
+}
+
+func TestTgBot_handleCancelBooking_CalendarError(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	mockRepo := new(MockBookingRepo)
+	mockCalendar := new(MockCalendarService)
+
+	bot := &TgBot{
+		api:         mockAPI,
+		cfg:         newTestConfig(),
+		repo:        mockRepo,
+		calendarSvc: mockCalendar,
+		states:      session.NewMemoryStore(time.Hour),
+	}
+	chatID := gofakeit.Int64()
+	eventID := gofakeit.UUID()
+
+	mockRepo.On("GetBookingByID", 5).Return(&booking.Booking{ID: 5, UserID: chatID, EventID: &eventID, Status: booking.StatusScheduled}, nil).Once()
+	mockCalendar.On("DeleteEvent", eventID).Return(errors.New("calendar unavailable")).Once()
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil).Once()
+	mockAPI.On("Send", mock.MatchedBy(func(msg tgbot.MessageConfig) bool {
+		return strings.HasPrefix(msg.Text, "Ошибка при отмене записи в календаре")
+	})).Return(tgbot.Message{}, nil).Once()
+
+	bot.handleCallbackQuery(context.Background(), newCallbackUpdate(chatID, signCallback(chatID, actionCancel, "5")))
+
+	// Запись в БД остается, пока событие не удалено из календаря
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything, mock.Anything, mock.Anything)
+	mockRepo.AssertExpectations(t)
+	mockCalendar.AssertExpectations(t)
+	mockAPI.AssertExpectations(t)
+}
+
+// sentMessages записывает все сообщения, отправленные ботом
+type sentMessages struct {
+	mu       sync.Mutex
+	messages []tgbot.MessageConfig
+}
+
+func recordMessages(mockAPI *MockBotAPI) *sentMessages {
+	sent := &sentMessages{}
+	mockAPI.On("Request", mock.Anything).Return(&tgbot.APIResponse{}, nil)
+	mockAPI.On("Send", mock.Anything).Run(func(args mock.Arguments) {
+		sent.mu.Lock()
+		defer sent.mu.Unlock()
+		sent.messages = append(sent.messages, args.Get(0).(tgbot.MessageConfig))
+	}).Return(tgbot.Message{}, nil)
+	return sent
+}
+
+// last возвращает последнее сообщение в чат
+func (s *sentMessages) last(chatID int64) tgbot.MessageConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		if s.messages[i].ChatID == chatID {
+			return s.messages[i]
+		}
+	}
+	return tgbot.MessageConfig{}
+}
+
+// firstButton возвращает данные первой кнопки последнего сообщения в чат
+func (s *sentMessages) firstButton(t *testing.T, chatID int64) string {
+	keyboard, ok := s.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	if !assert.True(t, ok, "last message has no inline keyboard") || len(keyboard.InlineKeyboard) == 0 {
+		t.FailNow()
+	}
+	return *keyboard.InlineKeyboard[0][0].CallbackData
+}
+
+// sameTime сравнивает моменты времени без учета часового пояса: время из кнопки разбирается в фиксированной зоне
+func sameTime(expected time.Time) interface{} {
+	return mock.MatchedBy(func(actual time.Time) bool { return actual.Equal(expected) })
+}
+
+func newCallbackUpdate(chatID int64, data string) tgbot.Update {
+	return tgbot.Update{
+		CallbackQuery: &tgbot.CallbackQuery{
+			ID:      gofakeit.UUID(),
+			From:    &tgbot.User{ID: chatID},
+			Message: &tgbot.Message{Chat: &tgbot.Chat{ID: chatID}},
+			Data:    data,
+		},
+	}
+}
+
+// signCallback подписывает данные кнопки тем же ключом, что и бот с newTestConfig
+func signCallback(chatID int64, action string, args ...string) string {
+	cfg := newTestConfig()
+	data, err := newCallbackCodec(cfg.Telegram.CallbackSecret, time.Hour).encode(chatID, action, args...)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// unsign проверяет подпись данных кнопки и возвращает их в виде "действие_аргументы"
+func unsign(chatID int64, data string) string {
+	decoded, err := newCallbackCodec(newTestConfig().Telegram.CallbackSecret, time.Hour).decode(chatID, data)
+	if err != nil {
+		return ""
+	}
+	return strings.Join(append([]string{decoded.Action}, decoded.Args...), "_")
+}
+
+func newTextUpdate(chatID int64, text string) tgbot.Update {
+	return tgbot.Update{
+		Message: &tgbot.Message{
+			Chat: &tgbot.Chat{ID: chatID},
+			From: &tgbot.User{ID: chatID},
+			Text: text,
+		},
+	}
+}
+
+// newScenarioBot создает бота с записями в памяти: клиника без врачей и каталога услуг
+func newScenarioBot(t *testing.T, adminID int64) (*TgBot, *MockCalendarService, pgxmock.PgxConnIface, *sentMessages) {
+	mockAPI := new(MockBotAPI)
+	mockCalendar := new(MockCalendarService)
+	dbMock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	t.Cleanup(func() { dbMock.Close(context.Background()) })
+
+	cfg := newTestConfig()
+	cfg.Telegram.AdminIDs = []int64{adminID}
+	bookings, patients := booking.NewMemoryStore(), patient.NewMemoryStore()
+	// Как NOT EXISTS в запросе Repo.DeleteDependent
+	patients.SetBookingCheck(func(patientID int, statuses []string) bool {
+		var active []booking.Status
+		for _, status := range statuses {
+			active = append(active, booking.Status(status))
+		}
+		found, err := bookings.GetUpcomingBookings(context.Background(), time.Time{}, time.Now().AddDate(100, 0, 0), active)
+		assert.NoError(t, err)
+		for _, bk := range found {
+			if bk.PatientID != nil && *bk.PatientID == patientID {
+				return true
+			}
+		}
+		return false
+	})
+	bot := NewBot(mockAPI, cfg, bookings, doctor.NewRepo(dbMock), catalog.NewRepo(dbMock),
+		schedule.NewRepo(dbMock), mockCalendar, session.NewMemoryStore(time.Hour), preference.NewMemoryStore(), patients)
+	return bot, mockCalendar, dbMock, recordMessages(mockAPI)
+}
+
+func TestTgBot_BookingScenario(t *testing.T) {
+	adminID := gofakeit.Int64()
+	bot, mockCalendar, dbMock, sent := newScenarioBot(t, adminID)
+	chatID := gofakeit.Int64()
+	eventID := gofakeit.UUID()
+
+	// Запись: врач и услуга не выбираются, сразу предлагаются даты
+	expectDoctors(dbMock)
+	expectServices(dbMock)
+	expectClosures(dbMock)
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionBook)))
+	dateData := sent.firstButton(t, chatID)
+
+	date, err := time.Parse(callbackDateLayout, strings.TrimPrefix(unsign(chatID, dateData), "date_"))
+	assert.NoError(t, err)
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	slot := time.Date(date.Year(), date.Month(), date.Day(), 10, 0, 0, 0, loc)
+	mockCalendar.On("GetFreeSlots", date, time.Hour, "").Return([]time.Time{slot}, nil).Once()
+	bot.handleUpdate(newCallbackUpdate(chatID, dateData))
+	timeData := sent.firstButton(t, chatID)
+
+	bot.handleUpdate(newCallbackUpdate(chatID, timeData))
+	assert.Equal(t, "Пожалуйста, введите ваше Имя и Фамилию.", sent.last(chatID).Text)
+
+	bot.handleUpdate(newTextUpdate(chatID, "Иван Петров"))
+	// Номер можно отправить кнопкой из профиля Telegram
+	keyboard := sent.last(chatID).ReplyMarkup.(tgbot.ReplyKeyboardMarkup)
+	assert.True(t, keyboard.Keyboard[0][0].RequestContact)
+	// Неверный номер не сбрасывает диалог
+	bot.handleUpdate(newTextUpdate(chatID, "12345"))
+	assert.Contains(t, sent.last(chatID).Text, "Не удалось распознать номер")
+
+	mockCalendar.On("IsSlotFree", sameTime(slot), sameTime(slot.Add(time.Hour)), "").Return(true, nil).Once()
+	mockCalendar.On("CreateEvent", "Запись: Иван Петров", mock.Anything, sameTime(slot), sameTime(slot.Add(time.Hour))).Return("", eventID, nil).Once()
+	bot.handleUpdate(newTextUpdate(chatID, "8 (916) 123-45-67"))
+	assert.Equal(t, "Вы успешно записаны на "+slot.Format("02.01.2006 в 15:04")+".", sent.last(chatID).Text)
+	assert.Contains(t, sent.last(adminID).Text, "Иван Петров")
+
+	bookings, err := bot.repo.GetUserBookings(context.Background(), chatID, booking.ActiveStatuses)
+	assert.NoError(t, err)
+	if assert.Len(t, bookings, 1) {
+		assert.Equal(t, "+79161234567", bookings[0].Contact)
+		assert.True(t, slot.Add(time.Hour).Equal(bookings[0].EndTime))
+		assert.Equal(t, eventID, *bookings[0].EventID)
+	}
+	// Первая запись заполняет профиль пациента
+	profile, err := bot.patients.GetByUserID(context.Background(), chatID)
+	assert.NoError(t, err)
+	if assert.NotNil(t, profile) && assert.Len(t, bookings, 1) {
+		assert.Equal(t, "Иван Петров", profile.Name)
+		assert.Equal(t, "+79161234567", profile.Phone)
+		assert.Equal(t, &profile.ID, bookings[0].PatientID)
+	}
+	// Диалог завершен, удержание времени снято
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, state)
+	held, err := bot.repo.GetHeldSlots(context.Background(), 0, slot, slot.Add(time.Hour), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, held)
+
+	// Просмотр и отмена записи
+	expectDoctors(dbMock)
+	expectServices(dbMock)
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionMyBookings)))
+	bookingKeyboard := sent.last(chatID).ReplyMarkup.(tgbot.InlineKeyboardMarkup)
+	cancelData := *bookingKeyboard.InlineKeyboard[0][1].CallbackData
+	assert.Equal(t, fmt.Sprintf("cancel_%d", bookings[0].ID), unsign(chatID, cancelData))
+
+	mockCalendar.On("DeleteEvent", eventID).Return(nil).Once()
+	bot.handleUpdate(newCallbackUpdate(chatID, cancelData))
+	assert.Equal(t, "Ваша запись успешно отменена.", sent.last(chatID).Text)
+
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionMyBookings)))
+	assert.Equal(t, "У вас пока нет записей.", sent.last(chatID).Text)
+
+	mockCalendar.AssertExpectations(t)
+	assert.NoError(t, dbMock.ExpectationsWereMet())
+}
+
+func TestTgBot_BookingScenario_SlotHeldByAnotherPatient(t *testing.T) {
+	bot, mockCalendar, _, sent := newScenarioBot(t, gofakeit.Int64())
+	firstChat, secondChat := gofakeit.Int64(), gofakeit.Int64()
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	slot := time.Date(2025, 10, 24, 10, 0, 0, 0, loc)
+	mockCalendar.On("GetFreeSlots", mock.Anything, time.Hour, "").Return([]time.Time{slot}, nil)
+
+	for _, chatID := range []int64{firstChat, secondChat} {
+		assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+	}
+	timeArg := strconv.FormatInt(slot.Unix(), 36)
+
+	// Первый пациент удерживает время, второму оно больше не предлагается
+	bot.handleUpdate(newCallbackUpdate(firstChat, signCallback(firstChat, actionTime, timeArg)))
+	assert.Equal(t, "Пожалуйста, введите ваше Имя и Фамилию.", sent.last(firstChat).Text)
+	bot.handleUpdate(newCallbackUpdate(secondChat, signCallback(secondChat, actionDate, "20251024")))
+	assert.Equal(t, "На выбранную дату нет свободных слотов.", sent.last(secondChat).Text)
+
+	// Даже по старой кнопке второй пациент не может занять это время
+	bot.handleUpdate(newCallbackUpdate(secondChat, signCallback(secondChat, actionTime, timeArg)))
+	assert.Contains(t, sent.last(secondChat).Text, "этот слот только что заняли")
+}
+
+func TestTgBot_BookingScenario_SharedContact(t *testing.T) {
+	adminID := gofakeit.Int64()
+	bot, mockCalendar, _, sent := newScenarioBot(t, adminID)
+	chatID := gofakeit.Int64()
+
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+	slot := time.Date(2025, 10, 24, 10, 0, 0, 0, loc)
+	mockCalendar.On("GetFreeSlots", mock.Anything, time.Hour, "").Return([]time.Time{slot}, nil)
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+
+	bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionTime, strconv.FormatInt(slot.Unix(), 36))))
+	bot.handleUpdate(newTextUpdate(chatID, "Иван Петров"))
+
+	// Пересланный чужой контакт не принимается за номер пациента
+	forwarded := newTextUpdate(chatID, "")
+	forwarded.Message.Contact = &tgbot.Contact{PhoneNumber: "79035554433", UserID: gofakeit.Int64()}
+	bot.handleUpdate(forwarded)
+	assert.Contains(t, sent.last(chatID).Text, "Это не ваш контакт")
+	assert.True(t, sent.last(chatID).ReplyMarkup.(tgbot.ReplyKeyboardMarkup).Keyboard[0][0].RequestContact)
+	state, err := bot.states.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, StateAwaitingContact, state.State)
+
+	// Telegram присылает номер из профиля без "+"
+	mockCalendar.On("IsSlotFree", sameTime(slot), sameTime(slot.Add(time.Hour)), "").Return(true, nil).Once()
+	mockCalendar.On("CreateEvent", "Запись: Иван Петров", mock.Anything, sameTime(slot), sameTime(slot.Add(time.Hour))).Return("", gofakeit.UUID(), nil).Once()
+	update := newTextUpdate(chatID, "")
+	update.Message.Contact = &tgbot.Contact{PhoneNumber: "79161234567", UserID: chatID}
+	bot.handleUpdate(update)
+
+	assert.Equal(t, "Вы успешно записаны на "+slot.Format("02.01.2006 в 15:04")+".", sent.last(chatID).Text)
+	assert.Contains(t, sent.last(adminID).Text, "Контакт: +79161234567")
+	bookings, err := bot.repo.GetUserBookings(context.Background(), chatID, booking.ActiveStatuses)
+	assert.NoError(t, err)
+	if assert.Len(t, bookings, 1) {
+		assert.Equal(t, "+79161234567", bookings[0].Contact)
+	}
+
+	// После приема номера кнопка отправки номера убирается
+	var removed bool
+	for _, msg := range sent.messages {
+		if msg.ChatID == chatID && msg.Text == "Номер для связи: +79161234567" {
+			_, removed = msg.ReplyMarkup.(tgbot.ReplyKeyboardRemove)
+		}
+	}
+	assert.True(t, removed)
+}
+
+// blockingCalendar имитирует зависший Google Calendar: запрос завершается только с отменой контекста
+type blockingCalendar struct {
+	MockCalendarService
+	started chan struct{}
+}
+
+func (c *blockingCalendar) GetFreeSlots(ctx context.Context, _ time.Time, _ time.Duration, _ string) ([]time.Time, error) {
+	close(c.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTgBot_Stop_CancelsInFlightUpdates(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	cal := &blockingCalendar{started: make(chan struct{})}
+	bot.calendarSvc = cal
+	chatID := gofakeit.Int64()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		bot.handleUpdate(newCallbackUpdate(chatID, signCallback(chatID, actionDate, "20251024")))
+	}()
+
+	<-cal.started
+	bot.Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not cancelled by Stop")
+	}
+	assert.Equal(t, "Не удалось получить свободные слоты. Попробуйте позже.", sent.last(chatID).Text)
+}
+
+func TestTgBot_newContext_Deadline(t *testing.T) {
+	bot, _, _, _ := newScenarioBot(t, gofakeit.Int64())
+	bot.cfg.Telegram.UpdateTimeoutSeconds = 5
+
+	ctx, cancel := bot.newContext()
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(5*time.Second), deadline, time.Second)
+}
+
+// gatedCalendar отвечает на запрос свободных слотов только после закрытия release
+type gatedCalendar struct {
+	MockCalendarService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *gatedCalendar) GetFreeSlots(ctx context.Context, _ time.Time, _ time.Duration, _ string) ([]time.Time, error) {
+	close(c.started)
+	select {
+	case <-c.release:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// startBot запускает бота с каналом обновлений из теста и возвращает канал, закрываемый по выходу из Start
+func startBot(ctx context.Context, bot *TgBot, updates chan tgbot.Update) chan struct{} {
+	bot.api.(*MockBotAPI).updates = updates
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := bot.Start(ctx); err != nil {
+			panic(err)
+		}
+	}()
+	return stopped
+}
+
+func TestTgBot_Start_DrainsInFlightUpdates(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	cal := &gatedCalendar{started: make(chan struct{}), release: make(chan struct{})}
+	bot.calendarSvc = cal
+	chatID := gofakeit.Int64()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan tgbot.Update, 1)
+	stopped := startBot(ctx, bot, updates)
+
+	updates <- newCallbackUpdate(chatID, signCallback(chatID, actionDate, "20251024"))
+	<-cal.started
+	cancel()
+
+	// Пока обработчик не завершился, Start не возвращается
+	select {
+	case <-stopped:
+		t.Fatal("Start returned before in-flight update was handled")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(cal.release)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not return after in-flight update was handled")
+	}
+	assert.NotEqual(t, "Не удалось получить свободные слоты. Попробуйте позже.", sent.last(chatID).Text)
+	assert.Error(t, bot.ctx.Err())
+}
+
+func TestTgBot_Start_CancelsAfterShutdownTimeout(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	bot.cfg.Telegram.ShutdownTimeoutSeconds = 0
+	cal := &blockingCalendar{started: make(chan struct{})}
+	bot.calendarSvc = cal
+	chatID := gofakeit.Int64()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan tgbot.Update, 1)
+	stopped := startBot(ctx, bot, updates)
+
+	updates <- newCallbackUpdate(chatID, signCallback(chatID, actionDate, "20251024"))
+	<-cal.started
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start did not cancel in-flight update after shutdown timeout")
+	}
+	assert.Equal(t, "Не удалось получить свободные слоты. Попробуйте позже.", sent.last(chatID).Text)
+}
+
+func TestTgBot_handleUpdate_CallbackWithoutMessage(t *testing.T) {
+	bot, _, _, sent := newScenarioBot(t, gofakeit.Int64())
+	chatID := gofakeit.Int64()
+
+	// У колбэка из inline-режима или со слишком старым сообщением нет Message: бот отвечает в личный чат
+	bot.handleUpdate(tgbot.Update{CallbackQuery: &tgbot.CallbackQuery{
+		ID:   gofakeit.UUID(),
+		From: &tgbot.User{ID: chatID},
+		Data: signCallback(chatID, actionMyBookings),
+	}})
+	assert.Equal(t, "У вас пока нет записей.", sent.last(chatID).Text)
+
+	// Колбэк без чата и пользователя просто пропускается
+	assert.NotPanics(t, func() {
+		bot.handleUpdate(tgbot.Update{CallbackQuery: &tgbot.CallbackQuery{ID: gofakeit.UUID()}})
+	})
+}
+
+// stuckCalendar имитирует обработчик, который не учитывает отмену контекста
+type stuckCalendar struct {
+	MockCalendarService
+	started chan struct{}
+	release chan struct{}
+}
+
+func (c *stuckCalendar) GetFreeSlots(context.Context, time.Time, time.Duration, string) ([]time.Time, error) {
+	close(c.started)
+	<-c.release
+	return nil, errors.New("released")
+}
+
+func TestTgBot_Start_ReturnsWhenHandlerIgnoresCancellation(t *testing.T) {
+	defer func(grace time.Duration) { shutdownGracePeriod = grace }(shutdownGracePeriod)
+	shutdownGracePeriod = 10 * time.Millisecond
+
+	bot, _, _, _ := newScenarioBot(t, gofakeit.Int64())
+	bot.cfg.Telegram.ShutdownTimeoutSeconds = 0
+	cal := &stuckCalendar{started: make(chan struct{}), release: make(chan struct{})}
+	defer close(cal.release)
+	bot.calendarSvc = cal
+	chatID := gofakeit.Int64()
+	assert.NoError(t, bot.states.Set(context.Background(), chatID, &session.UserState{State: StateAwaitingDate}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan tgbot.Update, 1)
+	stopped := startBot(ctx, bot, updates)
+
+	updates <- newCallbackUpdate(chatID, signCallback(chatID, actionDate, "20251024"))
+	<-cal.started
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Start kept waiting for a handler that ignores cancellation")
+	}
+}
+
+func TestTgBot_ForeignBookingCallbacks(t *testing.T) {
+	adminID := gofakeit.Int64()
+	bot, _, _, sent := newScenarioBot(t, adminID)
+	ownerID := gofakeit.Int64()
+	attackerID := ownerID + 1
+	start := time.Now().Add(24 * time.Hour)
+	visit := &booking.Booking{UserID: ownerID, Name: "Иван Петров", Contact: "+79161234567", Datetime: start, EndTime: start.Add(time.Hour)}
+	assert.NoError(t, bot.repo.CreateBooking(context.Background(), visit))
+
+	// Подделанный callback с чужим ID записи не меняет запись и не раскрывает ее существование
+	for _, action := range []string{actionCancel, actionConfirm, actionReschedule} {
+		bot.handleUpdate(newCallbackUpdate(attackerID, signCallback(attackerID, action, strconv.Itoa(visit.ID))))
+		assert.Equal(t, "Не удалось найти указанную запись.", sent.last(attackerID).Text, action)
+	}
+
+	got, err := bot.repo.GetBookingByID(context.Background(), visit.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, booking.StatusScheduled, got.Status)
+	assert.True(t, start.Equal(got.Datetime))
+	state, err := bot.states.Get(context.Background(), attackerID)
+	assert.NoError(t, err)
+	assert.True(t, state == nil || state.RescheduleID == 0)
+	assert.Empty(t, sent.last(adminID).Text)
+}
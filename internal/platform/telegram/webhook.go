@@ -0,0 +1,109 @@
+package telegram
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// Способы получения обновлений, выбираемые переменной UPDATES_MODE
+const (
+	UpdatesModePolling = "polling"
+	UpdatesModeWebhook = "webhook"
+)
+
+const (
+	// secretTokenHeader - заголовок, в котором Telegram передает секрет вебхука
+	secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	// maxWebhookBody ограничивает размер тела запроса с обновлением
+	maxWebhookBody = 1 << 20
+)
+
+// serveWebhook регистрирует вебхук в Telegram и принимает обновления по HTTP, пока не будет отменен ctx.
+// Обновления передаются в тот же диспетчер, что и при long polling
+func (b *TgBot) serveWebhook(ctx context.Context) error {
+	if b.cfg.Telegram.WebhookSecret == "" {
+		return errors.New("webhook secret is not set")
+	}
+	if err := b.setWebhook(); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", b.cfg.Telegram.WebhookListenAddr)
+	if err != nil {
+		return fmt.Errorf("unable to listen on %s: %v", b.cfg.Telegram.WebhookListenAddr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(b.cfg.Telegram.WebhookPath, b.webhookHandler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(listener)
+	}()
+	logrus.WithField("addr", listener.Addr().String()).Info("Listening for webhook updates")
+
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		return fmt.Errorf("webhook server failed: %v", err)
+	}
+
+	// Новые запросы больше не принимаются; уже принятые обновления дорабатывает диспетчер
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logrus.WithError(err).Error("Failed to shut down webhook server")
+	}
+	return nil
+}
+
+// setWebhook регистрирует WEBHOOK_URL вместе с секретом. WebhookConfig библиотеки не поддерживает
+// secret_token, поэтому запрос собирается вручную. Вызов идемпотентен, его можно выполнять из каждой реплики
+func (b *TgBot) setWebhook() error {
+	if b.cfg.Telegram.WebhookURL == "" {
+		return errors.New("webhook url is not set")
+	}
+	params := tgbot.Params{}
+	params.AddNonEmpty("url", b.cfg.Telegram.WebhookURL)
+	params.AddNonEmpty("secret_token", b.cfg.Telegram.WebhookSecret)
+	if _, err := b.api.MakeRequest("setWebhook", params); err != nil {
+		return fmt.Errorf("unable to set webhook: %v", err)
+	}
+	return nil
+}
+
+// webhookHandler принимает обновления от Telegram; запросы без верного секрета отклоняются
+func (b *TgBot) webhookHandler() http.Handler {
+	secret := []byte(b.cfg.Telegram.WebhookSecret)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(secretTokenHeader)), secret) != 1 {
+			logrus.WithField("remote_addr", r.RemoteAddr).Warn("Rejected webhook request with invalid secret token")
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		var update tgbot.Update
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebhookBody)).Decode(&update); err != nil {
+			logrus.WithError(err).Warn("Failed to decode webhook update")
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+
+		b.dispatcher.Dispatch(update)
+		w.WriteHeader(http.StatusOK)
+	})
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	tgbot "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTgBot_webhookHandler(t *testing.T) {
+	secret := gofakeit.Password(true, true, true, false, false, 32)
+	bot := &TgBot{cfg: newTestConfig()}
+	bot.cfg.Telegram.WebhookSecret = secret
+
+	var (
+		mu       sync.Mutex
+		received []tgbot.Update
+	)
+	bot.dispatcher = NewDispatcher(1, func(update tgbot.Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, update)
+	})
+	handler := bot.webhookHandler()
+
+	body := `{"update_id": 42, "message": {"message_id": 1, "chat": {"id": 100}, "text": "/start"}}`
+	tests := []struct {
+		name   string
+		method string
+		secret string
+		body   string
+		status int
+	}{
+		{name: "wrong method", method: http.MethodGet, secret: secret, status: http.StatusMethodNotAllowed},
+		{name: "missing secret", method: http.MethodPost, body: body, status: http.StatusForbidden},
+		{name: "wrong secret", method: http.MethodPost, secret: secret + "x", body: body, status: http.StatusForbidden},
+		{name: "invalid body", method: http.MethodPost, secret: secret, body: "{", status: http.StatusBadRequest},
+		{name: "valid update", method: http.MethodPost, secret: secret, body: body, status: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/telegram/webhook", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				req.Header.Set(secretTokenHeader, tt.secret)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+
+	// Обработано только обновление с верным секретом
+	bot.dispatcher.Wait()
+	assert.Len(t, received, 1)
+	assert.Equal(t, 42, received[0].UpdateID)
+	assert.Equal(t, int64(100), received[0].Message.Chat.ID)
+}
+
+func TestTgBot_setWebhook(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	bot := &TgBot{api: mockAPI, cfg: newTestConfig()}
+	bot.cfg.Telegram.WebhookURL = "https://bot.example.com/telegram/webhook"
+	bot.cfg.Telegram.WebhookSecret = "secret_token-1"
+
+	mockAPI.On("MakeRequest", "setWebhook", tgbot.Params{
+		"url":          "https://bot.example.com/telegram/webhook",
+		"secret_token": "secret_token-1",
+	}).Return(&tgbot.APIResponse{Ok: true}, nil).Once()
+	assert.NoError(t, bot.setWebhook())
+
+	mockAPI.On("MakeRequest", "setWebhook", tgbot.Params{
+		"url":          "https://bot.example.com/telegram/webhook",
+		"secret_token": "secret_token-1",
+	}).Return(&tgbot.APIResponse{}, errors.New("Bad Request: bad webhook")).Once()
+	assert.ErrorContains(t, bot.setWebhook(), "bad webhook")
+	mockAPI.AssertExpectations(t)
+
+	bot.cfg.Telegram.WebhookURL = ""
+	assert.Error(t, bot.setWebhook())
+}
+
+func TestTgBot_Start_Webhook(t *testing.T) {
+	bot, _, _, _ := newScenarioBot(t, gofakeit.Int64())
+	bot.cfg.Telegram.UpdatesMode = UpdatesModeWebhook
+
+	// Без секрета вебхук не запускается
+	assert.ErrorContains(t, bot.Start(context.Background()), "secret")
+
+	bot.cfg.Telegram.UpdatesMode = "push"
+	assert.ErrorContains(t, bot.Start(context.Background()), "unknown updates mode")
+}
+
+func TestTgBot_poll_DeletesWebhook(t *testing.T) {
+	mockAPI := new(MockBotAPI)
+	bot := &TgBot{api: mockAPI, cfg: newTestConfig()}
+
+	// После работы в режиме вебхука getUpdates отвечает 409, пока вебхук не удален
+	mockAPI.On("Request", tgbot.DeleteWebhookConfig{}).Return(&tgbot.APIResponse{Ok: true}, nil).Once()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.NoError(t, bot.poll(ctx))
+	mockAPI.AssertExpectations(t)
+}
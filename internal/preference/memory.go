@@ -0,0 +1,41 @@
+package preference
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryStore - хранилище настроек в памяти процесса (для тестов и локального запуска)
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[int64]Preferences
+}
+
+// NewMemoryStore создает хранилище настроек в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[int64]Preferences)}
+}
+
+// Get возвращает копию настроек пользователя или nil, если они еще не сохранялись
+func (s *MemoryStore) Get(_ context.Context, chatID int64) (*Preferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.entries[chatID]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+// SetLanguage сохраняет язык пользователя, не перезаписывая явно выбранный язык автоматически определенным
+func (s *MemoryStore) SetLanguage(_ context.Context, chatID int64, language string, chosen bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.entries[chatID]; ok && p.LanguageChosen && !chosen {
+		return nil
+	}
+	s.entries[chatID] = Preferences{ChatID: chatID, Language: language, LanguageChosen: chosen}
+	return nil
+}
@@ -0,0 +1,43 @@
+package preference
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_SetLanguage(t *testing.T) {
+	store := NewMemoryStore()
+	chatID := gofakeit.Int64()
+
+	got, err := store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	assert.NoError(t, store.SetLanguage(context.Background(), chatID, "en", false))
+	got, err = store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, &Preferences{ChatID: chatID, Language: "en"}, got)
+}
+
+func TestMemoryStore_SetLanguage_KeepsChosen(t *testing.T) {
+	store := NewMemoryStore()
+	chatID := gofakeit.Int64()
+
+	assert.NoError(t, store.SetLanguage(context.Background(), chatID, "kk", true))
+	// Автоматически определенный язык не перезаписывает выбранный пользователем
+	assert.NoError(t, store.SetLanguage(context.Background(), chatID, "en", false))
+
+	got, err := store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, "kk", got.Language)
+	assert.True(t, got.LanguageChosen)
+
+	// Явный выбор заменяет предыдущий
+	assert.NoError(t, store.SetLanguage(context.Background(), chatID, "ru", true))
+	got, err = store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, "ru", got.Language)
+}
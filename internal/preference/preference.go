@@ -0,0 +1,9 @@
+// Package preference хранит настройки пациента, не связанные с конкретной записью
+package preference
+
+// Preferences - настройки пользователя бота
+type Preferences struct {
+	ChatID         int64
+	Language       string // Код языка сообщений ("ru", "en", "kk")
+	LanguageChosen bool   // Язык выбран командой /language, а не определен по Telegram
+}
@@ -0,0 +1,50 @@
+package preference
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DBConnection interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Repo - хранилище настроек пользователей в PostgreSQL
+type Repo struct {
+	conn DBConnection
+}
+
+func NewRepo(conn DBConnection) *Repo {
+	return &Repo{conn: conn}
+}
+
+// Get возвращает настройки пользователя или nil, если они еще не сохранялись
+func (r *Repo) Get(ctx context.Context, chatID int64) (*Preferences, error) {
+	query := `SELECT chat_id, language, language_chosen FROM user_preferences WHERE chat_id = $1`
+	var p Preferences
+	err := r.conn.QueryRow(ctx, query, chatID).Scan(&p.ChatID, &p.Language, &p.LanguageChosen)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// SetLanguage сохраняет язык пользователя. Язык, определенный автоматически (chosen = false),
+// не перезаписывает язык, выбранный пользователем явно
+func (r *Repo) SetLanguage(ctx context.Context, chatID int64, language string, chosen bool) error {
+	query := `
+	INSERT INTO user_preferences (chat_id, language, language_chosen, updated_at)
+	VALUES ($1, $2, $3, now())
+	ON CONFLICT (chat_id) DO UPDATE
+	SET language = EXCLUDED.language, language_chosen = EXCLUDED.language_chosen, updated_at = EXCLUDED.updated_at
+	WHERE EXCLUDED.language_chosen OR NOT user_preferences.language_chosen`
+	_, err := r.conn.Exec(ctx, query, chatID, language, chosen)
+	return err
+}
@@ -0,0 +1,65 @@
+package preference
+
+import (
+	"context"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreferenceRepo_Get(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	chatID := gofakeit.Int64()
+
+	mock.ExpectQuery(`SELECT chat_id, language, language_chosen FROM user_preferences`).
+		WithArgs(chatID).
+		WillReturnRows(pgxmock.NewRows([]string{"chat_id", "language", "language_chosen"}).AddRow(chatID, "kk", true))
+
+	got, err := repo.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, &Preferences{ChatID: chatID, Language: "kk", LanguageChosen: true}, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPreferenceRepo_Get_NotFound(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	chatID := gofakeit.Int64()
+
+	mock.ExpectQuery(`SELECT chat_id, language, language_chosen FROM user_preferences`).
+		WithArgs(chatID).
+		WillReturnError(pgx.ErrNoRows)
+
+	got, err := repo.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPreferenceRepo_SetLanguage(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	chatID := gofakeit.Int64()
+
+	mock.ExpectExec(`INSERT INTO user_preferences .* WHERE EXCLUDED.language_chosen OR NOT user_preferences.language_chosen`).
+		WithArgs(chatID, "en", false).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	assert.NoError(t, repo.SetLanguage(context.Background(), chatID, "en", false))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
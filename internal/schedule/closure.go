@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+)
+
+// Источники закрытий
+const (
+	SourceManual             = "manual"
+	SourceProductionCalendar = "production_calendar"
+)
+
+// Closure - период, когда клиника (или отдельный врач) не принимает пациентов.
+// Даты хранятся без времени, DateTo включается в период
+type Closure struct {
+	ID       int       `db:"id"`
+	DoctorID *int      `db:"doctor_id"` // nil - закрыта вся клиника
+	DateFrom time.Time `db:"date_from"`
+	DateTo   time.Time `db:"date_to"`
+	Reason   string    `db:"reason"`
+	Source   string    `db:"source"`
+}
+
+// Covers сообщает, приходится ли календарный день date на период закрытия
+func (c *Closure) Covers(date time.Time) bool {
+	day := civilDate(date)
+	return !day.Before(civilDate(c.DateFrom)) && !day.After(civilDate(c.DateTo))
+}
+
+// AppliesTo сообщает, действует ли закрытие на врача; doctorID = 0 - врачи не заведены
+func (c *Closure) AppliesTo(doctorID int) bool {
+	return c.DoctorID == nil || *c.DoctorID == doctorID
+}
+
+// Period возвращает период закрытия для отображения, например "31.12.2025–08.01.2026";
+// date форматирует дату на языке получателя
+func (c *Closure) Period(date func(time.Time) string) string {
+	from := date(c.DateFrom)
+	if civilDate(c.DateFrom).Equal(civilDate(c.DateTo)) {
+		return from
+	}
+	return fmt.Sprintf("%s–%s", from, date(c.DateTo))
+}
+
+// civilDate отбрасывает время и часовой пояс, оставляя календарную дату
+func civilDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// dateParam форматирует календарную дату для параметра запроса с типом DATE
+func dateParam(t time.Time) string {
+	return t.Format("2006-01-02")
+}
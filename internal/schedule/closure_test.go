@@ -0,0 +1,37 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClosure_Covers(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+
+	c := Closure{
+		DateFrom: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC),
+		DateTo:   time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC),
+	}
+
+	// Поздний вечер по Москве - все еще тот же календарный день
+	assert.True(t, c.Covers(time.Date(2025, 12, 31, 23, 30, 0, 0, loc)))
+	assert.True(t, c.Covers(time.Date(2026, 1, 8, 10, 0, 0, 0, loc)))
+	assert.False(t, c.Covers(time.Date(2026, 1, 9, 0, 30, 0, 0, loc)))
+	assert.False(t, c.Covers(time.Date(2025, 12, 30, 12, 0, 0, 0, loc)))
+	assert.Equal(t, "31.12.2025–08.01.2026", c.Period(func(t time.Time) string { return t.Format("02.01.2006") }))
+}
+
+func TestClosure_AppliesTo(t *testing.T) {
+	clinic := Closure{}
+	assert.True(t, clinic.AppliesTo(0))
+	assert.True(t, clinic.AppliesTo(5))
+
+	doctorID := 5
+	vacation := Closure{DoctorID: &doctorID}
+	assert.True(t, vacation.AppliesTo(5))
+	assert.False(t, vacation.AppliesTo(3))
+	assert.False(t, vacation.AppliesTo(0))
+}
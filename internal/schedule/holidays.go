@@ -0,0 +1,69 @@
+package schedule
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Holiday - нерабочий праздничный день
+type Holiday struct {
+	Date  time.Time
+	Title string
+}
+
+// productionCalendar - формат производственного календаря xmlcalendar.ru
+type productionCalendar struct {
+	Year     int `xml:"year,attr"`
+	Holidays []struct {
+		ID    int    `xml:"id,attr"`
+		Title string `xml:"title,attr"`
+	} `xml:"holidays>holiday"`
+	Days []struct {
+		Date    string `xml:"d,attr"` // ММ.ДД
+		Type    int    `xml:"t,attr"` // 1 - выходной, 2 - сокращенный, 3 - рабочий выходной
+		Holiday int    `xml:"h,attr"`
+	} `xml:"days>day"`
+}
+
+// Тип дня производственного календаря, в который клиника не работает
+const dayTypeHoliday = 1
+
+// ParseProductionCalendar разбирает XML производственного календаря РФ (формат xmlcalendar.ru)
+// и возвращает нерабочие праздничные дни. Сокращенные дни и перенесенные рабочие
+// выходные не учитываются: часы работы в них определяет недельное расписание
+func ParseProductionCalendar(r io.Reader) ([]Holiday, error) {
+	var cal productionCalendar
+	if err := xml.NewDecoder(r).Decode(&cal); err != nil {
+		return nil, fmt.Errorf("unable to parse production calendar: %v", err)
+	}
+	if cal.Year == 0 {
+		return nil, fmt.Errorf("production calendar has no year")
+	}
+
+	titles := make(map[int]string)
+	for _, h := range cal.Holidays {
+		titles[h.ID] = h.Title
+	}
+
+	var holidays []Holiday
+	for _, day := range cal.Days {
+		if day.Type != dayTypeHoliday {
+			continue
+		}
+		date, err := time.Parse("01.02", day.Date)
+		if err != nil {
+			return nil, fmt.Errorf("invalid day %q in production calendar: %v", day.Date, err)
+		}
+		title := titles[day.Holiday]
+		if title == "" {
+			title = "Нерабочий день"
+		}
+		holidays = append(holidays, Holiday{
+			Date:  time.Date(cal.Year, date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+			Title: title,
+		})
+	}
+	return holidays, nil
+}
@@ -0,0 +1,49 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testProductionCalendar = `<?xml version="1.0" encoding="UTF-8"?>
+<calendar year="2025" lang="ru" date="2024.09.01" country="ru">
+	<holidays>
+		<holiday id="1" title="Новогодние каникулы"/>
+		<holiday id="3" title="День защитника Отечества"/>
+	</holidays>
+	<days>
+		<day d="01.01" t="1" h="1"/>
+		<day d="01.08" t="1" h="1"/>
+		<day d="02.22" t="2"/>
+		<day d="02.23" t="1" h="3"/>
+		<day d="11.01" t="3"/>
+		<day d="05.02" t="1"/>
+	</days>
+</calendar>`
+
+func TestParseProductionCalendar(t *testing.T) {
+	holidays, err := ParseProductionCalendar(strings.NewReader(testProductionCalendar))
+	assert.NoError(t, err)
+
+	// Сокращенные дни и рабочие субботы пропускаются
+	assert.Equal(t, []Holiday{
+		{Date: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Title: "Новогодние каникулы"},
+		{Date: time.Date(2025, 1, 8, 0, 0, 0, 0, time.UTC), Title: "Новогодние каникулы"},
+		{Date: time.Date(2025, 2, 23, 0, 0, 0, 0, time.UTC), Title: "День защитника Отечества"},
+		{Date: time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC), Title: "Нерабочий день"},
+	}, holidays)
+}
+
+func TestParseProductionCalendar_Errors(t *testing.T) {
+	_, err := ParseProductionCalendar(strings.NewReader("not xml"))
+	assert.Error(t, err)
+
+	_, err = ParseProductionCalendar(strings.NewReader(`<calendar><days><day d="01.01" t="1"/></days></calendar>`))
+	assert.Error(t, err)
+
+	_, err = ParseProductionCalendar(strings.NewReader(`<calendar year="2025"><days><day d="31.31" t="1"/></days></calendar>`))
+	assert.Error(t, err)
+}
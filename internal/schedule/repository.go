@@ -0,0 +1,139 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+)
+
+type DBConnection interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// Repo - хранилище закрытий клиники
+type Repo struct {
+	conn DBConnection
+}
+
+func NewRepo(conn DBConnection) *Repo {
+	return &Repo{conn: conn}
+}
+
+func (r *Repo) AddClosure(ctx context.Context, closure *Closure) error {
+	query := `
+	INSERT INTO clinic_closures (doctor_id, date_from, date_to, reason, source)
+	VALUES ($1, $2::date, $3::date, $4, $5)
+	RETURNING id`
+	return r.conn.QueryRow(ctx, query,
+		closure.DoctorID, dateParam(closure.DateFrom), dateParam(closure.DateTo), closure.Reason, closure.Source).
+		Scan(&closure.ID)
+}
+
+// DeleteClosure удаляет закрытие; возвращает pgx.ErrNoRows, если его нет
+func (r *Repo) DeleteClosure(ctx context.Context, id int) error {
+	tag, err := r.conn.Exec(ctx, `DELETE FROM clinic_closures WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// GetClosures возвращает закрытия, пересекающиеся с периодом [from, to] (календарные даты)
+func (r *Repo) GetClosures(ctx context.Context, from, to time.Time) ([]Closure, error) {
+	var closures []Closure
+	query := `
+	SELECT id, doctor_id, date_from, date_to, reason, source FROM clinic_closures
+	WHERE date_to >= $1::date AND date_from <= $2::date
+	ORDER BY date_from, id`
+	rows, err := r.conn.Query(ctx, query, dateParam(from), dateParam(to))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c Closure
+		if err := rows.Scan(&c.ID, &c.DoctorID, &c.DateFrom, &c.DateTo, &c.Reason, &c.Source); err != nil {
+			logrus.WithError(err).Error("Failed to scan row in GetClosures")
+			continue
+		}
+		closures = append(closures, c)
+	}
+
+	return closures, rows.Err()
+}
+
+// IsClosed сообщает, закрыта ли запись к врачу в календарный день date;
+// doctorID = 0 учитывает только закрытия всей клиники
+func (r *Repo) IsClosed(ctx context.Context, doctorID int, date time.Time) (bool, error) {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM clinic_closures
+		WHERE (doctor_id IS NULL OR doctor_id = $1) AND $2::date BETWEEN date_from AND date_to
+	)`
+	var closed bool
+	err := r.conn.QueryRow(ctx, query, doctorID, dateParam(date)).Scan(&closed)
+	return closed, err
+}
+
+// ImportHolidays заменяет ранее импортированные праздники за те же годы новыми.
+// Замена выполняется в одной транзакции, поэтому при ошибке остаются прежние праздники.
+// Закрытия, добавленные администратором вручную, не затрагиваются
+func (r *Repo) ImportHolidays(ctx context.Context, holidays []Holiday) error {
+	years := make(map[int]bool)
+	for _, h := range holidays {
+		years[h.Date.Year()] = true
+	}
+
+	tx, err := r.conn.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback(ctx) // После Commit ничего не делает
+
+	for year := range years {
+		query := `DELETE FROM clinic_closures WHERE source = $1 AND EXTRACT(YEAR FROM date_from) = $2`
+		if _, err := tx.Exec(ctx, query, SourceProductionCalendar, year); err != nil {
+			return fmt.Errorf("failed to delete holidays of %d: %v", year, err)
+		}
+	}
+
+	txRepo := NewRepo(tx)
+	for _, h := range holidays {
+		closure := &Closure{DateFrom: h.Date, DateTo: h.Date, Reason: h.Title, Source: SourceProductionCalendar}
+		if err := txRepo.AddClosure(ctx, closure); err != nil {
+			return fmt.Errorf("failed to import holiday %s: %v", dateParam(h.Date), err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// ImportProductionCalendarFile загружает нерабочие дни из XML-файла производственного календаря
+// и возвращает количество импортированных дней
+func (r *Repo) ImportProductionCalendarFile(ctx context.Context, path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("unable to open production calendar: %v", err)
+	}
+	defer f.Close()
+
+	holidays, err := ParseProductionCalendar(f)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.ImportHolidays(ctx, holidays); err != nil {
+		return 0, err
+	}
+	return len(holidays), nil
+}
@@ -0,0 +1,168 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var closureColumns = []string{"id", "doctor_id", "date_from", "date_to", "reason", "source"}
+
+func TestScheduleRepo_AddClosure(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	loc, err := time.LoadLocation("Europe/Moscow")
+	assert.NoError(t, err)
+
+	closure := &Closure{
+		DateFrom: time.Date(2025, 12, 31, 0, 0, 0, 0, loc),
+		DateTo:   time.Date(2026, 1, 8, 0, 0, 0, 0, loc),
+		Reason:   "Новогодние каникулы",
+		Source:   SourceManual,
+	}
+
+	// Даты передаются без времени и часового пояса
+	mock.ExpectQuery(`INSERT INTO clinic_closures`).
+		WithArgs(closure.DoctorID, "2025-12-31", "2026-01-08", closure.Reason, SourceManual).
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(7))
+
+	err = repo.AddClosure(context.Background(), closure)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, closure.ID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleRepo_DeleteClosure(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+
+	mock.ExpectExec(`DELETE FROM clinic_closures WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectExec(`DELETE FROM clinic_closures WHERE id = \$1`).
+		WithArgs(4).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	assert.NoError(t, repo.DeleteClosure(context.Background(), 3))
+	assert.ErrorIs(t, repo.DeleteClosure(context.Background(), 4), pgx.ErrNoRows)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleRepo_GetClosures(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	from := time.Date(2025, 10, 24, 15, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 30)
+	doctorID := 2
+
+	rows := pgxmock.NewRows(closureColumns).
+		AddRow(1, nil, from, from, gofakeit.Sentence(), SourceManual).
+		AddRow(2, &doctorID, from, to, "Отпуск", SourceManual)
+	mock.ExpectQuery(`SELECT id, doctor_id, date_from, date_to, reason, source FROM clinic_closures`).
+		WithArgs("2025-10-24", "2025-11-23").
+		WillReturnRows(rows)
+
+	closures, err := repo.GetClosures(context.Background(), from, to)
+	assert.NoError(t, err)
+	assert.Len(t, closures, 2)
+	assert.Equal(t, 2, *closures[1].DoctorID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleRepo_IsClosed(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	date := time.Date(2025, 12, 31, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`SELECT EXISTS`).
+		WithArgs(2, "2025-12-31").
+		WillReturnRows(pgxmock.NewRows([]string{"exists"}).AddRow(true))
+
+	closed, err := repo.IsClosed(context.Background(), 2, date)
+	assert.NoError(t, err)
+	assert.True(t, closed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleRepo_ImportProductionCalendarFile(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock)
+	path := filepath.Join(t.TempDir(), "calendar.xml")
+	assert.NoError(t, os.WriteFile(path, []byte(testProductionCalendar), 0o600))
+
+	// Сначала удаляются ранее импортированные праздники за год, затем добавляются новые
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM clinic_closures WHERE source = \$1`).
+		WithArgs(SourceProductionCalendar, 2025).
+		WillReturnResult(pgxmock.NewResult("DELETE", 14))
+	for i, date := range []string{"2025-01-01", "2025-01-08", "2025-02-23", "2025-05-02"} {
+		mock.ExpectQuery(`INSERT INTO clinic_closures`).
+			WithArgs((*int)(nil), date, date, pgxmock.AnyArg(), SourceProductionCalendar).
+			WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow(i + 1))
+	}
+	mock.ExpectCommit()
+
+	imported, err := repo.ImportProductionCalendarFile(context.Background(), path)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, imported)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestScheduleRepo_ImportProductionCalendarFile_Missing(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	_, err = NewRepo(mock).ImportProductionCalendarFile(context.Background(), filepath.Join(t.TempDir(), "missing.xml"))
+	assert.Error(t, err)
+}
+
+func TestScheduleRepo_ImportHolidays_Rollback(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	date := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectExec(`DELETE FROM clinic_closures WHERE source = \$1`).
+		WithArgs(SourceProductionCalendar, 2025).
+		WillReturnResult(pgxmock.NewResult("DELETE", 14))
+	mock.ExpectQuery(`INSERT INTO clinic_closures`).
+		WithArgs((*int)(nil), "2025-01-01", "2025-01-01", "Новый год", SourceProductionCalendar).
+		WillReturnError(errors.New("connection reset"))
+	// Ранее импортированные праздники не должны пропасть
+	mock.ExpectRollback()
+
+	err = NewRepo(mock).ImportHolidays(context.Background(), []Holiday{{Date: date, Title: "Новый год"}})
+	assert.ErrorContains(t, err, "connection reset")
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
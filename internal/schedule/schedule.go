@@ -0,0 +1,192 @@
+package schedule
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Interval - рабочий интервал дня, время отсчитывается от полуночи
+type Interval struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Week - недельное расписание работы; индекс - день недели (time.Sunday = 0).
+// День без интервалов считается выходным
+type Week [7][]Interval
+
+var weekdays = map[string]time.Weekday{
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+	"sun": time.Sunday,
+}
+
+var weekdayNames = [7]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Default возвращает расписание с понедельника по субботу с одинаковыми часами работы
+func Default(startHour, endHour int) Week {
+	var w Week
+	interval := Interval{Start: time.Duration(startHour) * time.Hour, End: time.Duration(endHour) * time.Hour}
+	for day := time.Monday; day <= time.Saturday; day++ {
+		w[day] = []Interval{interval}
+	}
+	return w
+}
+
+// Parse разбирает расписание вида "mon-fri 09:00-13:00,14:00-18:00; sat 10:00-15:00; sun closed".
+// Правила разделяются точкой с запятой; дни можно задавать диапазоном или через запятую.
+// Дни, не упомянутые в расписании, считаются выходными; более позднее правило для дня заменяет предыдущее
+func Parse(s string) (Week, error) {
+	var w Week
+	for _, rule := range strings.Split(s, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		fields := strings.Fields(rule)
+		if len(fields) != 2 {
+			return Week{}, fmt.Errorf("invalid schedule rule %q: expected \"<days> <hours>\"", rule)
+		}
+
+		days, err := parseDays(strings.ToLower(fields[0]))
+		if err != nil {
+			return Week{}, fmt.Errorf("invalid schedule rule %q: %v", rule, err)
+		}
+
+		var intervals []Interval
+		if strings.ToLower(fields[1]) != "closed" {
+			intervals, err = parseIntervals(fields[1])
+			if err != nil {
+				return Week{}, fmt.Errorf("invalid schedule rule %q: %v", rule, err)
+			}
+		}
+		for _, day := range days {
+			w[day] = intervals
+		}
+	}
+	return w, nil
+}
+
+// IsOpen сообщает, работает ли клиника в день недели date
+func (w Week) IsOpen(date time.Time) bool {
+	return len(w[date.Weekday()]) > 0
+}
+
+// Slots возвращает времена начала приема длительностью duration с шагом step.
+// day - полночь нужного дня в часовом поясе клиники; прием целиком укладывается в рабочий интервал
+func (w Week) Slots(day time.Time, duration, step time.Duration) []time.Time {
+	var slots []time.Time
+	for _, interval := range w[day.Weekday()] {
+		end := day.Add(interval.End)
+		for slot := day.Add(interval.Start); !slot.Add(duration).After(end); slot = slot.Add(step) {
+			slots = append(slots, slot)
+		}
+	}
+	return slots
+}
+
+// String возвращает расписание в том же формате, который принимает Parse;
+// подряд идущие дни с одинаковыми часами объединяются в диапазон
+func (w Week) String() string {
+	var rules []string
+	// Неделя начинается с понедельника
+	order := []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday}
+	for i := 0; i < len(order); {
+		day := order[i]
+		j := i + 1
+		for j < len(order) && slices.Equal(w[order[j]], w[day]) {
+			j++
+		}
+		if len(w[day]) > 0 {
+			days := weekdayNames[day]
+			if j-1 > i {
+				days += "-" + weekdayNames[order[j-1]]
+			}
+			var hours []string
+			for _, interval := range w[day] {
+				hours = append(hours, formatOffset(interval.Start)+"-"+formatOffset(interval.End))
+			}
+			rules = append(rules, days+" "+strings.Join(hours, ","))
+		}
+		i = j
+	}
+	if len(rules) == 0 {
+		return "closed"
+	}
+	return strings.Join(rules, "; ")
+}
+
+func parseDays(s string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, part := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(part, "-")
+		start, ok := weekdays[from]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", from)
+		}
+		if !isRange {
+			days = append(days, start)
+			continue
+		}
+		end, ok := weekdays[to]
+		if !ok {
+			return nil, fmt.Errorf("unknown day %q", to)
+		}
+		// Диапазон может переходить через воскресенье, например sat-mon
+		for day := start; ; day = (day + 1) % 7 {
+			days = append(days, day)
+			if day == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseIntervals(s string) ([]Interval, error) {
+	var intervals []Interval
+	for _, part := range strings.Split(s, ",") {
+		from, to, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid interval %q", part)
+		}
+		start, err := parseOffset(from)
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseOffset(to)
+		if err != nil {
+			return nil, err
+		}
+		if start >= end {
+			return nil, fmt.Errorf("interval %q ends before it starts", part)
+		}
+		if len(intervals) > 0 && start < intervals[len(intervals)-1].End {
+			return nil, fmt.Errorf("interval %q overlaps the previous one", part)
+		}
+		intervals = append(intervals, Interval{Start: start, End: end})
+	}
+	return intervals, nil
+}
+
+// parseOffset разбирает время "HH:MM"; допускается "24:00" как конец дня
+func parseOffset(s string) (time.Duration, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hours, &minutes); err != nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	if hours < 0 || minutes < 0 || minutes > 59 || hours > 24 || (hours == 24 && minutes != 0) {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func formatOffset(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,71 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	w, err := Parse("mon-fri 09:00-13:00,14:00-18:00; sat 10:00-15:00; sun closed")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []Interval{
+		{Start: 9 * time.Hour, End: 13 * time.Hour},
+		{Start: 14 * time.Hour, End: 18 * time.Hour},
+	}, w[time.Wednesday])
+	assert.Equal(t, []Interval{{Start: 10 * time.Hour, End: 15 * time.Hour}}, w[time.Saturday])
+	assert.Empty(t, w[time.Sunday])
+	assert.Equal(t, "mon-fri 09:00-13:00,14:00-18:00; sat 10:00-15:00", w.String())
+}
+
+func TestParse_OverrideAndList(t *testing.T) {
+	w, err := Parse("MON-SAT 09:00-18:00; sat 10:00-14:30; tue,thu closed")
+	assert.NoError(t, err)
+
+	assert.Len(t, w[time.Monday], 1)
+	assert.Empty(t, w[time.Tuesday])
+	assert.Empty(t, w[time.Thursday])
+	assert.Equal(t, []Interval{{Start: 10 * time.Hour, End: 14*time.Hour + 30*time.Minute}}, w[time.Saturday])
+	assert.Equal(t, "mon 09:00-18:00; wed 09:00-18:00; fri 09:00-18:00; sat 10:00-14:30", w.String())
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, s := range []string{
+		"mon",
+		"funday 09:00-18:00",
+		"mon 18:00-09:00",
+		"mon 09:00-13:00,12:00-18:00",
+		"mon 9-18",
+		"mon 09:00-25:00",
+	} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestWeek_Slots(t *testing.T) {
+	w, err := Parse("fri 09:00-11:00,12:00-13:00")
+	assert.NoError(t, err)
+
+	day := time.Date(2025, 10, 24, 0, 0, 0, 0, time.UTC) // пятница
+	slots := w.Slots(day, time.Hour, 30*time.Minute)
+
+	// Прием не пересекает обеденный перерыв
+	assert.Equal(t, []time.Time{
+		time.Date(2025, 10, 24, 9, 0, 0, 0, time.UTC),
+		time.Date(2025, 10, 24, 9, 30, 0, 0, time.UTC),
+		time.Date(2025, 10, 24, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 10, 24, 12, 0, 0, 0, time.UTC),
+	}, slots)
+
+	assert.Empty(t, w.Slots(day.AddDate(0, 0, 1), time.Hour, 30*time.Minute))
+}
+
+func TestDefault(t *testing.T) {
+	w := Default(9, 18)
+	assert.True(t, w.IsOpen(time.Date(2025, 10, 25, 0, 0, 0, 0, time.UTC)))  // суббота
+	assert.False(t, w.IsOpen(time.Date(2025, 10, 26, 0, 0, 0, 0, time.UTC))) // воскресенье
+	assert.Equal(t, "mon-sat 09:00-18:00", w.String())
+}
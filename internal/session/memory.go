@@ -0,0 +1,77 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	state     UserState
+	updatedAt time.Time
+}
+
+// MemoryStore - хранилище состояний в памяти процесса (для тестов и локального запуска)
+type MemoryStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[int64]memoryEntry
+	now     func() time.Time
+}
+
+// NewMemoryStore создает хранилище состояний в памяти
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		ttl:     ttl,
+		entries: make(map[int64]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// Get возвращает копию состояния пользователя или nil, если состояния нет или оно устарело
+func (s *MemoryStore) Get(_ context.Context, chatID int64) (*UserState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[chatID]
+	if !ok || s.expired(entry) {
+		return nil, nil
+	}
+	state := entry.state
+	return &state, nil
+}
+
+// Set сохраняет копию состояния пользователя
+func (s *MemoryStore) Set(_ context.Context, chatID int64, state *UserState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[chatID] = memoryEntry{state: *state, updatedAt: s.now()}
+	return nil
+}
+
+// Delete удаляет состояние пользователя
+func (s *MemoryStore) Delete(_ context.Context, chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, chatID)
+	return nil
+}
+
+// DeleteExpired удаляет все устаревшие состояния
+func (s *MemoryStore) DeleteExpired(_ context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for chatID, entry := range s.entries {
+		if s.expired(entry) {
+			delete(s.entries, chatID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) expired(entry memoryEntry) bool {
+	return s.ttl > 0 && s.now().Sub(entry.updatedAt) > s.ttl
+}
@@ -0,0 +1,64 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_SetGet(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	chatID := gofakeit.Int64()
+	state := &UserState{State: "awaiting_name", TempTime: gofakeit.Date(), TempName: gofakeit.Name()}
+
+	assert.NoError(t, store.Set(context.Background(), chatID, state))
+
+	got, err := store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, state, got)
+
+	// Изменение полученной копии не должно влиять на хранилище
+	got.State = "changed"
+	again, err := store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, "awaiting_name", again.State)
+}
+
+func TestMemoryStore_Delete(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	chatID := gofakeit.Int64()
+
+	assert.NoError(t, store.Set(context.Background(), chatID, &UserState{State: "awaiting_date"}))
+	assert.NoError(t, store.Delete(context.Background(), chatID))
+
+	got, err := store.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestMemoryStore_TTL(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	staleID := gofakeit.Int64()
+	freshID := staleID + 1
+	assert.NoError(t, store.Set(context.Background(), staleID, &UserState{State: "awaiting_name"}))
+
+	now = now.Add(2 * time.Hour)
+	assert.NoError(t, store.Set(context.Background(), freshID, &UserState{State: "awaiting_name"}))
+
+	got, err := store.Get(context.Background(), staleID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	assert.NoError(t, store.DeleteExpired(context.Background()))
+	assert.Len(t, store.entries, 1)
+
+	got, err = store.Get(context.Background(), freshID)
+	assert.NoError(t, err)
+	assert.NotNil(t, got)
+}
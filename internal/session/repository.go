@@ -0,0 +1,77 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type DBConnection interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// Repo - хранилище состояний диалога в PostgreSQL
+type Repo struct {
+	conn DBConnection
+	ttl  time.Duration
+}
+
+// NewRepo создает хранилище состояний; состояния старше ttl считаются устаревшими.
+// ttl <= 0 - состояния не устаревают, как в MemoryStore
+func NewRepo(conn DBConnection, ttl time.Duration) *Repo {
+	return &Repo{conn: conn, ttl: ttl}
+}
+
+func (r *Repo) Get(ctx context.Context, chatID int64) (*UserState, error) {
+	query := `
+	SELECT data FROM user_sessions
+	WHERE chat_id = $1 AND ($2 <= 0 OR updated_at > now() - $2 * interval '1 second')`
+	var data []byte
+	err := r.conn.QueryRow(ctx, query, chatID, int64(r.ttl.Seconds())).Scan(&data)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state UserState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("unable to decode user state: %v", err)
+	}
+	return &state, nil
+}
+
+func (r *Repo) Set(ctx context.Context, chatID int64, state *UserState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("unable to encode user state: %v", err)
+	}
+	query := `
+	INSERT INTO user_sessions (chat_id, data, updated_at)
+	VALUES ($1, $2, now())
+	ON CONFLICT (chat_id) DO UPDATE SET data = EXCLUDED.data, updated_at = EXCLUDED.updated_at`
+	_, err = r.conn.Exec(ctx, query, chatID, data)
+	return err
+}
+
+func (r *Repo) Delete(ctx context.Context, chatID int64) error {
+	query := `DELETE FROM user_sessions WHERE chat_id = $1`
+	_, err := r.conn.Exec(ctx, query, chatID)
+	return err
+}
+
+func (r *Repo) DeleteExpired(ctx context.Context) error {
+	if r.ttl <= 0 {
+		return nil
+	}
+	query := `DELETE FROM user_sessions WHERE updated_at <= now() - $1 * interval '1 second'`
+	_, err := r.conn.Exec(ctx, query, int64(r.ttl.Seconds()))
+	return err
+}
@@ -0,0 +1,127 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgx/v5"
+	"github.com/pashagolub/pgxmock/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionRepo_Get(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock, time.Hour)
+	chatID := gofakeit.Int64()
+	state := UserState{State: "awaiting_contact", TempTime: gofakeit.Date().UTC(), TempName: gofakeit.Name()}
+	data, err := json.Marshal(state)
+	assert.NoError(t, err)
+
+	mock.ExpectQuery(`SELECT data FROM user_sessions`).
+		WithArgs(chatID, int64(3600)).
+		WillReturnRows(pgxmock.NewRows([]string{"data"}).AddRow(data))
+
+	got, err := repo.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, &state, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepo_Get_NotFound(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock, time.Hour)
+	chatID := gofakeit.Int64()
+
+	mock.ExpectQuery(`SELECT data FROM user_sessions`).
+		WithArgs(chatID, int64(3600)).
+		WillReturnError(pgx.ErrNoRows)
+
+	got, err := repo.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepo_Set(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock, time.Hour)
+	chatID := gofakeit.Int64()
+	state := &UserState{State: "awaiting_name", TempTime: gofakeit.Date()}
+	data, err := json.Marshal(state)
+	assert.NoError(t, err)
+
+	mock.ExpectExec(`INSERT INTO user_sessions`).
+		WithArgs(chatID, data).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	assert.NoError(t, repo.Set(context.Background(), chatID, state))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepo_Delete(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock, time.Hour)
+	chatID := gofakeit.Int64()
+
+	mock.ExpectExec(`DELETE FROM user_sessions WHERE chat_id = \$1`).
+		WithArgs(chatID).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	assert.NoError(t, repo.Delete(context.Background(), chatID))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepo_DeleteExpired_Error(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock, time.Hour)
+
+	mock.ExpectExec(`DELETE FROM user_sessions WHERE updated_at`).
+		WithArgs(int64(3600)).
+		WillReturnError(assert.AnError)
+
+	assert.Error(t, repo.DeleteExpired(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepo_NoTTL(t *testing.T) {
+	mock, err := pgxmock.NewConn()
+	assert.NoError(t, err)
+	defer mock.Close(context.Background())
+
+	repo := NewRepo(mock, 0)
+
+	// Без TTL состояния не устаревают: Get не ограничивает возраст, а DeleteExpired ничего не удаляет
+	chatID := gofakeit.Int64()
+	state := UserState{State: "awaiting_date"}
+	data, err := json.Marshal(state)
+	assert.NoError(t, err)
+	mock.ExpectQuery(`SELECT data FROM user_sessions`).
+		WithArgs(chatID, int64(0)).
+		WillReturnRows(pgxmock.NewRows([]string{"data"}).AddRow(data))
+
+	got, err := repo.Get(context.Background(), chatID)
+	assert.NoError(t, err)
+	assert.Equal(t, &state, got)
+	assert.NoError(t, repo.DeleteExpired(context.Background()))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
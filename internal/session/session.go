@@ -0,0 +1,18 @@
+package session
+
+import (
+	"time"
+)
+
+// UserState - состояние диалога пользователя с ботом
+type UserState struct {
+	State        string    `json:"state"`
+	TempTime     time.Time `json:"temp_time"`     // Для хранения выбранной даты/времени
+	TempName     string    `json:"temp_name"`     // Для хранения имени
+	TempEvent    string    `json:"temp_event"`    // Для хранения ID события календаря
+	DoctorID     int       `json:"doctor_id"`     // Выбранный врач (0 - врачи не заведены)
+	ServiceID    int       `json:"service_id"`    // Выбранная услуга (0 - каталог услуг не заполнен)
+	RescheduleID int       `json:"reschedule_id"` // Переносимая запись (0 - оформляется новая запись)
+	ProfileField string    `json:"profile_field"` // Редактируемое поле профиля пациента
+	PatientID    int       `json:"patient_id"`    // Записываемый член семьи (0 - пациент записывается сам)
+}
@@ -0,0 +1,8 @@
+CREATE TABLE
+    IF NOT EXISTS user_sessions (
+        chat_id BIGINT PRIMARY KEY,
+        data JSONB NOT NULL,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
+
+CREATE INDEX IF NOT EXISTS user_sessions_updated_at_idx ON user_sessions (updated_at);
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS slot_holds;
+
+DROP INDEX IF EXISTS bookings_datetime_key;
+
+DROP TABLE IF EXISTS booking_conflicts;
@@ -0,0 +1,37 @@
+-- Двойные записи, сделанные до появления ограничения, переносятся в архив: на слоте остается
+-- самая ранняя запись, остальные администратор разбирает вручную
+CREATE TABLE
+    IF NOT EXISTS booking_conflicts (
+        -- ID записи в bookings на момент переноса
+        booking_id INT PRIMARY KEY,
+        user_id BIGINT NOT NULL,
+        name VARCHAR(255),
+        contact VARCHAR(255),
+        datetime TIMESTAMPTZ,
+        event_id VARCHAR(255),
+        -- ID записи, которая осталась на этом времени
+        kept_booking_id INT,
+        archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
+
+WITH duplicates AS (
+    DELETE FROM bookings
+    USING (SELECT datetime, MIN(id) AS kept_id FROM bookings GROUP BY datetime) AS kept
+    WHERE bookings.datetime = kept.datetime
+        AND bookings.id <> kept.kept_id
+    RETURNING bookings.*, kept.kept_id
+)
+INSERT INTO booking_conflicts (booking_id, user_id, name, contact, datetime, event_id, kept_booking_id)
+SELECT id, user_id, name, contact, datetime, event_id, kept_id
+FROM duplicates;
+
+CREATE UNIQUE INDEX IF NOT EXISTS bookings_datetime_key ON bookings (datetime);
+
+CREATE TABLE
+    IF NOT EXISTS slot_holds (
+        slot_start TIMESTAMPTZ PRIMARY KEY,
+        user_id BIGINT NOT NULL,
+        expires_at TIMESTAMPTZ NOT NULL
+    );
+
+CREATE INDEX IF NOT EXISTS slot_holds_user_id_idx ON slot_holds (user_id);
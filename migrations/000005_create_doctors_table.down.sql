@@ -0,0 +1,13 @@
+ALTER TABLE slot_holds DROP CONSTRAINT slot_holds_pkey;
+
+ALTER TABLE slot_holds DROP COLUMN doctor_id;
+
+ALTER TABLE slot_holds ADD PRIMARY KEY (slot_start);
+
+DROP INDEX IF EXISTS bookings_doctor_datetime_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS bookings_datetime_key ON bookings (datetime);
+
+ALTER TABLE bookings DROP COLUMN doctor_id;
+
+DROP TABLE IF EXISTS doctors;
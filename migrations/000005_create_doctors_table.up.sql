@@ -0,0 +1,23 @@
+CREATE TABLE
+    IF NOT EXISTS doctors (
+        id SERIAL PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        specialty VARCHAR(255) NOT NULL DEFAULT '',
+        calendar_id VARCHAR(255) NOT NULL,
+        work_start_hour INT NOT NULL DEFAULT 9,
+        work_end_hour INT NOT NULL DEFAULT 18,
+        active BOOLEAN NOT NULL DEFAULT TRUE
+    );
+
+ALTER TABLE bookings ADD COLUMN doctor_id INT REFERENCES doctors (id);
+
+-- Слот уникален в рамках врача; записи без врача (однокабинетный режим) считаются врачом 0
+DROP INDEX IF EXISTS bookings_datetime_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS bookings_doctor_datetime_key ON bookings (COALESCE(doctor_id, 0), datetime);
+
+ALTER TABLE slot_holds ADD COLUMN doctor_id INT NOT NULL DEFAULT 0;
+
+ALTER TABLE slot_holds DROP CONSTRAINT slot_holds_pkey;
+
+ALTER TABLE slot_holds ADD PRIMARY KEY (doctor_id, slot_start);
@@ -0,0 +1,21 @@
+DELETE FROM slot_holds;
+
+ALTER TABLE slot_holds DROP CONSTRAINT slot_holds_no_overlap;
+
+ALTER TABLE slot_holds DROP COLUMN slot_end;
+
+ALTER TABLE slot_holds DROP COLUMN id;
+
+ALTER TABLE slot_holds ADD PRIMARY KEY (doctor_id, slot_start);
+
+ALTER TABLE bookings DROP CONSTRAINT bookings_no_overlap;
+
+CREATE UNIQUE INDEX IF NOT EXISTS bookings_doctor_datetime_key ON bookings (COALESCE(doctor_id, 0), datetime);
+
+ALTER TABLE bookings DROP COLUMN end_time;
+
+ALTER TABLE bookings DROP COLUMN service_id;
+
+DROP TABLE IF EXISTS services;
+
+ALTER TABLE booking_conflicts DROP COLUMN IF EXISTS doctor_id;
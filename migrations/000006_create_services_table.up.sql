@@ -0,0 +1,75 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+CREATE TABLE
+    IF NOT EXISTS services (
+        id SERIAL PRIMARY KEY,
+        name VARCHAR(255) NOT NULL,
+        duration_minutes INT NOT NULL CHECK (duration_minutes > 0),
+        price INT NOT NULL DEFAULT 0,
+        description TEXT NOT NULL DEFAULT '',
+        active BOOLEAN NOT NULL DEFAULT TRUE
+    );
+
+ALTER TABLE bookings ADD COLUMN service_id INT REFERENCES services (id);
+
+-- До появления каталога все приемы длились один час
+ALTER TABLE bookings ADD COLUMN end_time TIMESTAMPTZ;
+
+ALTER TABLE booking_conflicts ADD COLUMN IF NOT EXISTS doctor_id INT;
+
+-- Записи без времени приема не занимают слот и не могут получить end_time: переносим их в архив
+WITH moved AS (
+    DELETE FROM bookings WHERE datetime IS NULL RETURNING *
+)
+INSERT INTO booking_conflicts (booking_id, user_id, name, contact, datetime, event_id, doctor_id)
+SELECT id, user_id, name, contact, datetime, event_id, doctor_id
+FROM moved;
+
+UPDATE bookings SET end_time = datetime + interval '1 hour' WHERE end_time IS NULL;
+
+ALTER TABLE bookings ALTER COLUMN end_time SET NOT NULL;
+
+-- Приемы одного врача не могут пересекаться по времени. Пересечения, накопившиеся до ограничения,
+-- переносятся в архив booking_conflicts: из каждой группы остается самая ранняя запись
+DO $$
+DECLARE
+    b RECORD;
+    kept INT;
+BEGIN
+    FOR b IN SELECT id, doctor_id, datetime, end_time FROM bookings ORDER BY id LOOP
+        SELECT MIN(id) INTO kept
+        FROM bookings
+        WHERE id < b.id
+            AND COALESCE(doctor_id, 0) = COALESCE(b.doctor_id, 0)
+            AND tstzrange(datetime, end_time) && tstzrange(b.datetime, b.end_time);
+        IF kept IS NOT NULL THEN
+            WITH moved AS (
+                DELETE FROM bookings WHERE id = b.id RETURNING *
+            )
+            INSERT INTO booking_conflicts (booking_id, user_id, name, contact, datetime, event_id, doctor_id, kept_booking_id)
+            SELECT id, user_id, name, contact, datetime, event_id, doctor_id, kept
+            FROM moved;
+        END IF;
+    END LOOP;
+END $$;
+
+DROP INDEX IF EXISTS bookings_doctor_datetime_key;
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+    (COALESCE(doctor_id, 0)) WITH =,
+    tstzrange(datetime, end_time) WITH &&
+);
+
+-- Удержания временные, при смене схемы их можно просто сбросить
+DELETE FROM slot_holds;
+
+ALTER TABLE slot_holds DROP CONSTRAINT slot_holds_pkey;
+
+ALTER TABLE slot_holds ADD COLUMN id SERIAL PRIMARY KEY;
+
+ALTER TABLE slot_holds ADD COLUMN slot_end TIMESTAMPTZ NOT NULL;
+
+ALTER TABLE slot_holds ADD CONSTRAINT slot_holds_no_overlap EXCLUDE USING gist (
+    doctor_id WITH =,
+    tstzrange(slot_start, slot_end) WITH &&
+);
@@ -0,0 +1,5 @@
+ALTER TABLE doctors ADD COLUMN work_start_hour INT NOT NULL DEFAULT 9;
+
+ALTER TABLE doctors ADD COLUMN work_end_hour INT NOT NULL DEFAULT 18;
+
+ALTER TABLE doctors DROP COLUMN schedule;
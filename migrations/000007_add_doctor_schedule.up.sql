@@ -0,0 +1,9 @@
+-- Расписание врача в формате WORK_SCHEDULE; пустая строка - расписание клиники
+ALTER TABLE doctors ADD COLUMN schedule TEXT NOT NULL DEFAULT '';
+
+UPDATE doctors
+SET schedule = format('mon-sat %s:00-%s:00', lpad(work_start_hour::text, 2, '0'), lpad(work_end_hour::text, 2, '0'));
+
+ALTER TABLE doctors DROP COLUMN work_start_hour;
+
+ALTER TABLE doctors DROP COLUMN work_end_hour;
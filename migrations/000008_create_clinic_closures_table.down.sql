@@ -0,0 +1 @@
+DROP TABLE IF EXISTS clinic_closures;
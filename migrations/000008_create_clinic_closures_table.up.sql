@@ -0,0 +1,14 @@
+CREATE TABLE
+    IF NOT EXISTS clinic_closures (
+        id SERIAL PRIMARY KEY,
+        -- NULL - закрыта вся клиника, иначе только запись к врачу (например, отпуск)
+        doctor_id INT REFERENCES doctors (id) ON DELETE CASCADE,
+        date_from DATE NOT NULL,
+        date_to DATE NOT NULL,
+        reason TEXT NOT NULL DEFAULT '',
+        -- manual - добавлено администратором, production_calendar - импорт производственного календаря
+        source VARCHAR(32) NOT NULL DEFAULT 'manual',
+        CHECK (date_to >= date_from)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_clinic_closures_dates ON clinic_closures (date_from, date_to);
@@ -0,0 +1,15 @@
+-- События локального календаря (CALENDAR_BACKEND=local) вместо Google Calendar
+CREATE TABLE
+    IF NOT EXISTS calendar_events (
+        id SERIAL PRIMARY KEY,
+        -- CALENDAR_ID клиники или calendar_id врача
+        calendar_id TEXT NOT NULL,
+        summary TEXT NOT NULL,
+        description TEXT NOT NULL DEFAULT '',
+        start_time TIMESTAMPTZ NOT NULL,
+        end_time TIMESTAMPTZ NOT NULL,
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        CHECK (end_time > start_time)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_calendar_events_calendar_start ON calendar_events (calendar_id, start_time);
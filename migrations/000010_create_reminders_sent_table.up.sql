@@ -0,0 +1,10 @@
+-- Отправленные напоминания: каждое напоминание о приеме отправляется один раз, в том числе после перезапуска бота.
+-- Время приема входит в ключ, чтобы после переноса записи напоминания пришли заново
+CREATE TABLE
+    IF NOT EXISTS reminders_sent (
+        booking_id INT NOT NULL REFERENCES bookings (id) ON DELETE CASCADE,
+        offset_minutes INT NOT NULL,
+        visit_time TIMESTAMPTZ NOT NULL,
+        sent_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        PRIMARY KEY (booking_id, offset_minutes, visit_time)
+    );
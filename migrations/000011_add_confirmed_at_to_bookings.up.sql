@@ -0,0 +1,2 @@
+-- Подтверждение визита пациентом по кнопке в напоминании
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS confirmed_at TIMESTAMPTZ;
@@ -0,0 +1,17 @@
+DROP INDEX IF EXISTS idx_bookings_user_status;
+
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+
+-- До появления статусов отмененные записи удалялись
+DELETE FROM bookings WHERE status NOT IN ('scheduled', 'confirmed');
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+    (COALESCE(doctor_id, 0)) WITH =,
+    tstzrange(datetime, end_time) WITH &&
+);
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS status_changed_at;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS status_reason;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS status;
@@ -0,0 +1,28 @@
+-- Жизненный цикл записи: отмененные и состоявшиеся приемы остаются в истории вместо удаления
+ALTER TABLE bookings
+ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'scheduled' CHECK (
+    status IN (
+        'scheduled',
+        'confirmed',
+        'cancelled_by_patient',
+        'cancelled_by_clinic',
+        'completed',
+        'no_show'
+    )
+);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+UPDATE bookings SET status = 'confirmed' WHERE confirmed_at IS NOT NULL;
+
+-- Время врача занимают только предстоящие приемы: отмененная запись не мешает записать другого пациента
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+    (COALESCE(doctor_id, 0)) WITH =,
+    tstzrange(datetime, end_time) WITH &&
+) WHERE (status IN ('scheduled', 'confirmed'));
+
+CREATE INDEX IF NOT EXISTS idx_bookings_user_status ON bookings (user_id, status);
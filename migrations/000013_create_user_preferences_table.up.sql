@@ -0,0 +1,7 @@
+CREATE TABLE
+    IF NOT EXISTS user_preferences (
+        chat_id BIGINT PRIMARY KEY,
+        language TEXT NOT NULL,
+        language_chosen BOOLEAN NOT NULL DEFAULT false,
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_bookings_patient_id;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS patient_id;
+
+DROP TABLE IF EXISTS patients;
@@ -0,0 +1,75 @@
+-- Профиль пациента: данные вводятся один раз и используются во всех записях
+CREATE TABLE
+    IF NOT EXISTS patients (
+        id SERIAL PRIMARY KEY,
+        -- Пользователь Telegram, которому принадлежит профиль
+        user_id BIGINT NOT NULL UNIQUE,
+        name TEXT NOT NULL DEFAULT '',
+        -- Телефон в формате E.164
+        phone TEXT NOT NULL DEFAULT '',
+        birth_date DATE,
+        notes TEXT NOT NULL DEFAULT '',
+        created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+        updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+    );
+
+-- name и contact в записи остаются снимком данных на момент записи
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS patient_id INTEGER REFERENCES patients (id) ON DELETE SET NULL;
+
+-- Профили заполняются данными из последней записи пользователя. Телефон приводится к E.164
+-- по правилам пакета phone; номера, которые не удалось разобрать, не переносятся
+WITH latest AS (
+    SELECT DISTINCT ON (user_id)
+        user_id,
+        COALESCE(name, '') AS name,
+        regexp_replace(COALESCE(contact, ''), '^\s+|\s+$', '', 'g') AS contact
+    FROM bookings
+    ORDER BY user_id, id DESC
+),
+parsed AS (
+    SELECT
+        user_id,
+        name,
+        contact ~ '^(\+|00)' AS international,
+        regexp_replace(regexp_replace(contact, '^(\+|00)', ''), '[ ().-]', '', 'g') AS digits
+    FROM latest
+),
+normalized AS (
+    SELECT
+        user_id,
+        name,
+        CASE
+            WHEN digits !~ '^[0-9]+$' THEN ''
+            WHEN international THEN digits
+            -- Без "+" принимаются только российские номера: 8XXXXXXXXXX, 7XXXXXXXXXX или 10 цифр
+            WHEN length(digits) = 11 AND left(digits, 1) IN ('7', '8') THEN '7' || substr(digits, 2)
+            WHEN length(digits) = 10 THEN '7' || digits
+            ELSE ''
+        END AS digits
+    FROM parsed
+)
+INSERT INTO patients (user_id, name, phone)
+SELECT
+    user_id,
+    name,
+    CASE
+        WHEN digits ~ '^[1-9][0-9]{7,14}$' AND (left(digits, 1) <> '7' OR length(digits) = 11) THEN '+' || digits
+        ELSE ''
+    END
+FROM normalized
+ON CONFLICT (user_id) DO NOTHING;
+
+-- Записи с другими данными могли быть сделаны для другого человека, их не связываем
+UPDATE bookings
+SET patient_id = patients.id
+FROM patients
+    JOIN (
+        SELECT DISTINCT ON (user_id) user_id, name, contact
+        FROM bookings
+        ORDER BY user_id, id DESC
+    ) AS latest ON latest.user_id = patients.user_id
+WHERE patients.user_id = bookings.user_id
+    AND bookings.name IS NOT DISTINCT FROM latest.name
+    AND bookings.contact IS NOT DISTINCT FROM latest.contact;
+
+CREATE INDEX IF NOT EXISTS idx_bookings_patient_id ON bookings (patient_id);
@@ -0,0 +1,9 @@
+DELETE FROM patients WHERE user_id IS NULL;
+
+DROP INDEX IF EXISTS idx_patients_guardian_id;
+
+ALTER TABLE patients DROP CONSTRAINT IF EXISTS patients_owner_check;
+
+ALTER TABLE patients DROP COLUMN IF EXISTS guardian_id;
+
+ALTER TABLE patients ALTER COLUMN user_id SET NOT NULL;
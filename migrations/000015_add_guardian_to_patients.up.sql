@@ -0,0 +1,9 @@
+-- Члены семьи (например, дети) записываются на прием из чата родителя:
+-- у такого профиля нет своего пользователя Telegram, а guardian_id указывает на профиль родителя
+ALTER TABLE patients ALTER COLUMN user_id DROP NOT NULL;
+
+ALTER TABLE patients ADD COLUMN IF NOT EXISTS guardian_id INTEGER REFERENCES patients (id) ON DELETE CASCADE;
+
+ALTER TABLE patients ADD CONSTRAINT patients_owner_check CHECK (user_id IS NOT NULL OR guardian_id IS NOT NULL);
+
+CREATE INDEX IF NOT EXISTS idx_patients_guardian_id ON patients (guardian_id);